@@ -0,0 +1,55 @@
+package go_currencycom
+
+import (
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+type aggTradesServiceTestSuite struct {
+	baseTestSuite
+}
+
+func TestAggTradesService(t *testing.T) {
+	suite.Run(t, new(aggTradesServiceTestSuite))
+}
+
+func (s *aggTradesServiceTestSuite) TestAggTrades() {
+	data := []byte(`[
+		{
+			"a": 1616651347,
+			"p": 11400.95,
+			"q": 0.058,
+			"T": 1596625079952,
+			"m": true
+		}
+	]`)
+	s.mockDo(data, nil)
+	defer s.assertDo()
+
+	symbol := "BTC/USD"
+	startTime := int64(1596625079000)
+	endTime := int64(1596625080000)
+	limit := 10
+	s.assertReq(func(r *request) {
+		e := newRequest().setParams(params{
+			"symbol":    symbol,
+			"startTime": startTime,
+			"endTime":   endTime,
+			"limit":     limit,
+		})
+		s.assertRequestEqual(e, r)
+	})
+	trades, err := s.client.NewAggTradesService().Symbol(symbol).
+		StartTime(startTime).EndTime(endTime).Limit(limit).
+		Do(newContext())
+	r := s.r()
+	r.NoError(err)
+	r.Len(trades, 1)
+	r.Equal(&AggTrade{
+		AggTradeID:   1616651347,
+		Price:        11400.95,
+		Quantity:     0.058,
+		Timestamp:    1596625079952,
+		IsBuyerMaker: true,
+	}, trades[0])
+}
@@ -0,0 +1,319 @@
+package go_currencycom
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CandleSource define the price a CandleBuilder reads from its events
+type CandleSource string
+
+// Candle sources
+const (
+	CandleSourceTrade CandleSource = "TRADE"
+	CandleSourceBid   CandleSource = "BID"
+	CandleSourceAsk   CandleSource = "ASK"
+	CandleSourceMid   CandleSource = "MID"
+)
+
+// Candle is a bar built locally by a CandleBuilder
+type Candle struct {
+	Symbol string
+	// OpenTime is the start of the interval for time bars, the time of the first event otherwise
+	OpenTime int64
+	// CloseTime is the end of the interval (exclusive) for time bars, the time of the last event otherwise
+	CloseTime int64
+	Open      float64
+	High      float64
+	Low       float64
+	Close     float64
+	// Volume is the traded size, always 0 for quote sources
+	Volume float64
+	// Count is the number of events merged in the candle
+	Count int
+	// Empty is set on candles filled for intervals without events
+	Empty bool
+
+	lastTime int64
+}
+
+// Kline returns the candle in the format of KlinesService
+func (c *Candle) Kline() *Kline {
+	return &Kline{
+		OpenTime:  time.UnixMilli(c.OpenTime).UTC(),
+		CloseTime: time.UnixMilli(c.CloseTime).UTC(),
+		Open:      c.Open,
+		High:      c.High,
+		Low:       c.Low,
+		Close:     c.Close,
+		Volume:    c.Volume,
+	}
+}
+
+type CandleHandler func(candle *Candle)
+
+// CandleBuilderConfig define how a CandleBuilder closes its candles. Exactly
+// one of Interval, Ticks and Volume must be set.
+type CandleBuilderConfig struct {
+	// Interval closes time bars aligned on multiples of Interval since the epoch
+	Interval time.Duration
+	// Ticks closes a candle after this number of events
+	Ticks int
+	// Volume closes a candle once its volume reaches this size
+	Volume float64
+	// Source is the price used for the candles, CandleSourceTrade by default
+	Source CandleSource
+	// FillEmpty emits flat candles at the previous close for intervals without events
+	FillEmpty bool
+}
+
+// CandleBuilderStats count the events handled by a CandleBuilder
+type CandleBuilderStats struct {
+	Events uint64
+	// Late is the number of events dropped because their candle was already emitted
+	Late    uint64
+	Candles uint64
+	Filled  uint64
+}
+
+// CandleBuilder aggregates trades or quotes of any number of symbols into
+// candles. Time bars are emitted once an event of a later interval arrives
+// or when Flush is called past their close time; events belonging to an
+// interval already emitted are dropped as late.
+type CandleBuilder struct {
+	cfg     CandleBuilderConfig
+	handler CandleHandler
+
+	mu      sync.Mutex
+	current map[string]*Candle
+	// last is the last candle emitted for each symbol
+	last  map[string]*Candle
+	stats CandleBuilderStats
+}
+
+// NewCandleBuilder init a builder emitting the closed candles to handler.
+// It returns an error if the config does not set exactly one of Interval,
+// Ticks and Volume, or if Interval is not a positive number of milliseconds.
+func NewCandleBuilder(cfg CandleBuilderConfig, handler CandleHandler) (*CandleBuilder, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Source == "" {
+		cfg.Source = CandleSourceTrade
+	}
+	return &CandleBuilder{
+		cfg:     cfg,
+		handler: handler,
+		current: make(map[string]*Candle),
+		last:    make(map[string]*Candle),
+	}, nil
+}
+
+func (cfg CandleBuilderConfig) validate() error {
+	if cfg.Interval < 0 || cfg.Interval%time.Millisecond != 0 {
+		return fmt.Errorf("invalid candle interval %s: not a positive number of milliseconds", cfg.Interval)
+	}
+	if cfg.Ticks < 0 {
+		return fmt.Errorf("invalid candle ticks %d", cfg.Ticks)
+	}
+	if cfg.Volume < 0 {
+		return fmt.Errorf("invalid candle volume %v", cfg.Volume)
+	}
+	set := 0
+	for _, ok := range []bool{cfg.Interval > 0, cfg.Ticks > 0, cfg.Volume > 0} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return errors.New("exactly one of candle interval, ticks and volume must be set")
+	}
+	return nil
+}
+
+// HandleTrade add a trade, ignored unless the source is CandleSourceTrade
+func (b *CandleBuilder) HandleTrade(event *WsTradesEvent) {
+	if b.cfg.Source != CandleSourceTrade {
+		return
+	}
+	b.add(event.Symbol, event.Timestamp, event.Price, event.Size)
+}
+
+// HandleMarketData add a quote, ignored if the source is CandleSourceTrade
+func (b *CandleBuilder) HandleMarketData(event *WsMarketDataEvent) {
+	var price float64
+	switch b.cfg.Source {
+	case CandleSourceBid:
+		price = event.Bid
+	case CandleSourceAsk:
+		price = event.Ofr
+	case CandleSourceMid:
+		price = (event.Bid + event.Ofr) / 2
+	default:
+		return
+	}
+	b.add(event.SymbolName, event.Timestamp, price, 0)
+}
+
+// TradesHandler returns HandleTrade as a WsTradesHandler
+func (b *CandleBuilder) TradesHandler() WsTradesHandler {
+	return b.HandleTrade
+}
+
+// MarketDataHandler returns HandleMarketData as a WsMarketDataHandler
+func (b *CandleBuilder) MarketDataHandler() WsMarketDataHandler {
+	return b.HandleMarketData
+}
+
+func (b *CandleBuilder) add(symbol string, ts int64, price, size float64) {
+	b.mu.Lock()
+	emit := b.addLocked(symbol, ts, price, size)
+	b.mu.Unlock()
+	b.emit(emit)
+}
+
+func (b *CandleBuilder) addLocked(symbol string, ts int64, price, size float64) (emit []*Candle) {
+	b.stats.Events++
+	if last, ok := b.last[symbol]; ok && ts < last.CloseTime {
+		b.stats.Late++
+		return nil
+	}
+	c := b.current[symbol]
+	if c != nil && b.cfg.Interval > 0 && ts >= c.CloseTime {
+		emit = append(emit, b.closeLocked(symbol))
+		c = nil
+	}
+	if c == nil {
+		emit = append(emit, b.fillLocked(symbol, ts)...)
+		c = &Candle{Symbol: symbol, OpenTime: ts, CloseTime: ts, Open: price, High: price, Low: price, Close: price, lastTime: ts}
+		if b.cfg.Interval > 0 {
+			c.OpenTime = b.align(ts)
+			c.CloseTime = c.OpenTime + b.intervalMs()
+		}
+		b.current[symbol] = c
+	}
+
+	if price > c.High {
+		c.High = price
+	}
+	if price < c.Low {
+		c.Low = price
+	}
+	// out of order events of the current candle do not move its close
+	if ts >= c.lastTime {
+		c.Close = price
+		c.lastTime = ts
+		if b.cfg.Interval <= 0 {
+			c.CloseTime = ts
+		}
+	}
+	c.Volume += size
+	c.Count++
+
+	if (b.cfg.Ticks > 0 && c.Count >= b.cfg.Ticks) || (b.cfg.Volume > 0 && c.Volume >= b.cfg.Volume) {
+		emit = append(emit, b.closeLocked(symbol))
+	}
+	return emit
+}
+
+func (b *CandleBuilder) intervalMs() int64 {
+	return b.cfg.Interval.Milliseconds()
+}
+
+func (b *CandleBuilder) align(ts int64) int64 {
+	return ts - ts%b.intervalMs()
+}
+
+func (b *CandleBuilder) closeLocked(symbol string) *Candle {
+	c := b.current[symbol]
+	delete(b.current, symbol)
+	b.last[symbol] = c
+	b.stats.Candles++
+	return c
+}
+
+// fillLocked returns flat candles for the intervals between the last
+// emitted candle of symbol and the interval containing ts
+func (b *CandleBuilder) fillLocked(symbol string, ts int64) (emit []*Candle) {
+	last, ok := b.last[symbol]
+	if !ok || !b.cfg.FillEmpty || b.cfg.Interval <= 0 {
+		return nil
+	}
+	end := b.align(ts)
+	for open := last.CloseTime; open < end; open += b.intervalMs() {
+		last = &Candle{
+			Symbol:    symbol,
+			OpenTime:  open,
+			CloseTime: open + b.intervalMs(),
+			Open:      last.Close,
+			High:      last.Close,
+			Low:       last.Close,
+			Close:     last.Close,
+			Empty:     true,
+		}
+		emit = append(emit, last)
+		b.last[symbol] = last
+		b.stats.Filled++
+	}
+	return emit
+}
+
+// Flush emit the time bars closing at or before now, and the empty intervals
+// up to now if FillEmpty is set. It does nothing for tick and volume bars.
+func (b *CandleBuilder) Flush(now time.Time) {
+	if b.cfg.Interval <= 0 {
+		return
+	}
+	ts := now.UnixMilli()
+	var emit []*Candle
+	b.mu.Lock()
+	for symbol, c := range b.current {
+		if ts >= c.CloseTime {
+			emit = append(emit, b.closeLocked(symbol))
+		}
+	}
+	for symbol := range b.last {
+		if _, ok := b.current[symbol]; !ok {
+			emit = append(emit, b.fillLocked(symbol, ts)...)
+		}
+	}
+	b.mu.Unlock()
+	b.emit(emit)
+}
+
+// Close emit every candle in progress, even if its interval is not over
+func (b *CandleBuilder) Close() {
+	var emit []*Candle
+	b.mu.Lock()
+	for symbol := range b.current {
+		emit = append(emit, b.closeLocked(symbol))
+	}
+	b.mu.Unlock()
+	b.emit(emit)
+}
+
+func (b *CandleBuilder) emit(candles []*Candle) {
+	for _, c := range candles {
+		b.handler(c)
+	}
+}
+
+// Current returns a copy of the candle in progress for symbol
+func (b *CandleBuilder) Current(symbol string) (candle Candle, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.current[symbol]
+	if !ok {
+		return Candle{}, false
+	}
+	return *c, true
+}
+
+// Stats returns the counters of the builder
+func (b *CandleBuilder) Stats() CandleBuilderStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stats
+}
@@ -0,0 +1,146 @@
+package go_currencycom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type candleBuilderTestSuite struct {
+	suite.Suite
+	candles []*Candle
+}
+
+func TestCandleBuilder(t *testing.T) {
+	suite.Run(t, new(candleBuilderTestSuite))
+}
+
+func (s *candleBuilderTestSuite) SetupTest() {
+	s.candles = nil
+}
+
+func (s *candleBuilderTestSuite) handle(candle *Candle) {
+	s.candles = append(s.candles, candle)
+}
+
+func (s *candleBuilderTestSuite) trade(b *CandleBuilder, ts int64, price, size float64) {
+	b.HandleTrade(&WsTradesEvent{Symbol: "TXN", Timestamp: ts, Price: price, Size: size})
+}
+
+func (s *candleBuilderTestSuite) TestTimeBars() {
+	b, err := NewCandleBuilder(CandleBuilderConfig{Interval: 10 * time.Second}, s.handle)
+	s.Require().NoError(err)
+	s.trade(b, 10_500, 100, 1)
+	s.trade(b, 12_000, 103, 2)
+	s.trade(b, 11_000, 99, 1) // out of order within the interval
+	s.trade(b, 19_999, 101, 1)
+	r := s.Require()
+	r.Empty(s.candles)
+
+	s.trade(b, 20_000, 102, 1)
+	r.Len(s.candles, 1)
+	c := s.candles[0]
+	r.Equal(int64(10_000), c.OpenTime)
+	r.Equal(int64(20_000), c.CloseTime)
+	r.Equal(100.0, c.Open)
+	r.Equal(103.0, c.High)
+	r.Equal(99.0, c.Low)
+	r.Equal(101.0, c.Close)
+	r.Equal(5.0, c.Volume)
+	r.Equal(4, c.Count)
+	r.Equal(&Kline{
+		OpenTime:  time.UnixMilli(10_000).UTC(),
+		CloseTime: time.UnixMilli(20_000).UTC(),
+		Open:      100,
+		High:      103,
+		Low:       99,
+		Close:     101,
+		Volume:    5,
+	}, c.Kline())
+
+	// late event of the emitted interval
+	s.trade(b, 15_000, 50, 1)
+	r.Equal(uint64(1), b.Stats().Late)
+	current, ok := b.Current("TXN")
+	r.True(ok)
+	r.Equal(102.0, current.Low)
+
+	b.Flush(time.UnixMilli(30_000))
+	r.Len(s.candles, 2)
+	r.Equal(int64(20_000), s.candles[1].OpenTime)
+	_, ok = b.Current("TXN")
+	r.False(ok)
+}
+
+func (s *candleBuilderTestSuite) TestFillEmpty() {
+	b, err := NewCandleBuilder(CandleBuilderConfig{Interval: time.Second, FillEmpty: true}, s.handle)
+	s.Require().NoError(err)
+	s.trade(b, 0, 100, 1)
+	s.trade(b, 3_500, 105, 1)
+	r := s.Require()
+	r.Len(s.candles, 3)
+	r.False(s.candles[0].Empty)
+	for i, c := range s.candles[1:] {
+		r.True(c.Empty)
+		r.Equal(int64(i+1)*1000, c.OpenTime)
+		r.Equal(100.0, c.Open)
+		r.Equal(100.0, c.Close)
+		r.Zero(c.Volume)
+	}
+
+	b.Flush(time.UnixMilli(6_000))
+	r.Len(s.candles, 6)
+	r.Equal(int64(4_000), s.candles[3].CloseTime)
+	r.Equal(105.0, s.candles[5].Close)
+	r.True(s.candles[5].Empty)
+	r.Equal(uint64(4), b.Stats().Filled)
+}
+
+func (s *candleBuilderTestSuite) TestTickBars() {
+	b, err := NewCandleBuilder(CandleBuilderConfig{Ticks: 2, Source: CandleSourceMid}, s.handle)
+	s.Require().NoError(err)
+	b.HandleMarketData(&WsMarketDataEvent{SymbolName: "TXN", Bid: 1, Ofr: 3, Timestamp: 1})
+	b.HandleMarketData(&WsMarketDataEvent{SymbolName: "TXN", Bid: 3, Ofr: 5, Timestamp: 2})
+	b.HandleMarketData(&WsMarketDataEvent{SymbolName: "TXN", Bid: 5, Ofr: 7, Timestamp: 3})
+	b.HandleTrade(&WsTradesEvent{Symbol: "TXN", Price: 100, Timestamp: 4})
+	r := s.Require()
+	r.Len(s.candles, 1)
+	r.Equal(Candle{Symbol: "TXN", OpenTime: 1, CloseTime: 2, Open: 2, High: 4, Low: 2, Close: 4, Count: 2, lastTime: 2}, *s.candles[0])
+
+	b.Close()
+	r.Len(s.candles, 2)
+	r.Equal(6.0, s.candles[1].Close)
+	r.Equal(1, s.candles[1].Count)
+}
+
+func (s *candleBuilderTestSuite) TestVolumeBars() {
+	b, err := NewCandleBuilder(CandleBuilderConfig{Volume: 10}, s.handle)
+	s.Require().NoError(err)
+	s.trade(b, 1, 100, 4)
+	s.trade(b, 2, 101, 4)
+	s.trade(b, 3, 102, 3)
+	s.trade(b, 4, 103, 1)
+	r := s.Require()
+	r.Len(s.candles, 1)
+	r.Equal(11.0, s.candles[0].Volume)
+	r.Equal(102.0, s.candles[0].Close)
+	current, _ := b.Current("TXN")
+	r.Equal(103.0, current.Open)
+}
+
+func (s *candleBuilderTestSuite) TestInvalidConfig() {
+	r := s.Require()
+	for _, cfg := range []CandleBuilderConfig{
+		{},
+		{Interval: time.Microsecond},
+		{Interval: 1500 * time.Microsecond},
+		{Interval: -time.Second},
+		{Interval: time.Second, Ticks: 2},
+		{Ticks: -1},
+		{Volume: -1},
+	} {
+		_, err := NewCandleBuilder(cfg, s.handle)
+		r.Error(err, "%+v", cfg)
+	}
+}
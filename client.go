@@ -12,6 +12,7 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"sync/atomic"
 	"time"
 )
 
@@ -39,6 +40,12 @@ type ExpireTimestampType string
 // CandlestickInterval define interval of candlestick
 type CandlestickInterval string
 
+// KlinePriceType define the price of klines
+type KlinePriceType string
+
+// KlineType define the type of klines
+type KlineType string
+
 const (
 	// BaseURL is the base url of currency.com api
 	BaseURL = "https://api-adapter.backend.currency.com/"
@@ -92,6 +99,13 @@ const (
 	CandlestickInterval4h  CandlestickInterval = "4h"
 	CandlestickInterval1d  CandlestickInterval = "1d"
 	CandlestickInterval1w  CandlestickInterval = "1w"
+
+	KlinePriceTypeBid KlinePriceType = "bid"
+	KlinePriceTypeAsk KlinePriceType = "ask"
+	KlinePriceTypeMid KlinePriceType = "mid"
+
+	KlineTypeClassic    KlineType = "classic"
+	KlineTypeHeikinAshi KlineType = "heikin-ashi"
 )
 
 func FormatTimestamp(t time.Time) int64 {
@@ -129,6 +143,8 @@ type Client struct {
 	Logger     *log.Logger
 	TimeOffset int64
 	do         doFunc
+	// rateLimitIntervalCache is the request interval of the exchange rate limits
+	rateLimitIntervalCache atomic.Pointer[time.Duration]
 }
 
 func NewClient(apiKey, secretKey string) *Client {
@@ -242,7 +258,7 @@ func (c *Client) callAPI(ctx context.Context, r *request, opts ...RequestOption)
 	c.debug("Response: %s", string(data))
 
 	if resp.StatusCode >= http.StatusBadRequest {
-		apiErr := new(APIError)
+		apiErr := &APIError{StatusCode: resp.StatusCode}
 		e := json.Unmarshal(data, apiErr)
 		if e != nil {
 			c.debug("Failed to parse error message: %s", e)
@@ -305,3 +321,15 @@ func (c *Client) NewListHistoricalPositionsService() *ListHistoricalPositionsSer
 func (c *Client) NewKlinesService() *KlinesService {
 	return &KlinesService{c: c}
 }
+
+func (c *Client) NewAggTradesService() *AggTradesService {
+	return &AggTradesService{c: c}
+}
+
+func (c *Client) NewKlineDownloader() *KlineDownloader {
+	return &KlineDownloader{c: c, MinInterval: 100 * time.Millisecond, Retries: 3, RetryDelay: time.Second}
+}
+
+func (c *Client) NewMarketSnapshotService() *MarketSnapshotService {
+	return &MarketSnapshotService{c: c}
+}
@@ -0,0 +1,68 @@
+// Command currencycom-relay serves the Currency.com websocket streams to
+// local consumers over a single set of upstream connections.
+//
+// Point the clients at it with
+//
+//	currencycom.WebsocketEndpoint = "ws://localhost:8080/connect"
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	currencycom "github.com/radovsky1/go-currencycom"
+	"github.com/radovsky1/go-currencycom/relay"
+)
+
+func main() {
+	logger := log.New(os.Stderr, "relay: ", log.LstdFlags)
+	if err := run(logger); err != nil {
+		logger.Fatal(err)
+	}
+}
+
+func run(logger *log.Logger) error {
+	addr := flag.String("addr", "localhost:8080", "address to listen on")
+	demo := flag.Bool("demo", false, "relay the demo api")
+	upstream := flag.String("upstream", "", "upstream websocket endpoint, overrides -demo")
+	origins := flag.String("origin", "", "comma separated origins allowed besides the relay host, * for any")
+	flag.Parse()
+
+	currencycom.UseDemo = *demo
+	opts := make([]currencycom.WsOption, 0)
+	if *upstream != "" {
+		opts = append(opts, currencycom.WithWsEndpoint(*upstream))
+	}
+	server := relay.NewServer(opts...)
+	server.ErrorLog = logger
+	if *origins != "" {
+		server.CheckOrigin = checkOrigin(strings.Split(*origins, ","))
+	}
+	defer server.Close()
+
+	mux := http.NewServeMux()
+	mux.Handle("/connect", server)
+	logger.Printf("listening on ws://%s/connect", *addr)
+	return http.ListenAndServe(*addr, mux)
+}
+
+// checkOrigin accepts the requests without origin, from the relay host or
+// from one of origins
+func checkOrigin(origins []string) func(r *http.Request) bool {
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		allowed[strings.TrimSpace(origin)] = true
+	}
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" || allowed["*"] || allowed[origin] {
+			return true
+		}
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+}
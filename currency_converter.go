@@ -0,0 +1,211 @@
+package go_currencycom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNoConversionPath is returned when no chain of quoted symbols links two currencies
+var ErrNoConversionPath = errors.New("no conversion path")
+
+// ConversionStep is the conversion through one symbol. Rate is the bid of the
+// symbol when selling its base asset, the inverse of its ask when buying it.
+type ConversionStep struct {
+	Symbol string
+	From   string
+	To     string
+	Rate   float64
+	Time   time.Time
+}
+
+// Conversion is an amount converted along a path of symbols
+type Conversion struct {
+	From   string
+	To     string
+	Amount float64
+	Result float64
+	// Rate is the product of the rates of the path
+	Rate float64
+	Path []ConversionStep
+	// Time is the time of the oldest quote of the path
+	Time time.Time
+}
+
+type conversionQuote struct {
+	bid, ask float64
+	time     time.Time
+}
+
+type conversionEdge struct {
+	symbol string
+	from   string
+	to     string
+	// sell is set when the edge sells the base asset of symbol
+	sell bool
+}
+
+// CurrencyConverter converts amounts between the assets of the exchange info.
+// Every symbol links its base and quote assets; quotes come from the market
+// data stream through Handle or from the depth through Refresh, and amounts
+// are converted along the shortest path of quoted symbols.
+type CurrencyConverter struct {
+	c     *Client
+	edges map[string][]conversionEdge
+
+	mu     sync.RWMutex
+	quotes map[string]conversionQuote
+}
+
+// NewCurrencyConverter init a converter for the symbols of info, c is only
+// needed by Refresh
+func NewCurrencyConverter(c *Client, info *ExchangeInfo) *CurrencyConverter {
+	edges := make(map[string][]conversionEdge)
+	for _, s := range info.Symbols {
+		if s.BaseAsset == "" || s.QuoteAsset == "" || s.BaseAsset == s.QuoteAsset {
+			continue
+		}
+		edges[s.BaseAsset] = append(edges[s.BaseAsset], conversionEdge{symbol: s.Symbol, from: s.BaseAsset, to: s.QuoteAsset, sell: true})
+		edges[s.QuoteAsset] = append(edges[s.QuoteAsset], conversionEdge{symbol: s.Symbol, from: s.QuoteAsset, to: s.BaseAsset})
+	}
+	for _, e := range edges {
+		sort.SliceStable(e, func(i, j int) bool {
+			return e[i].symbol < e[j].symbol
+		})
+	}
+	return &CurrencyConverter{c: c, edges: edges, quotes: make(map[string]conversionQuote)}
+}
+
+// SetQuote set the bid and ask of symbol at t
+func (cc *CurrencyConverter) SetQuote(symbol string, bid, ask float64, t time.Time) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.quotes[symbol] = conversionQuote{bid: bid, ask: ask, time: t}
+}
+
+// Handle set the quote of a market data event
+func (cc *CurrencyConverter) Handle(event *WsMarketDataEvent) {
+	cc.SetQuote(event.SymbolName, event.Bid, event.Ofr, time.UnixMilli(event.Timestamp))
+}
+
+// Handler returns Handle as a WsMarketDataHandler
+func (cc *CurrencyConverter) Handler() WsMarketDataHandler {
+	return cc.Handle
+}
+
+// rate returns the rate of edge, ok is false if its symbol is not quoted
+func (cc *CurrencyConverter) rate(e conversionEdge) (rate float64, t time.Time, ok bool) {
+	q, found := cc.quotes[e.symbol]
+	if !found {
+		return 0, time.Time{}, false
+	}
+	bid, ask := q.bid, q.ask
+	if bid <= 0 {
+		bid = ask
+	}
+	if ask <= 0 {
+		ask = bid
+	}
+	if bid <= 0 {
+		return 0, time.Time{}, false
+	}
+	if e.sell {
+		return bid, q.time, true
+	}
+	return 1 / ask, q.time, true
+}
+
+// path returns the shortest path from from to to, through quoted symbols only if quoted
+func (cc *CurrencyConverter) path(from, to string, quoted bool) ([]conversionEdge, bool) {
+	prev := map[string]conversionEdge{from: {}}
+	queue := []string{from}
+	for len(queue) > 0 {
+		asset := queue[0]
+		queue = queue[1:]
+		if asset == to {
+			var res []conversionEdge
+			for asset != from {
+				e := prev[asset]
+				res = append([]conversionEdge{e}, res...)
+				asset = e.from
+			}
+			return res, true
+		}
+		for _, e := range cc.edges[asset] {
+			if _, seen := prev[e.to]; seen {
+				continue
+			}
+			if quoted {
+				if _, _, ok := cc.rate(e); !ok {
+					continue
+				}
+			}
+			prev[e.to] = e
+			queue = append(queue, e.to)
+		}
+	}
+	return nil, false
+}
+
+// Convert convert amount of from into to with the current quotes
+func (cc *CurrencyConverter) Convert(amount float64, from, to string) (*Conversion, error) {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	res := &Conversion{From: from, To: to, Amount: amount, Result: amount, Rate: 1, Path: []ConversionStep{}}
+	if from == to {
+		return res, nil
+	}
+	edges, ok := cc.path(from, to, true)
+	if !ok {
+		return nil, fmt.Errorf("%w from %s to %s", ErrNoConversionPath, from, to)
+	}
+	for _, e := range edges {
+		rate, t, _ := cc.rate(e)
+		res.Path = append(res.Path, ConversionStep{Symbol: e.symbol, From: e.from, To: e.to, Rate: rate, Time: t})
+		res.Rate *= rate
+		if res.Time.IsZero() || t.Before(res.Time) {
+			res.Time = t
+		}
+	}
+	res.Result = amount * res.Rate
+	return res, nil
+}
+
+// Refresh fetch the depth of the symbols of the shortest path from from to
+// to, whether they are quoted or not
+func (cc *CurrencyConverter) Refresh(ctx context.Context, from, to string, opts ...RequestOption) error {
+	if from == to {
+		return nil
+	}
+	cc.mu.RLock()
+	edges, ok := cc.path(from, to, false)
+	cc.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w from %s to %s", ErrNoConversionPath, from, to)
+	}
+	for _, e := range edges {
+		depth, err := cc.c.NewDepthService().Symbol(e.symbol).Do(ctx, opts...)
+		if err != nil {
+			return err
+		}
+		var bid, ask float64
+		for _, level := range depth.Bids {
+			if level.Price > bid {
+				bid = level.Price
+			}
+		}
+		for _, level := range depth.Asks {
+			if ask == 0 || level.Price < ask {
+				ask = level.Price
+			}
+		}
+		if bid <= 0 && ask <= 0 {
+			return fmt.Errorf("%s: %w", e.symbol, ErrNoLiquidity)
+		}
+		cc.SetQuote(e.symbol, bid, ask, time.Now())
+	}
+	return nil
+}
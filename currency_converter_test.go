@@ -0,0 +1,101 @@
+package go_currencycom
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type currencyConverterTestSuite struct {
+	baseTestSuite
+}
+
+func TestCurrencyConverter(t *testing.T) {
+	suite.Run(t, new(currencyConverterTestSuite))
+}
+
+func (s *currencyConverterTestSuite) converter() *CurrencyConverter {
+	return NewCurrencyConverter(s.client.Client, &ExchangeInfo{Symbols: []ExchangeSymbolInfo{
+		{Symbol: "BTC/USD_LEVERAGE", BaseAsset: "BTC", QuoteAsset: "USD"},
+		{Symbol: "BTC/EUR", BaseAsset: "BTC", QuoteAsset: "EUR"},
+		{Symbol: "ETH/BTC", BaseAsset: "ETH", QuoteAsset: "BTC"},
+		{Symbol: "EUR/USD", BaseAsset: "EUR", QuoteAsset: "USD"},
+		{Symbol: "GBP/USD", BaseAsset: "GBP", QuoteAsset: "USD"},
+	}})
+}
+
+func (s *currencyConverterTestSuite) TestConvert() {
+	cc := s.converter()
+	t1, t2 := time.UnixMilli(1000), time.UnixMilli(2000)
+	cc.Handler()(&WsMarketDataEvent{SymbolName: "BTC/USD_LEVERAGE", Bid: 30000, Ofr: 30010, Timestamp: 2000})
+	cc.SetQuote("BTC/EUR", 27000, 27010, t2)
+	cc.SetQuote("ETH/BTC", 0.05, 0.051, t1)
+	cc.SetQuote("EUR/USD", 1.1, 1.2, t2)
+
+	r := s.r()
+	res, err := cc.Convert(2, "BTC", "USD")
+	r.NoError(err)
+	r.Equal(60000.0, res.Result)
+	r.Equal([]ConversionStep{{Symbol: "BTC/USD_LEVERAGE", From: "BTC", To: "USD", Rate: 30000, Time: t2}}, res.Path)
+	r.Equal(t2, res.Time)
+
+	res, err = cc.Convert(30010, "USD", "BTC")
+	r.NoError(err)
+	r.InDelta(1, res.Result, 1e-12)
+
+	// triangulated through BTC
+	res, err = cc.Convert(10, "ETH", "EUR")
+	r.NoError(err)
+	r.InDelta(13500, res.Result, 1e-9)
+	r.InDelta(1350, res.Rate, 1e-9)
+	r.Len(res.Path, 2)
+	r.Equal("ETH/BTC", res.Path[0].Symbol)
+	r.Equal("BTC/EUR", res.Path[1].Symbol)
+	r.Equal(t1, res.Time)
+
+	res, err = cc.Convert(5, "EUR", "EUR")
+	r.NoError(err)
+	r.Equal(5.0, res.Result)
+	r.Empty(res.Path)
+
+	// unquoted symbols are skipped
+	cc.SetQuote("BTC/EUR", 0, 0, t2)
+	res, err = cc.Convert(1, "ETH", "EUR")
+	r.NoError(err)
+	r.Equal([]string{"ETH/BTC", "BTC/USD_LEVERAGE", "EUR/USD"}, []string{res.Path[0].Symbol, res.Path[1].Symbol, res.Path[2].Symbol})
+	r.InDelta(0.05*30000/1.2, res.Result, 1e-9)
+
+	_, err = cc.Convert(1, "GBP", "USD")
+	r.True(errors.Is(err, ErrNoConversionPath))
+	r.EqualError(err, "no conversion path from GBP to USD")
+	_, err = cc.Convert(1, "USD", "JPY")
+	r.True(errors.Is(err, ErrNoConversionPath))
+}
+
+func (s *currencyConverterTestSuite) TestRefresh() {
+	data := []byte(`{
+        "lastUpdateId": 1027024,
+        "asks": [[1.28, 10], [1.27, 5]],
+        "bids": [[1.25, 10], [1.26, 2]]
+    }`)
+	s.mockDo(data, nil)
+	defer s.assertDo()
+	s.assertReq(func(r *request) {
+		e := newRequest().setParam("symbol", "GBP/USD")
+		s.assertRequestEqual(e, r)
+	})
+	cc := s.converter()
+	r := s.r()
+	r.NoError(cc.Refresh(newContext(), "GBP", "USD"))
+	res, err := cc.Convert(100, "GBP", "USD")
+	r.NoError(err)
+	r.InDelta(126, res.Result, 1e-9)
+	res, err = cc.Convert(127, "USD", "GBP")
+	r.NoError(err)
+	r.InDelta(100, res.Result, 1e-9)
+
+	r.NoError(cc.Refresh(newContext(), "USD", "USD"))
+	r.True(errors.Is(cc.Refresh(newContext(), "USD", "JPY"), ErrNoConversionPath))
+}
@@ -8,6 +8,8 @@ import (
 type APIError struct {
 	Code    int64  `json:"code"`
 	Message string `json:"msg"`
+	// StatusCode is the HTTP status of the response
+	StatusCode int `json:"-"`
 }
 
 // Error return error code and message
@@ -20,3 +22,23 @@ func IsAPIError(e error) bool {
 	_, ok := e.(*APIError)
 	return ok
 }
+
+// WsError define the error payload of a websocket message whose status is not OK
+type WsError struct {
+	Status        string
+	Destination   string
+	CorrelationID string
+	Code          int64
+	Message       string
+}
+
+// Error return status, error code and message
+func (e WsError) Error() string {
+	return fmt.Sprintf("<WsError> status=%s, code=%d, msg=%s", e.Status, e.Code, e.Message)
+}
+
+// IsWsError check if e is a websocket error
+func IsWsError(e error) bool {
+	_, ok := e.(*WsError)
+	return ok
+}
@@ -0,0 +1,124 @@
+package indicators
+
+import "math"
+
+// ADXValue is the output of ADX
+type ADXValue struct {
+	ADX     float64
+	PlusDI  float64
+	MinusDI float64
+}
+
+type adxState struct {
+	n        int
+	prev     Bar
+	tr       float64
+	plusDM   float64
+	minusDM  float64
+	dxSum    float64
+	adx      float64
+	plusDI   float64
+	minusDI  float64
+	diReady  bool
+	adxReady bool
+}
+
+// ADX is Wilder's average directional index of period bars, with the plus
+// and minus directional indicators
+type ADX struct {
+	period    int
+	prev, cur adxState
+}
+
+// NewADX init an average directional index, usually of period 14
+func NewADX(period int) *ADX {
+	return &ADX{period: period}
+}
+
+// Next add a bar
+func (a *ADX) Next(bar Bar) ADXValue {
+	a.prev = a.cur
+	return a.Revise(bar)
+}
+
+// Revise replace the last bar
+func (a *ADX) Revise(bar Bar) ADXValue {
+	s := a.prev
+	s.n++
+	if s.n > 1 {
+		up, down := bar.High-s.prev.High, s.prev.Low-bar.Low
+		var plusDM, minusDM float64
+		if up > down && up > 0 {
+			plusDM = up
+		}
+		if down > up && down > 0 {
+			minusDM = down
+		}
+		tr := trueRange(bar, s.prev.Close, false)
+
+		p := float64(a.period)
+		// the first smoothed values are the sums of the first period moves
+		if s.n <= a.period+1 {
+			s.tr += tr
+			s.plusDM += plusDM
+			s.minusDM += minusDM
+		} else {
+			s.tr = s.tr - s.tr/p + tr
+			s.plusDM = s.plusDM - s.plusDM/p + plusDM
+			s.minusDM = s.minusDM - s.minusDM/p + minusDM
+		}
+
+		if s.n >= a.period+1 {
+			s.diReady = true
+			s.plusDI, s.minusDI = 0, 0
+			if s.tr != 0 {
+				s.plusDI = 100 * s.plusDM / s.tr
+				s.minusDI = 100 * s.minusDM / s.tr
+			}
+			var dx float64
+			if sum := s.plusDI + s.minusDI; sum != 0 {
+				dx = 100 * math.Abs(s.plusDI-s.minusDI) / sum
+			}
+			switch dxN := s.n - a.period; {
+			case dxN < a.period:
+				s.dxSum += dx
+			case dxN == a.period:
+				s.adx = (s.dxSum + dx) / p
+				s.adxReady = true
+			default:
+				s.adx = (s.adx*(p-1) + dx) / p
+			}
+		}
+	}
+	s.prev = bar
+	a.cur = s
+	return a.Value()
+}
+
+// Value returns the current index and indicators
+func (a *ADX) Value() ADXValue {
+	v := ADXValue{ADX: math.NaN(), PlusDI: math.NaN(), MinusDI: math.NaN()}
+	if a.cur.diReady {
+		v.PlusDI, v.MinusDI = a.cur.plusDI, a.cur.minusDI
+	}
+	if a.cur.adxReady {
+		v.ADX = a.cur.adx
+	}
+	return v
+}
+
+// NextBar implements BarIndicator
+func (a *ADX) NextBar(bar Bar) { a.Next(bar) }
+
+// ReviseBar implements BarIndicator
+func (a *ADX) ReviseBar(bar Bar) { a.Revise(bar) }
+
+// ADXSeries returns the average directional index of every bar
+func ADXSeries(bars []Bar, period int) []ADXValue {
+	a := NewADX(period)
+	res := make([]ADXValue, len(bars))
+	for i, bar := range bars {
+		res[i] = a.Next(bar)
+	}
+	return res
+}
@@ -0,0 +1,80 @@
+package indicators
+
+import "math"
+
+// trueRange returns the true range of bar after a bar closed at prevClose,
+// its high-low range if it is the first bar
+func trueRange(bar Bar, prevClose float64, first bool) float64 {
+	tr := bar.High - bar.Low
+	if !first {
+		tr = math.Max(tr, math.Max(math.Abs(bar.High-prevClose), math.Abs(bar.Low-prevClose)))
+	}
+	return tr
+}
+
+type atrState struct {
+	n         int
+	prevClose float64
+	sum       float64
+	value     float64
+}
+
+// ATR is the average true range of period bars, smoothed with Wilder's method
+type ATR struct {
+	period    int
+	prev, cur atrState
+}
+
+// NewATR init an average true range, usually of period 14
+func NewATR(period int) *ATR {
+	return &ATR{period: period}
+}
+
+// Next add a bar
+func (a *ATR) Next(bar Bar) float64 {
+	a.prev = a.cur
+	return a.Revise(bar)
+}
+
+// Revise replace the last bar
+func (a *ATR) Revise(bar Bar) float64 {
+	s := a.prev
+	tr := trueRange(bar, s.prevClose, s.n == 0)
+	s.n++
+	p := float64(a.period)
+	switch {
+	case s.n < a.period:
+		s.sum += tr
+	case s.n == a.period:
+		s.value = (s.sum + tr) / p
+	default:
+		s.value = (s.value*(p-1) + tr) / p
+	}
+	s.prevClose = bar.Close
+	a.cur = s
+	return a.Value()
+}
+
+// Value returns the current average
+func (a *ATR) Value() float64 {
+	if a.cur.n < a.period {
+		return math.NaN()
+	}
+	return a.cur.value
+}
+
+// NextBar implements BarIndicator
+func (a *ATR) NextBar(bar Bar) { a.Next(bar) }
+
+// ReviseBar implements BarIndicator
+func (a *ATR) ReviseBar(bar Bar) { a.Revise(bar) }
+
+// ATRSeries returns the average true range of every bar
+func ATRSeries(bars []Bar, period int) []float64 {
+	a := NewATR(period)
+	res := make([]float64, len(bars))
+	for i, bar := range bars {
+		res[i] = a.Next(bar)
+	}
+	return res
+}
@@ -0,0 +1,64 @@
+package indicators
+
+import "math"
+
+// BollingerValue is the output of BollingerBands
+type BollingerValue struct {
+	Upper  float64
+	Middle float64
+	Lower  float64
+}
+
+// BollingerBands are the simple moving average of period values and the
+// bands k population standard deviations above and below it
+type BollingerBands struct {
+	k   float64
+	sma *SMA
+}
+
+// NewBollingerBands init Bollinger bands, usually of period 20 and k 2
+func NewBollingerBands(period int, k float64) *BollingerBands {
+	return &BollingerBands{k: k, sma: NewSMA(period)}
+}
+
+// Next add a value
+func (b *BollingerBands) Next(v float64) BollingerValue {
+	b.sma.Next(v)
+	return b.Value()
+}
+
+// Revise replace the last value
+func (b *BollingerBands) Revise(v float64) BollingerValue {
+	b.sma.Revise(v)
+	return b.Value()
+}
+
+// Value returns the current bands
+func (b *BollingerBands) Value() BollingerValue {
+	mean := b.sma.Value()
+	if math.IsNaN(mean) {
+		return BollingerValue{Upper: mean, Middle: mean, Lower: mean}
+	}
+	var variance float64
+	for _, v := range b.sma.cur.values {
+		variance += (v - mean) * (v - mean)
+	}
+	dev := b.k * math.Sqrt(variance/float64(len(b.sma.cur.values)))
+	return BollingerValue{Upper: mean + dev, Middle: mean, Lower: mean - dev}
+}
+
+// NextBar implements BarIndicator on close prices
+func (b *BollingerBands) NextBar(bar Bar) { b.Next(bar.Close) }
+
+// ReviseBar implements BarIndicator on close prices
+func (b *BollingerBands) ReviseBar(bar Bar) { b.Revise(bar.Close) }
+
+// BollingerSeries returns the Bollinger bands of every value
+func BollingerSeries(values []float64, period int, k float64) []BollingerValue {
+	b := NewBollingerBands(period, k)
+	res := make([]BollingerValue, len(values))
+	for i, v := range values {
+		res[i] = b.Next(v)
+	}
+	return res
+}
@@ -0,0 +1,168 @@
+// Package indicators computes technical indicators over klines and OHLC
+// streams. Every indicator has a batch function over a whole series and a
+// streaming type fed one bar at a time.
+//
+// Streaming types take a new bar with Next and a new version of the last bar
+// with Revise, so that a candle still in progress, such as the updates of an
+// OHLC stream, can be fed repeatedly without counting it more than once.
+// Values are NaN until an indicator has seen enough bars.
+package indicators
+
+import (
+	"math"
+	"time"
+
+	currencycom "github.com/radovsky1/go-currencycom"
+)
+
+// Bar is the input of the indicators
+type Bar struct {
+	Time   time.Time
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume float64
+}
+
+// FromKline returns the bar of a kline
+func FromKline(k *currencycom.Kline) Bar {
+	return Bar{
+		Time:   k.OpenTime,
+		Open:   k.Open,
+		High:   k.High,
+		Low:    k.Low,
+		Close:  k.Close,
+		Volume: k.Volume,
+	}
+}
+
+// FromKlines returns the bars of klines
+func FromKlines(klines []*currencycom.Kline) []Bar {
+	bars := make([]Bar, len(klines))
+	for i, k := range klines {
+		bars[i] = FromKline(k)
+	}
+	return bars
+}
+
+// FromOHLCEvent returns the bar of an OHLC stream update, opened at its
+// timestamp. The stream has no volume: fed with these bars, VWAP stays NaN.
+func FromOHLCEvent(e *currencycom.WsOHLCMarketDataEvent) Bar {
+	return Bar{
+		Time:  time.UnixMilli(e.Timestamp).UTC(),
+		Open:  e.Open,
+		High:  e.High,
+		Low:   e.Low,
+		Close: e.Close,
+	}
+}
+
+// Closes returns the close prices of bars
+func Closes(bars []Bar) []float64 {
+	closes := make([]float64, len(bars))
+	for i, b := range bars {
+		closes[i] = b.Close
+	}
+	return closes
+}
+
+// BarIndicator is a streaming indicator fed with bars
+type BarIndicator interface {
+	// NextBar add a new bar
+	NextBar(bar Bar)
+	// ReviseBar replace the last bar
+	ReviseBar(bar Bar)
+}
+
+// Feed passes bars to indicators, calling ReviseBar for updates of the bar
+// in progress and NextBar once a bar with a new time arrives. Bars older
+// than the one in progress are ignored.
+type Feed struct {
+	indicators []BarIndicator
+	last       time.Time
+	started    bool
+}
+
+// NewFeed init a feed of indicators
+func NewFeed(indicators ...BarIndicator) *Feed {
+	return &Feed{indicators: indicators}
+}
+
+// Add pass bar to the indicators
+func (f *Feed) Add(bar Bar) {
+	switch {
+	case f.started && bar.Time.Equal(f.last):
+		for _, ind := range f.indicators {
+			ind.ReviseBar(bar)
+		}
+	case !f.started || bar.Time.After(f.last):
+		f.last, f.started = bar.Time, true
+		for _, ind := range f.indicators {
+			ind.NextBar(bar)
+		}
+	}
+}
+
+// Handler returns a WsOHLCMarketDataHandler adding the updates of symbol and
+// interval to the feed
+func (f *Feed) Handler(symbol string, interval currencycom.CandlestickInterval) currencycom.WsOHLCMarketDataHandler {
+	return func(event *currencycom.WsOHLCMarketDataEvent) {
+		if event.Symbol == symbol && event.Interval == string(interval) {
+			f.Add(FromOHLCEvent(event))
+		}
+	}
+}
+
+// series run a streaming indicator over values
+func series(values []float64, next func(float64) float64) []float64 {
+	res := make([]float64, len(values))
+	for i, v := range values {
+		res[i] = next(v)
+	}
+	return res
+}
+
+// window is a sliding window of the last values. Appending never writes
+// inside the elements of an older copy, so that a copy taken before Next can
+// be used to revise the last value.
+type window struct {
+	values []float64
+	size   int
+}
+
+func (w window) push(v float64) window {
+	values := append(w.values, v)
+	if len(values) > w.size {
+		values = values[len(values)-w.size:]
+	}
+	return window{values: values, size: w.size}
+}
+
+func (w window) full() bool {
+	return len(w.values) == w.size
+}
+
+func (w window) min() float64 {
+	m := math.Inf(1)
+	for _, v := range w.values {
+		m = math.Min(m, v)
+	}
+	return m
+}
+
+func (w window) max() float64 {
+	m := math.Inf(-1)
+	for _, v := range w.values {
+		m = math.Max(m, v)
+	}
+	return m
+}
+
+func (w window) mean() float64 {
+	var sum float64
+	for _, v := range w.values {
+		sum += v
+	}
+	return sum / float64(len(w.values))
+}
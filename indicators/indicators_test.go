@@ -0,0 +1,272 @@
+package indicators
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	currencycom "github.com/radovsky1/go-currencycom"
+	"github.com/stretchr/testify/suite"
+)
+
+// closes is the RSI example of Wilder's method published by StockCharts
+var closes = []float64{
+	44.34, 44.09, 44.15, 43.61, 44.33, 44.83, 45.10, 45.42, 45.84, 46.08,
+	45.89, 46.03, 45.61, 46.28, 46.28, 46.00, 46.03, 46.41, 46.22, 45.64,
+}
+
+// worked is a short series of high, low, close and volume whose indicators
+// are worked out by hand in the tests below, from the definitions of the
+// indicators (Wilder's smoothing for ATR and ADX, SMA-seeded EMAs for MACD)
+var worked = [][4]float64{
+	{10, 8, 9, 1},
+	{12, 9, 11, 2},
+	{11, 7, 8, 1},
+	{13, 10, 13, 3},
+	{12, 11, 11.5, 2},
+	{15, 12, 14, 1},
+}
+
+func workedBars() []Bar {
+	bars := make([]Bar, len(worked))
+	start := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	for i, v := range worked {
+		bars[i] = Bar{
+			Time:   start.Add(time.Duration(i) * time.Minute),
+			High:   v[0],
+			Low:    v[1],
+			Close:  v[2],
+			Volume: v[3],
+		}
+	}
+	return bars
+}
+
+// ohlcv is a random walk of open, high, low, close and volume, used to
+// compare streamed values with the batch functions
+var ohlcv = [][5]float64{
+	{100, 101.65, 99.23, 100.44, 42},
+	{100.44, 100.68, 100.07, 100.48, 7},
+	{100.48, 102.35, 97.5, 97.84, 33},
+	{97.84, 98.93, 97.65, 97.79, 28},
+	{97.79, 99.93, 97.44, 98.19, 6},
+	{98.19, 101.01, 96.02, 96.37, 37},
+	{96.37, 97.0, 95.23, 96.65, 41},
+	{96.65, 99.63, 96.34, 98.74, 38},
+	{98.74, 100.77, 98.49, 99.13, 3},
+	{99.13, 101.98, 98.45, 99.76, 27},
+	{99.76, 100.49, 97.0, 97.52, 37},
+	{97.52, 99.09, 94.66, 98.51, 12},
+	{98.51, 99.03, 95.54, 98.09, 41},
+	{98.09, 99.05, 96.19, 96.53, 36},
+	{96.53, 96.85, 93.65, 93.87, 40},
+	{93.87, 94.92, 91.33, 94.45, 35},
+	{94.45, 96.63, 92.85, 95.08, 38},
+	{95.08, 97.4, 93.23, 94.81, 16},
+	{94.81, 95.73, 93.57, 93.79, 37},
+	{93.79, 95.32, 91.11, 93.76, 22},
+	{93.76, 96.05, 92.29, 95.19, 5},
+	{95.19, 95.79, 92.57, 94.28, 11},
+	{94.28, 96.03, 93.51, 95.07, 27},
+	{95.07, 95.27, 94.68, 95.25, 36},
+	{95.25, 98.18, 93.65, 95.6, 45},
+	{95.6, 97.39, 93.06, 96.26, 30},
+	{96.26, 96.61, 95.79, 96.07, 31},
+	{96.07, 96.4, 95.76, 96.36, 45},
+	{96.36, 97.94, 93.41, 97.35, 29},
+	{97.35, 98.8, 95.38, 98.29, 23},
+	{98.29, 98.4, 95.93, 97.04, 11},
+	{97.04, 97.63, 94.52, 94.74, 14},
+	{94.74, 96.21, 94.08, 96.08, 16},
+	{96.08, 98.11, 94.08, 96.62, 6},
+	{96.62, 97.47, 94.33, 95.93, 36},
+	{95.93, 97.35, 95.23, 96.4, 36},
+	{96.4, 97.82, 94.28, 95.87, 44},
+	{95.87, 97.81, 94.69, 95.28, 6},
+	{95.28, 96.18, 94.51, 94.99, 43},
+	{94.99, 96.18, 94.93, 95.7, 38},
+}
+
+func testBars() []Bar {
+	bars := make([]Bar, len(ohlcv))
+	start := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	for i, v := range ohlcv {
+		bars[i] = Bar{
+			Time:   start.Add(time.Duration(i) * time.Minute),
+			Open:   v[0],
+			High:   v[1],
+			Low:    v[2],
+			Close:  v[3],
+			Volume: v[4],
+		}
+	}
+	return bars
+}
+
+type indicatorsTestSuite struct {
+	suite.Suite
+}
+
+func TestIndicators(t *testing.T) {
+	suite.Run(t, new(indicatorsTestSuite))
+}
+
+func (s *indicatorsTestSuite) assertValues(e []float64, a []float64) {
+	r := s.Require()
+	r.Len(a, len(e))
+	for i := range e {
+		if math.IsNaN(e[i]) {
+			r.True(math.IsNaN(a[i]), "value %d: %v", i, a[i])
+			continue
+		}
+		r.InDelta(e[i], a[i], 1e-4, "value %d", i)
+	}
+}
+
+func (s *indicatorsTestSuite) TestRSI() {
+	nan := math.NaN()
+	s.assertValues([]float64{
+		nan, nan, nan, nan, nan, nan, nan, nan, nan, nan, nan, nan, nan, nan,
+		70.4641, 66.2496, 66.4809, 69.3469, 66.2947, 57.9150,
+	}, RSISeries(closes, 14))
+	s.Require().Equal(100.0, RSISeries([]float64{1, 2, 3}, 2)[2])
+}
+
+func (s *indicatorsTestSuite) TestMovingAverages() {
+	s.assertValues([]float64{46.2, 46.188, 46.06}, SMASeries(closes, 5)[17:])
+	s.assertValues([]float64{46.151121, 46.174080, 45.996054}, EMASeries(closes, 5)[17:])
+	s.assertValues([]float64{46.200667, 46.207333, 46.024667}, WMASeries(closes, 5)[17:])
+	s.assertValues([]float64{math.NaN(), math.NaN(), 2, 3}, SMASeries([]float64{1, 2, 3, 4}, 3))
+	s.assertValues([]float64{math.NaN(), 2, 2.666667}, EMASeries([]float64{1, 3, 3}, 2))
+}
+
+func (s *indicatorsTestSuite) TestBollinger() {
+	v := BollingerSeries(closes, 10, 2)
+	r := s.Require()
+	r.True(math.IsNaN(v[8].Middle))
+	r.InDelta(46.550347, v[19].Upper, 1e-6)
+	r.InDelta(46.039, v[19].Middle, 1e-6)
+	r.InDelta(45.527653, v[19].Lower, 1e-6)
+}
+
+func (s *indicatorsTestSuite) TestMACD() {
+	// EMA(2) of the closes: 10, 26/3, 104/9, 311/27, 1067/81 from the second
+	// bar; EMA(3): 28/3, 67/6, 34/3, 38/3 from the third. The signal EMA(2)
+	// of their difference starts at the fourth bar.
+	v := MACDSeries(Closes(workedBars()), 2, 3, 2)
+	nan := math.NaN()
+	macd := make([]float64, len(v))
+	signal := make([]float64, len(v))
+	histogram := make([]float64, len(v))
+	for i := range v {
+		macd[i], signal[i], histogram[i] = v[i].MACD, v[i].Signal, v[i].Histogram
+	}
+	s.assertValues([]float64{nan, nan, -2.0 / 3, 7.0 / 18, 5.0 / 27, 41.0 / 81}, macd)
+	s.assertValues([]float64{nan, nan, nan, -5.0 / 36, 25.0 / 324, 353.0 / 972}, signal)
+	s.assertValues([]float64{nan, nan, nan, 19.0 / 36, 35.0 / 324, 139.0 / 972}, histogram)
+}
+
+func (s *indicatorsTestSuite) TestATR() {
+	// true ranges 2, 3, 4, 5, 2, 3.5: the first ATR is (2+3+4)/3, then
+	// (3*2+5)/3 = 11/3, (11/3*2+2)/3 = 28/9, (28/9*2+3.5)/3 = 175/54
+	nan := math.NaN()
+	s.assertValues([]float64{nan, nan, 3, 11.0 / 3, 28.0 / 9, 175.0 / 54}, ATRSeries(workedBars(), 3))
+}
+
+func (s *indicatorsTestSuite) TestStochastic() {
+	// %K(3): (8-7)/(12-7), (13-7)/(13-7), (11.5-7)/(13-7), (14-10)/(15-10)
+	v := StochasticSeries(workedBars(), 3, 2)
+	nan := math.NaN()
+	k := make([]float64, len(v))
+	d := make([]float64, len(v))
+	for i := range v {
+		k[i], d[i] = v[i].K, v[i].D
+	}
+	s.assertValues([]float64{nan, nan, 20, 100, 75, 80}, k)
+	s.assertValues([]float64{nan, nan, nan, 60, 87.5, 77.5}, d)
+}
+
+func (s *indicatorsTestSuite) TestVWAP() {
+	// typical prices 9, 32/3, 26/3, 12, 23/2, 41/3 weighted by the volumes
+	s.assertValues([]float64{9, 91.0 / 9, 39.0 / 4, 75.0 / 7, 98.0 / 9, 67.0 / 6}, VWAPSeries(workedBars()))
+
+	vwap := NewVWAP()
+	s.Require().True(math.IsNaN(vwap.Value()))
+	vwap.Next(Bar{High: 3, Low: 1, Close: 2, Volume: 1})
+	vwap.Reset()
+	s.Require().Equal(10.0, vwap.Next(Bar{High: 11, Low: 9, Close: 10, Volume: 2}))
+
+	// the OHLC stream has no volume
+	vwap.Reset()
+	NewFeed(vwap).Handler("BTC/USD", currencycom.CandlestickInterval1m)(&currencycom.WsOHLCMarketDataEvent{
+		Symbol: "BTC/USD", Interval: "1m", Timestamp: 60000, High: 11, Low: 9, Close: 10,
+	})
+	s.Require().True(math.IsNaN(vwap.Value()))
+}
+
+func (s *indicatorsTestSuite) TestADX() {
+	// +DM 2, 0, 2, 0, 3 and -DM 0, 2, 0, 0, 0 from the second bar, over true
+	// ranges 3, 4, 5, 2, 3.5. The smoothed sums give +DI 200/7, 600/17, 24,
+	// 3000/53 and -DI 200/7, 200/17, 8, 200/53 from the third bar, hence DX
+	// 0, 50, 50, 87.5 and ADX (0+50)/2, (25+50)/2, (37.5+87.5)/2.
+	v := ADXSeries(workedBars(), 2)
+	nan := math.NaN()
+	adx := make([]float64, len(v))
+	plusDI := make([]float64, len(v))
+	minusDI := make([]float64, len(v))
+	for i := range v {
+		adx[i], plusDI[i], minusDI[i] = v[i].ADX, v[i].PlusDI, v[i].MinusDI
+	}
+	s.assertValues([]float64{nan, nan, nan, 25, 37.5, 62.5}, adx)
+	s.assertValues([]float64{nan, nan, 200.0 / 7, 600.0 / 17, 24, 3000.0 / 53}, plusDI)
+	s.assertValues([]float64{nan, nan, 200.0 / 7, 200.0 / 17, 8, 200.0 / 53}, minusDI)
+}
+
+// TestRevise feeds every bar as a series of updates, as an OHLC stream does,
+// and expects the same values as the batch functions
+func (s *indicatorsTestSuite) TestRevise() {
+	bars := testBars()
+	sma, ema, wma, rsi := NewSMA(5), NewEMA(5), NewWMA(5), NewRSI(14)
+	macd, boll := NewMACD(3, 6, 4), NewBollingerBands(10, 2)
+	atr, stoch, vwap, adx := NewATR(14), NewStochastic(14, 3), NewVWAP(), NewADX(14)
+	feed := NewFeed(sma, ema, wma, rsi, macd, boll, atr, stoch, vwap, adx)
+	for _, bar := range bars {
+		partial := bar
+		partial.High, partial.Low, partial.Close, partial.Volume = bar.Open+5, bar.Open-5, bar.Open, 1
+		feed.Add(partial)
+		feed.Add(bar)
+		// late updates of an older bar are ignored
+		feed.Add(Bar{Time: bar.Time.Add(-time.Minute), Close: 1000})
+	}
+
+	closes := Closes(bars)
+	r := s.Require()
+	r.InDelta(SMASeries(closes, 5)[39], sma.Value(), 1e-9)
+	r.InDelta(EMASeries(closes, 5)[39], ema.Value(), 1e-9)
+	r.InDelta(WMASeries(closes, 5)[39], wma.Value(), 1e-9)
+	r.InDelta(RSISeries(closes, 14)[39], rsi.Value(), 1e-9)
+	r.InDelta(MACDSeries(closes, 3, 6, 4)[39].Signal, macd.Value().Signal, 1e-9)
+	r.InDelta(BollingerSeries(closes, 10, 2)[39].Upper, boll.Value().Upper, 1e-9)
+	r.InDelta(ATRSeries(bars, 14)[39], atr.Value(), 1e-9)
+	r.InDelta(StochasticSeries(bars, 14, 3)[39].D, stoch.Value().D, 1e-9)
+	r.InDelta(VWAPSeries(bars)[39], vwap.Value(), 1e-9)
+	r.InDelta(ADXSeries(bars, 14)[39].ADX, adx.Value().ADX, 1e-9)
+}
+
+func (s *indicatorsTestSuite) TestFeedHandler() {
+	ema := NewEMA(2)
+	handler := NewFeed(ema).Handler("BTC/USD", currencycom.CandlestickInterval1m)
+	handler(&currencycom.WsOHLCMarketDataEvent{Symbol: "BTC/USD", Interval: "1m", Timestamp: 60000, Close: 1})
+	handler(&currencycom.WsOHLCMarketDataEvent{Symbol: "BTC/USD", Interval: "1m", Timestamp: 120000, Close: 2})
+	handler(&currencycom.WsOHLCMarketDataEvent{Symbol: "ETH/USD", Interval: "1m", Timestamp: 180000, Close: 100})
+	// other intervals of the symbol are ignored
+	handler(&currencycom.WsOHLCMarketDataEvent{Symbol: "BTC/USD", Interval: "5m", Timestamp: 180000, Close: 100})
+	handler(&currencycom.WsOHLCMarketDataEvent{Symbol: "BTC/USD", Interval: "1m", Timestamp: 120000, Close: 5})
+	s.Require().Equal(3.0, ema.Value())
+}
+
+func (s *indicatorsTestSuite) TestFromKlines() {
+	open := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	bars := FromKlines([]*currencycom.Kline{{OpenTime: open, Open: 1, High: 2, Low: 0.5, Close: 1.5, Volume: 10}})
+	s.Require().Equal([]Bar{{Time: open, Open: 1, High: 2, Low: 0.5, Close: 1.5, Volume: 10}}, bars)
+}
@@ -0,0 +1,61 @@
+package indicators
+
+import "math"
+
+// MACDValue is the output of MACD
+type MACDValue struct {
+	MACD      float64
+	Signal    float64
+	Histogram float64
+}
+
+// MACD is the moving average convergence divergence: the difference between
+// a fast and a slow EMA, and the EMA of this difference as signal line
+type MACD struct {
+	fast, slow, signal *EMA
+}
+
+// NewMACD init a MACD, usually of periods 12, 26 and 9
+func NewMACD(fast, slow, signal int) *MACD {
+	return &MACD{fast: NewEMA(fast), slow: NewEMA(slow), signal: NewEMA(signal)}
+}
+
+// Next add a value
+func (m *MACD) Next(v float64) MACDValue {
+	if macd := m.fast.Next(v) - m.slow.Next(v); !math.IsNaN(macd) {
+		m.signal.Next(macd)
+	}
+	return m.Value()
+}
+
+// Revise replace the last value
+func (m *MACD) Revise(v float64) MACDValue {
+	if macd := m.fast.Revise(v) - m.slow.Revise(v); !math.IsNaN(macd) {
+		m.signal.Revise(macd)
+	}
+	return m.Value()
+}
+
+// Value returns the current lines, the signal and histogram are NaN until
+// the signal period is over
+func (m *MACD) Value() MACDValue {
+	macd := m.fast.Value() - m.slow.Value()
+	signal := m.signal.Value()
+	return MACDValue{MACD: macd, Signal: signal, Histogram: macd - signal}
+}
+
+// NextBar implements BarIndicator on close prices
+func (m *MACD) NextBar(bar Bar) { m.Next(bar.Close) }
+
+// ReviseBar implements BarIndicator on close prices
+func (m *MACD) ReviseBar(bar Bar) { m.Revise(bar.Close) }
+
+// MACDSeries returns the MACD of every value
+func MACDSeries(values []float64, fast, slow, signal int) []MACDValue {
+	m := NewMACD(fast, slow, signal)
+	res := make([]MACDValue, len(values))
+	for i, v := range values {
+		res[i] = m.Next(v)
+	}
+	return res
+}
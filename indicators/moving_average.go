@@ -0,0 +1,155 @@
+package indicators
+
+import "math"
+
+// SMA is the simple moving average of the last period values
+type SMA struct {
+	prev, cur window
+}
+
+// NewSMA init a simple moving average
+func NewSMA(period int) *SMA {
+	w := window{size: period}
+	return &SMA{prev: w, cur: w}
+}
+
+// Next add a value
+func (s *SMA) Next(v float64) float64 {
+	s.prev = s.cur
+	return s.Revise(v)
+}
+
+// Revise replace the last value
+func (s *SMA) Revise(v float64) float64 {
+	s.cur = s.prev.push(v)
+	return s.Value()
+}
+
+// Value returns the current average
+func (s *SMA) Value() float64 {
+	if !s.cur.full() {
+		return math.NaN()
+	}
+	return s.cur.mean()
+}
+
+// NextBar implements BarIndicator on close prices
+func (s *SMA) NextBar(bar Bar) { s.Next(bar.Close) }
+
+// ReviseBar implements BarIndicator on close prices
+func (s *SMA) ReviseBar(bar Bar) { s.Revise(bar.Close) }
+
+// SMASeries returns the simple moving average of every value
+func SMASeries(values []float64, period int) []float64 {
+	return series(values, NewSMA(period).Next)
+}
+
+type emaState struct {
+	n     int
+	sum   float64
+	value float64
+}
+
+// EMA is the exponential moving average of period values, seeded with the
+// simple average of the first period values
+type EMA struct {
+	period    int
+	alpha     float64
+	prev, cur emaState
+}
+
+// NewEMA init an exponential moving average
+func NewEMA(period int) *EMA {
+	return &EMA{period: period, alpha: 2 / float64(period+1)}
+}
+
+// Next add a value
+func (e *EMA) Next(v float64) float64 {
+	e.prev = e.cur
+	return e.Revise(v)
+}
+
+// Revise replace the last value
+func (e *EMA) Revise(v float64) float64 {
+	s := e.prev
+	s.n++
+	switch {
+	case s.n < e.period:
+		s.sum += v
+	case s.n == e.period:
+		s.sum += v
+		s.value = s.sum / float64(e.period)
+	default:
+		s.value += e.alpha * (v - s.value)
+	}
+	e.cur = s
+	return e.Value()
+}
+
+// Value returns the current average
+func (e *EMA) Value() float64 {
+	if e.cur.n < e.period {
+		return math.NaN()
+	}
+	return e.cur.value
+}
+
+// NextBar implements BarIndicator on close prices
+func (e *EMA) NextBar(bar Bar) { e.Next(bar.Close) }
+
+// ReviseBar implements BarIndicator on close prices
+func (e *EMA) ReviseBar(bar Bar) { e.Revise(bar.Close) }
+
+// EMASeries returns the exponential moving average of every value
+func EMASeries(values []float64, period int) []float64 {
+	return series(values, NewEMA(period).Next)
+}
+
+// WMA is the linearly weighted moving average of the last period values,
+// the latest value having the weight period
+type WMA struct {
+	prev, cur window
+}
+
+// NewWMA init a weighted moving average
+func NewWMA(period int) *WMA {
+	w := window{size: period}
+	return &WMA{prev: w, cur: w}
+}
+
+// Next add a value
+func (w *WMA) Next(v float64) float64 {
+	w.prev = w.cur
+	return w.Revise(v)
+}
+
+// Revise replace the last value
+func (w *WMA) Revise(v float64) float64 {
+	w.cur = w.prev.push(v)
+	return w.Value()
+}
+
+// Value returns the current average
+func (w *WMA) Value() float64 {
+	if !w.cur.full() {
+		return math.NaN()
+	}
+	var sum, weights float64
+	for i, v := range w.cur.values {
+		weight := float64(i + 1)
+		sum += weight * v
+		weights += weight
+	}
+	return sum / weights
+}
+
+// NextBar implements BarIndicator on close prices
+func (w *WMA) NextBar(bar Bar) { w.Next(bar.Close) }
+
+// ReviseBar implements BarIndicator on close prices
+func (w *WMA) ReviseBar(bar Bar) { w.Revise(bar.Close) }
+
+// WMASeries returns the weighted moving average of every value
+func WMASeries(values []float64, period int) []float64 {
+	return series(values, NewWMA(period).Next)
+}
@@ -0,0 +1,79 @@
+package indicators
+
+import "math"
+
+type rsiState struct {
+	n        int
+	last     float64
+	avgGain  float64
+	avgLoss  float64
+	sumGain  float64
+	sumLoss  float64
+	computed bool
+}
+
+// RSI is the relative strength index of period changes, smoothed with
+// Wilder's method
+type RSI struct {
+	period    int
+	prev, cur rsiState
+}
+
+// NewRSI init a relative strength index, usually of period 14
+func NewRSI(period int) *RSI {
+	return &RSI{period: period}
+}
+
+// Next add a value
+func (r *RSI) Next(v float64) float64 {
+	r.prev = r.cur
+	return r.Revise(v)
+}
+
+// Revise replace the last value
+func (r *RSI) Revise(v float64) float64 {
+	s := r.prev
+	s.n++
+	if s.n > 1 {
+		change := v - s.last
+		gain, loss := math.Max(change, 0), math.Max(-change, 0)
+		p := float64(r.period)
+		switch {
+		case s.n <= r.period:
+			s.sumGain += gain
+			s.sumLoss += loss
+		case s.n == r.period+1:
+			s.avgGain = (s.sumGain + gain) / p
+			s.avgLoss = (s.sumLoss + loss) / p
+			s.computed = true
+		default:
+			s.avgGain = (s.avgGain*(p-1) + gain) / p
+			s.avgLoss = (s.avgLoss*(p-1) + loss) / p
+		}
+	}
+	s.last = v
+	r.cur = s
+	return r.Value()
+}
+
+// Value returns the current index, between 0 and 100
+func (r *RSI) Value() float64 {
+	if !r.cur.computed {
+		return math.NaN()
+	}
+	if r.cur.avgLoss == 0 {
+		return 100
+	}
+	return 100 - 100/(1+r.cur.avgGain/r.cur.avgLoss)
+}
+
+// NextBar implements BarIndicator on close prices
+func (r *RSI) NextBar(bar Bar) { r.Next(bar.Close) }
+
+// ReviseBar implements BarIndicator on close prices
+func (r *RSI) ReviseBar(bar Bar) { r.Revise(bar.Close) }
+
+// RSISeries returns the relative strength index of every value
+func RSISeries(values []float64, period int) []float64 {
+	return series(values, NewRSI(period).Next)
+}
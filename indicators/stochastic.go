@@ -0,0 +1,87 @@
+package indicators
+
+import "math"
+
+// StochasticValue is the output of Stochastic
+type StochasticValue struct {
+	K float64
+	D float64
+}
+
+type stochasticState struct {
+	highs, lows window
+	close       float64
+}
+
+// Stochastic is the stochastic oscillator: %K locates the close within the
+// range of the last kPeriod bars, %D is the simple average of dPeriod %K
+type Stochastic struct {
+	prev, cur stochasticState
+	d         *SMA
+}
+
+// NewStochastic init a stochastic oscillator, usually of periods 14 and 3
+func NewStochastic(kPeriod, dPeriod int) *Stochastic {
+	s := stochasticState{highs: window{size: kPeriod}, lows: window{size: kPeriod}}
+	return &Stochastic{prev: s, cur: s, d: NewSMA(dPeriod)}
+}
+
+// Next add a bar
+func (s *Stochastic) Next(bar Bar) StochasticValue {
+	s.prev = s.cur
+	s.cur = s.push(bar)
+	if k := s.k(); !math.IsNaN(k) {
+		s.d.Next(k)
+	}
+	return s.Value()
+}
+
+// Revise replace the last bar
+func (s *Stochastic) Revise(bar Bar) StochasticValue {
+	s.cur = s.push(bar)
+	if k := s.k(); !math.IsNaN(k) {
+		s.d.Revise(k)
+	}
+	return s.Value()
+}
+
+func (s *Stochastic) push(bar Bar) stochasticState {
+	return stochasticState{
+		highs: s.prev.highs.push(bar.High),
+		lows:  s.prev.lows.push(bar.Low),
+		close: bar.Close,
+	}
+}
+
+// k returns %K, 0 if the range is empty
+func (s *Stochastic) k() float64 {
+	if !s.cur.highs.full() {
+		return math.NaN()
+	}
+	high, low := s.cur.highs.max(), s.cur.lows.min()
+	if high == low {
+		return 0
+	}
+	return 100 * (s.cur.close - low) / (high - low)
+}
+
+// Value returns the current oscillator
+func (s *Stochastic) Value() StochasticValue {
+	return StochasticValue{K: s.k(), D: s.d.Value()}
+}
+
+// NextBar implements BarIndicator
+func (s *Stochastic) NextBar(bar Bar) { s.Next(bar) }
+
+// ReviseBar implements BarIndicator
+func (s *Stochastic) ReviseBar(bar Bar) { s.Revise(bar) }
+
+// StochasticSeries returns the stochastic oscillator of every bar
+func StochasticSeries(bars []Bar, kPeriod, dPeriod int) []StochasticValue {
+	s := NewStochastic(kPeriod, dPeriod)
+	res := make([]StochasticValue, len(bars))
+	for i, bar := range bars {
+		res[i] = s.Next(bar)
+	}
+	return res
+}
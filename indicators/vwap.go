@@ -0,0 +1,65 @@
+package indicators
+
+import "math"
+
+type vwapState struct {
+	notional float64
+	volume   float64
+}
+
+// VWAP is the volume weighted average of the typical price (high + low +
+// close) / 3 of the bars since the start or the last Reset. It needs the
+// volume of the bars, which klines have and OHLC stream updates do not.
+type VWAP struct {
+	prev, cur vwapState
+}
+
+// NewVWAP init a volume weighted average price
+func NewVWAP() *VWAP {
+	return new(VWAP)
+}
+
+// Next add a bar
+func (v *VWAP) Next(bar Bar) float64 {
+	v.prev = v.cur
+	return v.Revise(bar)
+}
+
+// Revise replace the last bar
+func (v *VWAP) Revise(bar Bar) float64 {
+	typical := (bar.High + bar.Low + bar.Close) / 3
+	v.cur = vwapState{
+		notional: v.prev.notional + typical*bar.Volume,
+		volume:   v.prev.volume + bar.Volume,
+	}
+	return v.Value()
+}
+
+// Value returns the current average, NaN without volume
+func (v *VWAP) Value() float64 {
+	if v.cur.volume == 0 {
+		return math.NaN()
+	}
+	return v.cur.notional / v.cur.volume
+}
+
+// Reset start a new session
+func (v *VWAP) Reset() {
+	v.prev, v.cur = vwapState{}, vwapState{}
+}
+
+// NextBar implements BarIndicator
+func (v *VWAP) NextBar(bar Bar) { v.Next(bar) }
+
+// ReviseBar implements BarIndicator
+func (v *VWAP) ReviseBar(bar Bar) { v.Revise(bar) }
+
+// VWAPSeries returns the volume weighted average price after every bar
+func VWAPSeries(bars []Bar) []float64 {
+	v := NewVWAP()
+	res := make([]float64, len(bars))
+	for i, bar := range bars {
+		res[i] = v.Next(bar)
+	}
+	return res
+}
@@ -0,0 +1,161 @@
+package go_currencycom
+
+import (
+	"fmt"
+	"strings"
+)
+
+// InstrumentMode define the market of an instrument, as ExchangeSymbolInfo.MarketType
+type InstrumentMode string
+
+// InstrumentKind define how the symbol of an instrument is written
+type InstrumentKind string
+
+const (
+	InstrumentModeLeverage InstrumentMode = "LEVERAGE"
+	InstrumentModeExchange InstrumentMode = "SPOT"
+
+	// InstrumentKindPair is a BASE/QUOTE pair, suffixed with _LEVERAGE in leverage mode
+	InstrumentKindPair InstrumentKind = "PAIR"
+	// InstrumentKindTicker is an exchange traded ticker followed by a dot, such as AAPL.
+	InstrumentKindTicker InstrumentKind = "TICKER"
+	// InstrumentKindName is a leverage instrument named after its asset, such as Apple
+	InstrumentKindName InstrumentKind = "NAME"
+
+	leverageSuffix = "_LEVERAGE"
+)
+
+// Instrument is a parsed Currency.com symbol
+type Instrument struct {
+	Kind InstrumentKind
+	// Base and Quote are the assets of a pair, Base is the ticker of a ticker
+	Base  string
+	Quote string
+	// Name is the name of a named instrument
+	Name string
+	Mode InstrumentMode
+	// explicit is set when the symbol itself tells the mode
+	explicit bool
+}
+
+// ParseInstrument parse a symbol such as BTC/USD_LEVERAGE, BTC/USD, EUR/USD, AAPL. or Apple.
+// Mode is only set when the symbol tells it: pairs without suffix are exchange
+// pairs or leverage currency pairs such as EUR/USD, and names are used in both
+// modes. Use InstrumentOf to get the mode from the exchange info.
+func ParseInstrument(symbol string) (Instrument, error) {
+	if strings.TrimSpace(symbol) != symbol || symbol == "" {
+		return Instrument{}, fmt.Errorf("invalid symbol: %q", symbol)
+	}
+	if pair := strings.TrimSuffix(symbol, leverageSuffix); pair != symbol {
+		i, err := parsePair(symbol, pair)
+		i.Mode, i.explicit = InstrumentModeLeverage, true
+		return i, err
+	}
+	if strings.Contains(symbol, "/") {
+		return parsePair(symbol, symbol)
+	}
+	if ticker := strings.TrimSuffix(symbol, "."); ticker != symbol {
+		if ticker == "" || strings.Contains(ticker, ".") {
+			return Instrument{}, fmt.Errorf("invalid symbol: %q", symbol)
+		}
+		return Instrument{Kind: InstrumentKindTicker, Base: ticker, Mode: InstrumentModeExchange, explicit: true}, nil
+	}
+	return Instrument{Kind: InstrumentKindName, Name: symbol}, nil
+}
+
+// InstrumentOf parse the symbol of info and set its mode from the market type
+func InstrumentOf(info *ExchangeSymbolInfo) (Instrument, error) {
+	i, err := ParseInstrument(info.Symbol)
+	if err != nil {
+		return Instrument{}, err
+	}
+	if err = i.Validate(info); err != nil {
+		return Instrument{}, err
+	}
+	i.Mode = InstrumentMode(info.MarketType)
+	return i, nil
+}
+
+func parsePair(symbol, pair string) (Instrument, error) {
+	parts := strings.Split(pair, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return Instrument{}, fmt.Errorf("invalid symbol: %q", symbol)
+	}
+	return Instrument{Kind: InstrumentKindPair, Base: parts[0], Quote: parts[1]}, nil
+}
+
+// NewPairInstrument returns the pair of base and quote in mode. Its String
+// is the usual form of the symbol, which the exchange does not always use:
+// leverage currency pairs such as EUR/USD have no _LEVERAGE suffix. Use
+// SymbolRegistry.PairSymbol to find the symbol actually listed.
+func NewPairInstrument(base, quote string, mode InstrumentMode) Instrument {
+	return Instrument{Kind: InstrumentKindPair, Base: base, Quote: quote, Mode: mode, explicit: true}
+}
+
+// String returns the symbol of the instrument, see NewPairInstrument for pairs
+func (i Instrument) String() string {
+	switch i.Kind {
+	case InstrumentKindPair:
+		symbol := i.Base + "/" + i.Quote
+		if i.Mode == InstrumentModeLeverage && i.explicit {
+			symbol += leverageSuffix
+		}
+		return symbol
+	case InstrumentKindTicker:
+		return i.Base + "."
+	}
+	return i.Name
+}
+
+// Validate check that info is the symbol of the instrument and agrees with
+// its assets and mode
+func (i Instrument) Validate(info *ExchangeSymbolInfo) error {
+	if info.Symbol != i.String() {
+		return fmt.Errorf("symbol %s does not match instrument %s", info.Symbol, i)
+	}
+	if i.Kind == InstrumentKindPair && (info.BaseAsset != i.Base || info.QuoteAsset != i.Quote) {
+		return fmt.Errorf("%s trades %s/%s", info.Symbol, info.BaseAsset, info.QuoteAsset)
+	}
+	if i.Mode != "" && InstrumentMode(info.MarketType) != i.Mode {
+		return fmt.Errorf("%s is a %s instrument", info.Symbol, info.MarketType)
+	}
+	return nil
+}
+
+// PairSymbol returns the pair symbol of base and quote in mode listed in
+// the exchange info, e.g. EUR/USD for the leverage pair of EUR and USD
+func (r *SymbolRegistry) PairSymbol(base, quote string, mode InstrumentMode) (info *ExchangeSymbolInfo, ok bool) {
+	return r.symbolOf(base, quote, mode, true)
+}
+
+// symbolOf returns the symbol of base and quote in mode, a pair symbol if
+// there is one unless pairOnly is set
+func (r *SymbolRegistry) symbolOf(base, quote string, mode InstrumentMode, pairOnly bool) (info *ExchangeSymbolInfo, ok bool) {
+	for _, candidate := range r.ByBaseAsset(base) {
+		if candidate.QuoteAsset != quote || InstrumentMode(candidate.MarketType) != mode {
+			continue
+		}
+		if i, err := ParseInstrument(candidate.Symbol); err == nil && i.Kind == InstrumentKindPair {
+			return candidate, true
+		}
+		if !pairOnly && info == nil {
+			info = candidate
+		}
+	}
+	return info, info != nil
+}
+
+// Sibling returns the instrument of the same base and quote assets in the
+// other mode, e.g. BTC/USD for BTC/USD_LEVERAGE or Apple for AAPL.
+func (r *SymbolRegistry) Sibling(symbol string) (sibling *ExchangeSymbolInfo, ok bool) {
+	info, ok := r.Symbol(symbol)
+	if !ok {
+		return nil, false
+	}
+	mode := InstrumentModeLeverage
+	if info.MarketType == string(InstrumentModeLeverage) {
+		mode = InstrumentModeExchange
+	}
+	// a pair symbol is preferred if there are several
+	return r.symbolOf(info.BaseAsset, info.QuoteAsset, mode, false)
+}
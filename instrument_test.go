@@ -0,0 +1,130 @@
+package go_currencycom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type instrumentTestSuite struct {
+	baseTestSuite
+}
+
+func TestInstrument(t *testing.T) {
+	suite.Run(t, new(instrumentTestSuite))
+}
+
+func (s *instrumentTestSuite) TestParseInstrument() {
+	r := s.r()
+	tests := []struct {
+		symbol string
+		kind   InstrumentKind
+		base   string
+		quote  string
+		name   string
+		mode   InstrumentMode
+	}{
+		{"BTC/USD_LEVERAGE", InstrumentKindPair, "BTC", "USD", "", InstrumentModeLeverage},
+		{"BTC/USD", InstrumentKindPair, "BTC", "USD", "", ""},
+		{"EUR/USD", InstrumentKindPair, "EUR", "USD", "", ""},
+		{"AAPL.", InstrumentKindTicker, "AAPL", "", "", InstrumentModeExchange},
+		{"Apple", InstrumentKindName, "", "", "Apple", ""},
+		{"Crude Oil", InstrumentKindName, "", "", "Crude Oil", ""},
+	}
+	for _, test := range tests {
+		i, err := ParseInstrument(test.symbol)
+		r.NoError(err, test.symbol)
+		r.Equal(test.kind, i.Kind, test.symbol)
+		r.Equal(test.base, i.Base, test.symbol)
+		r.Equal(test.quote, i.Quote, test.symbol)
+		r.Equal(test.name, i.Name, test.symbol)
+		r.Equal(test.mode, i.Mode, test.symbol)
+		r.Equal(test.symbol, i.String())
+	}
+
+	for _, symbol := range []string{"", " BTC", "BTC/", "/USD", "BTC/USD/EUR", "_LEVERAGE", "BTC_LEVERAGE", ".", "A.B."} {
+		_, err := ParseInstrument(symbol)
+		r.EqualError(err, "invalid symbol: \""+symbol+"\"", symbol)
+	}
+}
+
+func (s *instrumentTestSuite) TestNewPairInstrument() {
+	r := s.r()
+	r.Equal("ETH/USD_LEVERAGE", NewPairInstrument("ETH", "USD", InstrumentModeLeverage).String())
+	r.Equal("ETH/USD", NewPairInstrument("ETH", "USD", InstrumentModeExchange).String())
+
+	i, err := ParseInstrument("ETH/USD_LEVERAGE")
+	r.NoError(err)
+	r.Equal(NewPairInstrument("ETH", "USD", InstrumentModeLeverage), i)
+}
+
+func (s *instrumentTestSuite) TestValidate() {
+	r := s.r()
+	info := &ExchangeSymbolInfo{Symbol: "BTC/USD_LEVERAGE", BaseAsset: "BTC", QuoteAsset: "USD", MarketType: "LEVERAGE"}
+	i, err := InstrumentOf(info)
+	r.NoError(err)
+	r.Equal(NewPairInstrument("BTC", "USD", InstrumentModeLeverage), i)
+
+	r.EqualError(NewPairInstrument("BTC", "USD", InstrumentModeExchange).Validate(info),
+		"symbol BTC/USD_LEVERAGE does not match instrument BTC/USD")
+	info.QuoteAsset = "EUR"
+	r.EqualError(i.Validate(info), "BTC/USD_LEVERAGE trades BTC/EUR")
+	info.QuoteAsset, info.MarketType = "USD", "SPOT"
+	r.EqualError(i.Validate(info), "BTC/USD_LEVERAGE is a SPOT instrument")
+
+	// leverage currency pairs have no suffix
+	i, err = InstrumentOf(&ExchangeSymbolInfo{Symbol: "EUR/USD", BaseAsset: "EUR", QuoteAsset: "USD", MarketType: "LEVERAGE"})
+	r.NoError(err)
+	r.Equal(InstrumentModeLeverage, i.Mode)
+	r.Equal("EUR/USD", i.String())
+
+	i, err = InstrumentOf(&ExchangeSymbolInfo{Symbol: "EVK", BaseAsset: "EVK", QuoteAsset: "EUR", MarketType: "SPOT"})
+	r.NoError(err)
+	r.Equal(InstrumentKindName, i.Kind)
+	r.Equal(InstrumentModeExchange, i.Mode)
+
+	_, err = InstrumentOf(&ExchangeSymbolInfo{Symbol: "AAPL.", BaseAsset: "AAPL", QuoteAsset: "USD", MarketType: "LEVERAGE"})
+	r.EqualError(err, "AAPL. is a LEVERAGE instrument")
+}
+
+func (s *instrumentTestSuite) TestSibling() {
+	registry, err := NewSymbolRegistry(s.client.Client, time.Hour, nil)
+	s.r().NoError(err)
+	registry.Set(&ExchangeInfo{Symbols: []ExchangeSymbolInfo{
+		{Symbol: "BTC/USD_LEVERAGE", BaseAsset: "BTC", QuoteAsset: "USD", MarketType: "LEVERAGE"},
+		{Symbol: "BTC/USD", BaseAsset: "BTC", QuoteAsset: "USD", MarketType: "SPOT"},
+		{Symbol: "BTC/EUR", BaseAsset: "BTC", QuoteAsset: "EUR", MarketType: "SPOT"},
+		{Symbol: "Apple", BaseAsset: "AAPL", QuoteAsset: "USD", MarketType: "LEVERAGE"},
+		{Symbol: "AAPL.", BaseAsset: "AAPL", QuoteAsset: "USD", MarketType: "SPOT"},
+		{Symbol: "EUR/USD", BaseAsset: "EUR", QuoteAsset: "USD", MarketType: "LEVERAGE"},
+	}})
+	r := s.r()
+	for symbol, sibling := range map[string]string{
+		"BTC/USD_LEVERAGE": "BTC/USD",
+		"BTC/USD":          "BTC/USD_LEVERAGE",
+		"Apple":            "AAPL.",
+		"AAPL.":            "Apple",
+	} {
+		info, ok := registry.Sibling(symbol)
+		r.True(ok, symbol)
+		r.Equal(sibling, info.Symbol, symbol)
+	}
+	_, ok := registry.Sibling("BTC/EUR")
+	r.False(ok)
+	_, ok = registry.Sibling("EUR/USD")
+	r.False(ok)
+	_, ok = registry.Sibling("ETH/USD")
+	r.False(ok)
+
+	// leverage currency pairs have no suffix: the symbol is read from the exchange info
+	r.Equal("EUR/USD_LEVERAGE", NewPairInstrument("EUR", "USD", InstrumentModeLeverage).String())
+	info, ok := registry.PairSymbol("EUR", "USD", InstrumentModeLeverage)
+	r.True(ok)
+	r.Equal("EUR/USD", info.Symbol)
+	info, ok = registry.PairSymbol("BTC", "USD", InstrumentModeLeverage)
+	r.True(ok)
+	r.Equal("BTC/USD_LEVERAGE", info.Symbol)
+	_, ok = registry.PairSymbol("AAPL", "USD", InstrumentModeLeverage)
+	r.False(ok)
+}
@@ -0,0 +1,237 @@
+package go_currencycom
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+const klineDownloaderPageLimit = 1000
+
+// KlineGap is a range of candles missing from a download
+type KlineGap struct {
+	Symbol string
+	// From is the open time of the first missing candle
+	From int64
+	// To is the open time of the candle following the gap
+	To      int64
+	Missing int
+	// MarketClosed is set when the gap is explained by the market being closed
+	MarketClosed bool
+}
+
+type KlineHandler func(kline *Kline)
+
+type KlineGapHandler func(gap KlineGap)
+
+// KlineDownloader download every kline of a symbol between two times,
+// paging through KlinesService and pausing between requests.
+type KlineDownloader struct {
+	c            *Client
+	symbol       string
+	interval     CandlestickInterval
+	startTime    int64
+	endTime      *int64
+	priceType    *KlinePriceType
+	ktype        *KlineType
+	gapHandler   KlineGapHandler
+	marketClosed func(from, to time.Time) bool
+	// PageLimit is the limit of each request, 1000 by default
+	PageLimit int
+	// MinInterval is the minimum time between two requests
+	MinInterval time.Duration
+	// Retries is the number of times a page is requested again after a
+	// network error, a server error or a rate limit
+	Retries int
+	// RetryDelay is the pause before the first retry, doubled on every retry
+	RetryDelay time.Duration
+}
+
+// Symbol set symbol
+func (d *KlineDownloader) Symbol(symbol string) *KlineDownloader {
+	d.symbol = symbol
+	return d
+}
+
+// Interval set interval
+func (d *KlineDownloader) Interval(interval CandlestickInterval) *KlineDownloader {
+	d.interval = interval
+	return d
+}
+
+// StartTime set start time
+func (d *KlineDownloader) StartTime(startTime int64) *KlineDownloader {
+	d.startTime = startTime
+	return d
+}
+
+// EndTime set end time, now by default
+func (d *KlineDownloader) EndTime(endTime int64) *KlineDownloader {
+	d.endTime = &endTime
+	return d
+}
+
+// PriceType set price type
+func (d *KlineDownloader) PriceType(priceType KlinePriceType) *KlineDownloader {
+	d.priceType = &priceType
+	return d
+}
+
+// Type set kline type
+func (d *KlineDownloader) Type(ktype KlineType) *KlineDownloader {
+	d.ktype = &ktype
+	return d
+}
+
+// GapHandler set the handler receiving the gaps between downloaded klines
+func (d *KlineDownloader) GapHandler(handler KlineGapHandler) *KlineDownloader {
+	d.gapHandler = handler
+	return d
+}
+
+// MarketClosed set the function telling whether the market was closed
+// during a gap, e.g. from the trading hours of the symbol
+func (d *KlineDownloader) MarketClosed(f func(from, to time.Time) bool) *KlineDownloader {
+	d.marketClosed = f
+	return d
+}
+
+// Do download the klines in ascending open time, without duplicates, and
+// pass them to handler. Missing klines are reported to the gap handler,
+// including before the first and after the last kline of the range.
+func (d *KlineDownloader) Do(ctx context.Context, handler KlineHandler, opts ...RequestOption) error {
+	if err := d.interval.Validate(); err != nil {
+		return err
+	}
+	stepMs := d.interval.Duration().Milliseconds()
+	endTime := time.Now().UnixMilli()
+	if d.endTime != nil {
+		endTime = *d.endTime
+	}
+
+	var last int64
+	started := false
+	var lastRequest time.Time
+	start := d.startTime
+	for start <= endTime {
+		if err := sleepContext(ctx, d.MinInterval-time.Since(lastRequest)); err != nil {
+			return err
+		}
+		lastRequest = time.Now()
+		klines, err := d.page(ctx, start, endTime, opts...)
+		if err != nil {
+			return err
+		}
+		progressed := false
+		for _, kline := range klines {
+			openTime := kline.OpenTime.UnixMilli()
+			if openTime < start || openTime > endTime || (started && openTime <= last) {
+				continue
+			}
+			if started && openTime > last+stepMs {
+				d.reportGap(last+stepMs, openTime, stepMs)
+			}
+			if !started && openTime-d.startTime >= stepMs {
+				// aligned on the first kline, as the start time may not be
+				d.reportGap(openTime-(openTime-d.startTime)/stepMs*stepMs, openTime, stepMs)
+			}
+			handler(kline)
+			last, started = openTime, true
+			progressed = true
+		}
+		if !progressed {
+			break
+		}
+		start = last + stepMs
+	}
+	if !started {
+		if missing := (endTime-d.startTime)/stepMs + 1; missing > 0 {
+			d.reportGap(d.startTime, d.startTime+missing*stepMs, stepMs)
+		}
+		return nil
+	}
+	if missing := (endTime - last) / stepMs; missing > 0 {
+		d.reportGap(last+stepMs, last+(missing+1)*stepMs, stepMs)
+	}
+	return nil
+}
+
+func (d *KlineDownloader) page(ctx context.Context, start, end int64, opts ...RequestOption) (klines []*Kline, err error) {
+	limit := d.PageLimit
+	if limit <= 0 {
+		limit = klineDownloaderPageLimit
+	}
+	delay := d.RetryDelay
+	for retry := 0; ; retry++ {
+		s := d.c.NewKlinesService().Symbol(d.symbol).Interval(d.interval).
+			StartTime(start).EndTime(end).Limit(limit)
+		if d.priceType != nil {
+			s.PriceType(*d.priceType)
+		}
+		if d.ktype != nil {
+			s.Type(*d.ktype)
+		}
+		klines, err = s.Do(ctx, opts...)
+		if err == nil || retry >= d.Retries || ctx.Err() != nil || !retryable(err) {
+			return klines, err
+		}
+		if err = sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+		delay *= 2
+	}
+}
+
+// retryable reports whether a request failing with err may succeed later:
+// network errors, server errors and rate limits
+func retryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= http.StatusInternalServerError || apiErr.StatusCode == http.StatusTooManyRequests
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+func (d *KlineDownloader) reportGap(from, to, stepMs int64) {
+	if d.gapHandler == nil {
+		return
+	}
+	gap := KlineGap{
+		Symbol:  d.symbol,
+		From:    from,
+		To:      to,
+		Missing: int((to - from) / stepMs),
+	}
+	if d.marketClosed != nil {
+		gap.MarketClosed = d.marketClosed(time.UnixMilli(from), time.UnixMilli(to))
+	}
+	d.gapHandler(gap)
+}
+
+// Stream download the klines in the background. The kline channel is closed
+// once the download is over, the error channel then receives its result.
+func (d *KlineDownloader) Stream(ctx context.Context, opts ...RequestOption) (klineC <-chan *Kline, errC <-chan error) {
+	kc := make(chan *Kline, klineDownloaderPageLimit)
+	ec := make(chan error, 1)
+	go func() {
+		defer close(ec)
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		err := d.Do(ctx, func(kline *Kline) {
+			select {
+			case kc <- kline:
+			case <-ctx.Done():
+			}
+		}, opts...)
+		if err == nil {
+			err = ctx.Err()
+		}
+		close(kc)
+		ec <- err
+	}()
+	return kc, ec
+}
@@ -0,0 +1,168 @@
+package go_currencycom
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type klineDownloaderTestSuite struct {
+	baseTestSuite
+	openTimes []int64
+	requests  []string
+	failures  int
+	// failure is the response of the failed requests
+	failure *http.Response
+	failErr error
+}
+
+func TestKlineDownloader(t *testing.T) {
+	suite.Run(t, new(klineDownloaderTestSuite))
+}
+
+func (s *klineDownloaderTestSuite) SetupTest() {
+	s.baseTestSuite.SetupTest()
+	s.requests = nil
+	s.failures = 0
+	s.failure = nil
+	s.failErr = nil
+	s.client.Client.do = s.serve
+}
+
+// serve answer the klines of openTimes, repeating the last kline of the
+// previous page as the server does when startTime is inclusive
+func (s *klineDownloaderTestSuite) serve(req *http.Request) (*http.Response, error) {
+	q := req.URL.Query()
+	s.requests = append(s.requests, q.Get("startTime"))
+	if s.failures > 0 {
+		s.failures--
+		if s.failErr != nil {
+			return nil, s.failErr
+		}
+		if s.failure != nil {
+			return s.failure, nil
+		}
+		return newHTTPResponse([]byte(`{"code":-1003,"msg":"Too many requests"}`), http.StatusTooManyRequests), nil
+	}
+	start, _ := strconv.ParseInt(q.Get("startTime"), 10, 64)
+	end, _ := strconv.ParseInt(q.Get("endTime"), 10, 64)
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	var rows []string
+	for _, t := range s.openTimes {
+		if t >= start-60000 && t <= end && len(rows) < limit {
+			rows = append(rows, fmt.Sprintf(`[%d,"1","2","0.5","1.5","10"]`, t))
+		}
+	}
+	return newHTTPResponse([]byte("["+strings.Join(rows, ",")+"]"), http.StatusOK), nil
+}
+
+func (s *klineDownloaderTestSuite) newDownloader() *KlineDownloader {
+	d := s.client.NewKlineDownloader().Symbol("BTC/USD").Interval(CandlestickInterval1m).
+		StartTime(0).EndTime(600000)
+	d.PageLimit = 3
+	d.MinInterval = 0
+	d.RetryDelay = time.Millisecond
+	return d
+}
+
+func (s *klineDownloaderTestSuite) TestDo() {
+	s.openTimes = []int64{0, 60000, 120000, 180000, 360000, 420000, 480000, 900000}
+	var gaps []KlineGap
+	var openTimes []int64
+	err := s.newDownloader().
+		GapHandler(func(gap KlineGap) {
+			gaps = append(gaps, gap)
+		}).
+		MarketClosed(func(from, to time.Time) bool {
+			return from.UnixMilli() >= 240000
+		}).
+		Do(newContext(), func(kline *Kline) {
+			openTimes = append(openTimes, kline.OpenTime.UnixMilli())
+		})
+	r := s.r()
+	r.NoError(err)
+	r.Equal([]int64{0, 60000, 120000, 180000, 360000, 420000, 480000}, openTimes)
+	r.Equal([]KlineGap{
+		{Symbol: "BTC/USD", From: 240000, To: 360000, Missing: 2, MarketClosed: true},
+		{Symbol: "BTC/USD", From: 540000, To: 660000, Missing: 2, MarketClosed: true},
+	}, gaps)
+	r.Equal([]string{"0", "180000", "420000", "540000"}, s.requests)
+}
+
+func (s *klineDownloaderTestSuite) TestRetry() {
+	s.openTimes = []int64{0}
+	s.failures = 2
+	var n int
+	err := s.newDownloader().Do(newContext(), func(kline *Kline) {
+		n++
+	})
+	s.r().NoError(err)
+	s.r().Equal(1, n)
+
+	s.failures = 4
+	err = s.newDownloader().Do(newContext(), func(kline *Kline) {})
+	s.r().True(IsAPIError(err))
+	s.r().Equal(http.StatusTooManyRequests, err.(*APIError).StatusCode)
+
+	// network errors are retried
+	s.failures = 2
+	s.failErr = &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+	s.r().NoError(s.newDownloader().Do(newContext(), func(kline *Kline) {}))
+
+	// client errors are not
+	s.requests = nil
+	s.failures = 1
+	s.failErr = nil
+	s.failure = newHTTPResponse([]byte(`{"code":-1121,"msg":"Invalid symbol."}`), http.StatusBadRequest)
+	err = s.newDownloader().Do(newContext(), func(kline *Kline) {})
+	s.r().True(IsAPIError(err))
+	s.r().Len(s.requests, 1)
+}
+
+func (s *klineDownloaderTestSuite) TestHeadAndTailGaps() {
+	s.openTimes = []int64{120000, 180000}
+	var gaps []KlineGap
+	err := s.newDownloader().StartTime(30000).EndTime(300000).
+		GapHandler(func(gap KlineGap) {
+			gaps = append(gaps, gap)
+		}).
+		Do(newContext(), func(kline *Kline) {})
+	r := s.r()
+	r.NoError(err)
+	r.Equal([]KlineGap{
+		{Symbol: "BTC/USD", From: 60000, To: 120000, Missing: 1},
+		{Symbol: "BTC/USD", From: 240000, To: 360000, Missing: 2},
+	}, gaps)
+
+	s.openTimes = nil
+	gaps = nil
+	r.NoError(s.newDownloader().EndTime(120000).
+		GapHandler(func(gap KlineGap) {
+			gaps = append(gaps, gap)
+		}).
+		Do(newContext(), func(kline *Kline) {}))
+	r.Equal([]KlineGap{{Symbol: "BTC/USD", From: 0, To: 180000, Missing: 3}}, gaps)
+}
+
+func (s *klineDownloaderTestSuite) TestStream() {
+	s.openTimes = []int64{0, 60000, 120000, 180000}
+	klineC, errC := s.newDownloader().Stream(newContext())
+	var n int
+	for range klineC {
+		n++
+	}
+	s.r().NoError(<-errC)
+	s.r().Equal(4, n)
+}
+
+func (s *klineDownloaderTestSuite) TestInvalidInterval() {
+	err := s.newDownloader().Interval(CandlestickInterval("1x")).Do(newContext(), func(kline *Kline) {})
+	s.r().Error(err)
+}
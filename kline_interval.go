@@ -0,0 +1,58 @@
+package go_currencycom
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Duration returns the length of the interval, 0 if it is not valid
+func (i CandlestickInterval) Duration() time.Duration {
+	if len(i) < 2 {
+		return 0
+	}
+	n, err := strconv.Atoi(string(i[:len(i)-1]))
+	if err != nil || n <= 0 {
+		return 0
+	}
+	var unit time.Duration
+	switch i[len(i)-1] {
+	case 's':
+		unit = time.Second
+	case 'm':
+		unit = time.Minute
+	case 'h':
+		unit = time.Hour
+	case 'd':
+		unit = 24 * time.Hour
+	case 'w':
+		unit = 7 * 24 * time.Hour
+	default:
+		return 0
+	}
+	return time.Duration(n) * unit
+}
+
+// Validate returns an error if the interval cannot be parsed
+func (i CandlestickInterval) Validate() error {
+	if i.Duration() <= 0 {
+		return fmt.Errorf("invalid kline interval: %q", string(i))
+	}
+	return nil
+}
+
+// Align returns the open time of the interval containing t, in UTC. Daily
+// intervals start at midnight and weekly intervals on Monday.
+func (i CandlestickInterval) Align(t time.Time) time.Time {
+	d := i.Duration()
+	if d <= 0 {
+		return t.UTC()
+	}
+	// the zero time is a Monday at midnight UTC
+	return t.UTC().Truncate(d)
+}
+
+// NextOpen returns the open time of the interval following the one containing t
+func (i CandlestickInterval) NextOpen(t time.Time) time.Time {
+	return i.Align(t).Add(i.Duration())
+}
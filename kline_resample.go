@@ -0,0 +1,163 @@
+package go_currencycom
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const oneDay = 24 * time.Hour
+
+// ResampledKline is a kline aggregated from klines of a shorter interval
+type ResampledKline struct {
+	Kline
+	// Count is the number of source klines in the bucket
+	Count int
+	// Partial is set when the bucket has fewer source klines than the
+	// intervals it spans: the series starts after its open time, ends before
+	// its close time or misses klines in between, e.g. while the market is
+	// closed
+	Partial bool
+}
+
+// ResampleKlines aggregate klines into buckets of d. Buckets shorter than a
+// day are aligned in UTC; daily and longer buckets start at midnight in loc,
+// and buckets of whole weeks on Monday. loc defaults to UTC. Klines with
+// the same open time are counted once, the last one is kept. The result is
+// empty if d is not positive, e.g. the Duration of an invalid interval.
+func ResampleKlines(klines []*Kline, d time.Duration, loc *time.Location) []*ResampledKline {
+	if d <= 0 {
+		return []*ResampledKline{}
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	byTime := make(map[int64]*Kline, len(klines))
+	for _, k := range klines {
+		byTime[k.OpenTime.UnixMilli()] = k
+	}
+	sorted := make([]*Kline, 0, len(byTime))
+	for _, k := range byTime {
+		sorted = append(sorted, k)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].OpenTime.Before(sorted[j].OpenTime)
+	})
+
+	res := make([]*ResampledKline, 0)
+	var cur *ResampledKline
+	for _, k := range sorted {
+		if cur == nil || !k.OpenTime.Before(cur.CloseTime) {
+			open, close := resampleBucket(k.OpenTime, d, loc)
+			cur = &ResampledKline{Kline: Kline{
+				OpenTime:  open,
+				CloseTime: close,
+				Open:      k.Open,
+				High:      k.High,
+				Low:       k.Low,
+			}}
+			res = append(res, cur)
+		}
+		if k.High > cur.High {
+			cur.High = k.High
+		}
+		if k.Low < cur.Low {
+			cur.Low = k.Low
+		}
+		cur.Close = k.Close
+		cur.Volume += k.Volume
+		cur.Count++
+	}
+	if step := sourceInterval(sorted); step > 0 {
+		for _, r := range res {
+			r.Partial = r.Count < int(r.CloseTime.Sub(r.OpenTime)/step)
+		}
+	}
+	return res
+}
+
+// sourceInterval returns the interval of sorted klines: the duration of the
+// first one, or the shortest time between two open times without close times
+func sourceInterval(sorted []*Kline) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	if step := sorted[0].CloseTime.Sub(sorted[0].OpenTime); step > 0 {
+		return step
+	}
+	var step time.Duration
+	for i := 1; i < len(sorted); i++ {
+		if diff := sorted[i].OpenTime.Sub(sorted[i-1].OpenTime); step == 0 || diff < step {
+			step = diff
+		}
+	}
+	return step
+}
+
+// resampleBucket returns the open and close times of the bucket of d containing t
+func resampleBucket(t time.Time, d time.Duration, loc *time.Location) (open, close time.Time) {
+	if d < oneDay || d%oneDay != 0 {
+		open = t.UTC().Truncate(d)
+		return open, open.Add(d)
+	}
+	n := int64(d / oneDay)
+	local := t.In(loc)
+	y, m, dd := local.Date()
+	// days since 1970-01-05, the first Monday after the epoch
+	days := time.Date(y, m, dd, 0, 0, 0, 0, time.UTC).Unix()/86400 - 4
+	offset := days % n
+	if offset < 0 {
+		offset += n
+	}
+	open = time.Date(y, m, dd-int(offset), 0, 0, 0, 0, loc)
+	return open, open.AddDate(0, 0, int(n))
+}
+
+// CompleteKlines returns the klines of the buckets fully covered by the source series
+func CompleteKlines(resampled []*ResampledKline) []*Kline {
+	res := make([]*Kline, 0, len(resampled))
+	for _, r := range resampled {
+		if !r.Partial {
+			k := r.Kline
+			res = append(res, &k)
+		}
+	}
+	return res
+}
+
+// Location returns the location of Timezone, UTC if it is empty
+func (e *ExchangeInfo) Location() (*time.Location, error) {
+	return parseLocation(e.Timezone)
+}
+
+// parseLocation parse an IANA time zone name or a fixed offset such as UTC+3 or GMT-05:30
+func parseLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	if loc, err := time.LoadLocation(name); err == nil {
+		return loc, nil
+	}
+	offset := strings.TrimPrefix(strings.TrimPrefix(name, "UTC"), "GMT")
+	if offset == name || len(offset) < 2 || (offset[0] != '+' && offset[0] != '-') {
+		return nil, fmt.Errorf("unknown time zone: %q", name)
+	}
+	hours, minutes, _ := strings.Cut(offset[1:], ":")
+	h, err := strconv.Atoi(hours)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone: %q", name)
+	}
+	var mm int
+	if minutes != "" {
+		if mm, err = strconv.Atoi(minutes); err != nil {
+			return nil, fmt.Errorf("unknown time zone: %q", name)
+		}
+	}
+	seconds := h*3600 + mm*60
+	if offset[0] == '-' {
+		seconds = -seconds
+	}
+	return time.FixedZone(name, seconds), nil
+}
@@ -0,0 +1,160 @@
+package go_currencycom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type klineResampleTestSuite struct {
+	suite.Suite
+}
+
+func TestKlineResample(t *testing.T) {
+	suite.Run(t, new(klineResampleTestSuite))
+}
+
+// hourly returns n hourly klines from start, the close of the i-th being i
+func (s *klineResampleTestSuite) hourly(start time.Time, n int) []*Kline {
+	klines := make([]*Kline, n)
+	for i := range klines {
+		open := start.Add(time.Duration(i) * time.Hour)
+		klines[i] = &Kline{
+			OpenTime:  open,
+			CloseTime: open.Add(time.Hour),
+			Open:      float64(i) - 0.5,
+			High:      float64(i) + 1,
+			Low:       float64(i) - 1,
+			Close:     float64(i),
+			Volume:    1,
+		}
+	}
+	return klines
+}
+
+func (s *klineResampleTestSuite) TestIntraday() {
+	start := time.Date(2023, 3, 13, 2, 0, 0, 0, time.UTC)
+	res := ResampleKlines(s.hourly(start, 7), CandlestickInterval4h.Duration(), nil)
+	r := s.Require()
+	r.Len(res, 3)
+
+	r.Equal(time.Date(2023, 3, 13, 0, 0, 0, 0, time.UTC), res[0].OpenTime)
+	r.True(res[0].Partial)
+	r.Equal(2, res[0].Count)
+
+	r.Equal(time.Date(2023, 3, 13, 4, 0, 0, 0, time.UTC), res[1].OpenTime)
+	r.Equal(time.Date(2023, 3, 13, 8, 0, 0, 0, time.UTC), res[1].CloseTime)
+	r.False(res[1].Partial)
+	r.Equal(4, res[1].Count)
+	r.Equal(1.5, res[1].Open)
+	r.Equal(6.0, res[1].High)
+	r.Equal(1.0, res[1].Low)
+	r.Equal(5.0, res[1].Close)
+	r.Equal(4.0, res[1].Volume)
+
+	r.True(res[2].Partial)
+	r.Equal(1, res[2].Count)
+
+	complete := CompleteKlines(res)
+	r.Len(complete, 1)
+	r.Equal(res[1].Kline, *complete[0])
+}
+
+func (s *klineResampleTestSuite) TestMissingAndDuplicates() {
+	start := time.Date(2023, 3, 13, 0, 0, 0, 0, time.UTC)
+	klines := s.hourly(start, 12)
+	// a kline missing inside the second bucket, the first bucket repeated
+	klines = append(klines[:5], klines[6:]...)
+	repeated := *klines[1]
+	repeated.Volume = 2
+	klines = append(klines, s.hourly(start, 4)[0], &repeated)
+	res := ResampleKlines(klines, CandlestickInterval4h.Duration(), nil)
+	r := s.Require()
+	r.Len(res, 3)
+	r.Equal(4, res[0].Count)
+	r.Equal(5.0, res[0].Volume)
+	r.False(res[0].Partial)
+	r.Equal(3, res[1].Count)
+	r.True(res[1].Partial)
+	r.False(res[2].Partial)
+	r.Len(CompleteKlines(res), 2)
+
+	// without close times the interval is the shortest between two klines
+	for _, k := range klines {
+		k.CloseTime = time.Time{}
+	}
+	res = ResampleKlines(klines, CandlestickInterval4h.Duration(), nil)
+	r.False(res[0].Partial)
+	r.True(res[1].Partial)
+}
+
+func (s *klineResampleTestSuite) TestDailyTimezone() {
+	loc := time.FixedZone("UTC+3", 3*3600)
+	// 2023-03-12 21:00 UTC is midnight of 2023-03-13 in UTC+3
+	start := time.Date(2023, 3, 12, 21, 0, 0, 0, time.UTC)
+	res := ResampleKlines(s.hourly(start, 48), CandlestickInterval1d.Duration(), loc)
+	r := s.Require()
+	r.Len(res, 2)
+	r.True(res[0].OpenTime.Equal(start))
+	r.Equal(24, res[0].Count)
+	r.False(res[0].Partial)
+	r.False(res[1].Partial)
+
+	res = ResampleKlines(s.hourly(start, 48), CandlestickInterval1d.Duration(), nil)
+	r.Len(res, 3)
+	r.Equal(3, res[0].Count)
+	r.True(res[0].Partial)
+}
+
+func (s *klineResampleTestSuite) TestWeekly() {
+	loc := time.FixedZone("UTC-5", -5*3600)
+	start := time.Date(2023, 3, 15, 0, 0, 0, 0, time.UTC)
+	res := ResampleKlines(s.hourly(start, 24*7), CandlestickInterval1w.Duration(), loc)
+	r := s.Require()
+	r.Len(res, 2)
+	r.True(res[0].OpenTime.Equal(time.Date(2023, 3, 13, 0, 0, 0, 0, loc)))
+	r.True(res[1].OpenTime.Equal(time.Date(2023, 3, 20, 0, 0, 0, 0, loc)))
+	r.Equal(24*7, res[0].Count+res[1].Count)
+	r.Empty(CompleteKlines(res))
+}
+
+func (s *klineResampleTestSuite) TestDST() {
+	loc, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		s.T().Skip("time zone database not available")
+	}
+	// clocks go forward on 2023-03-26, the day lasts 23 hours
+	start := time.Date(2023, 3, 26, 0, 0, 0, 0, time.UTC)
+	res := ResampleKlines(s.hourly(start, 23), CandlestickInterval1d.Duration(), loc)
+	s.Require().Len(res, 1)
+	s.Require().False(res[0].Partial)
+	s.Require().Equal(23*time.Hour, res[0].CloseTime.Sub(res[0].OpenTime))
+}
+
+func (s *klineResampleTestSuite) TestInvalidDuration() {
+	klines := s.hourly(time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC), 4)
+	s.Require().Empty(ResampleKlines(klines, 0, nil))
+	s.Require().Empty(ResampleKlines(klines, -time.Hour, nil))
+	s.Require().Empty(ResampleKlines(klines, CandlestickInterval("invalid").Duration(), nil))
+}
+
+func (s *klineResampleTestSuite) TestLocation() {
+	r := s.Require()
+	loc, err := (&ExchangeInfo{}).Location()
+	r.NoError(err)
+	r.Equal(time.UTC, loc)
+
+	loc, err = (&ExchangeInfo{Timezone: "UTC+3"}).Location()
+	r.NoError(err)
+	_, offset := time.Date(2023, 1, 1, 0, 0, 0, 0, loc).Zone()
+	r.Equal(3*3600, offset)
+
+	loc, err = parseLocation("GMT-05:30")
+	r.NoError(err)
+	_, offset = time.Date(2023, 1, 1, 0, 0, 0, 0, loc).Zone()
+	r.Equal(-(5*3600 + 30*60), offset)
+
+	_, err = parseLocation("Mars/Olympus")
+	r.Error(err)
+}
@@ -4,17 +4,22 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bitly/go-simplejson"
 )
 
 type KlinesService struct {
 	c         *Client
 	startTime *int64
 	endTime   *int64
-	interval  string
+	interval  CandlestickInterval
 	limit     *int
 	symbol    string
-	priceType *string
-	ktype     *string
+	priceType *KlinePriceType
+	ktype     *KlineType
+	store     KlineStore
 }
 
 func (s *KlinesService) StartTime(startTime int64) *KlinesService {
@@ -27,7 +32,7 @@ func (s *KlinesService) EndTime(endTime int64) *KlinesService {
 	return s
 }
 
-func (s *KlinesService) Interval(interval string) *KlinesService {
+func (s *KlinesService) Interval(interval CandlestickInterval) *KlinesService {
 	s.interval = interval
 	return s
 }
@@ -42,17 +47,70 @@ func (s *KlinesService) Symbol(symbol string) *KlinesService {
 	return s
 }
 
-func (s *KlinesService) PriceType(priceType string) *KlinesService {
+func (s *KlinesService) PriceType(priceType KlinePriceType) *KlinesService {
 	s.priceType = &priceType
 	return s
 }
 
-func (s *KlinesService) Type(ktype string) *KlinesService {
+func (s *KlinesService) Type(ktype KlineType) *KlinesService {
 	s.ktype = &ktype
 	return s
 }
 
+// Cache set a store used as a read-through cache for requests with both a
+// start and an end time: ranges covered by the store are not requested again
+func (s *KlinesService) Cache(store KlineStore) *KlinesService {
+	s.store = store
+	return s
+}
+
 func (s *KlinesService) Do(ctx context.Context, opts ...RequestOption) (res []*Kline, err error) {
+	if s.store == nil || s.startTime == nil || s.endTime == nil {
+		return s.do(ctx, opts...)
+	}
+	key := KlineStoreKey{Symbol: s.symbol, Interval: s.interval}
+	if s.priceType != nil {
+		key.PriceType = *s.priceType
+	}
+	if s.ktype != nil {
+		key.Type = *s.ktype
+	}
+	ranges, err := s.store.Coverage(key)
+	if err != nil {
+		return []*Kline{}, err
+	}
+	if len(klineCoverageGaps(ranges, *s.startTime, *s.endTime)) == 0 {
+		res, err = s.store.Klines(key, *s.startTime, *s.endTime)
+		if err == nil && s.limit != nil && len(res) > *s.limit {
+			res = res[:*s.limit]
+		}
+		return res, err
+	}
+
+	res, err = s.do(ctx, opts...)
+	if err != nil {
+		return res, err
+	}
+	final, err := klineFinalTime(s.interval, time.Now())
+	if err != nil {
+		return res, nil
+	}
+	to := *s.endTime
+	if len(res) > 0 && (s.limit == nil || len(res) >= *s.limit) {
+		// the page may have stopped before the end time, at the limit or
+		// at the default limit of the server: the range is only known up
+		// to the last kline
+		if last := res[len(res)-1].OpenTime.UnixMilli() + s.interval.Duration().Milliseconds() - 1; last < to {
+			to = last
+		}
+	}
+	if to > final {
+		to = final
+	}
+	return res, s.store.Save(key, res, KlineCoverage{From: *s.startTime, To: to})
+}
+
+func (s *KlinesService) do(ctx context.Context, opts ...RequestOption) (res []*Kline, err error) {
 	r := &request{
 		method:   http.MethodGet,
 		endpoint: "api/v2/klines",
@@ -91,24 +149,47 @@ func (s *KlinesService) Do(ctx context.Context, opts ...RequestOption) (res []*K
 			err = fmt.Errorf("invalid kline data: %s", item)
 			return []*Kline{}, err
 		}
+		var values [5]float64
+		for k := range values {
+			values[k], err = jsonFloat64(item.GetIndex(k + 1))
+			if err != nil {
+				return []*Kline{}, fmt.Errorf("invalid kline data: %s", item)
+			}
+		}
+		openTime := time.UnixMilli(item.GetIndex(0).MustInt64()).UTC()
 		res[i] = &Kline{
-			OpenTime: item.GetIndex(0).MustInt64(),
-			Open:     item.GetIndex(1).MustString(),
-			High:     item.GetIndex(2).MustString(),
-			Low:      item.GetIndex(3).MustString(),
-			Close:    item.GetIndex(4).MustString(),
-			Volume:   item.GetIndex(5).MustString(),
+			OpenTime:  openTime,
+			CloseTime: openTime.Add(s.interval.Duration()),
+			Open:      values[0],
+			High:      values[1],
+			Low:       values[2],
+			Close:     values[3],
+			Volume:    values[4],
 		}
 	}
 	return res, nil
 }
 
+// jsonFloat64 read a number sent either as a JSON number or as a string
+func jsonFloat64(j *simplejson.Json) (float64, error) {
+	if v, err := j.Float64(); err == nil {
+		return v, nil
+	}
+	v, err := j.String()
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(v, 64)
+}
+
 // Kline define kline info
 type Kline struct {
-	OpenTime int64  `json:"openTime"`
-	Open     string `json:"open"`
-	High     string `json:"high"`
-	Low      string `json:"low"`
-	Close    string `json:"close"`
-	Volume   string `json:"volume"`
+	OpenTime time.Time `json:"openTime"`
+	// CloseTime is the end of the interval, the open time of the next kline
+	CloseTime time.Time `json:"closeTime"`
+	Open      float64   `json:"open"`
+	High      float64   `json:"high"`
+	Low       float64   `json:"low"`
+	Close     float64   `json:"close"`
+	Volume    float64   `json:"volume"`
 }
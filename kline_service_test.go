@@ -3,6 +3,7 @@ package go_currencycom
 import (
 	"github.com/stretchr/testify/suite"
 	"testing"
+	"time"
 )
 
 type klineServiceTestSuite struct {
@@ -36,12 +37,12 @@ func (s *klineServiceTestSuite) TestKline() {
 	defer s.assertDo()
 
 	symbol := "BTC/USD_LEVERAGE"
-	interval := "1m"
+	interval := CandlestickInterval1m
 	limit := 10
 	startTime := int64(1499040000000)
 	endTime := int64(1499040000001)
-	priceType := "spot"
-	ktype := "kline"
+	priceType := KlinePriceTypeBid
+	ktype := KlineTypeHeikinAshi
 	s.assertReq(func(r *request) {
 		e := newRequest().setParams(params{
 			"symbol":    symbol,
@@ -65,20 +66,22 @@ func (s *klineServiceTestSuite) TestKline() {
 	r.Len(klines, 2)
 	ansKlines := []*Kline{
 		{
-			OpenTime: 1499040000000,
-			Open:     "0.01634790",
-			High:     "0.80000000",
-			Low:      "0.01575800",
-			Close:    "0.01577100",
-			Volume:   "148976.11427815",
+			OpenTime:  time.UnixMilli(1499040000000).UTC(),
+			CloseTime: time.UnixMilli(1499040060000).UTC(),
+			Open:      0.01634790,
+			High:      0.80000000,
+			Low:       0.01575800,
+			Close:     0.01577100,
+			Volume:    148976.11427815,
 		},
 		{
-			OpenTime: 1499040000001,
-			Open:     "0.01634790",
-			High:     "0.80000000",
-			Low:      "0.01575800",
-			Close:    "0.01577101",
-			Volume:   "148976.11427815",
+			OpenTime:  time.UnixMilli(1499040000001).UTC(),
+			CloseTime: time.UnixMilli(1499040060001).UTC(),
+			Open:      0.01634790,
+			High:      0.80000000,
+			Low:       0.01575800,
+			Close:     0.01577101,
+			Volume:    148976.11427815,
 		},
 	}
 	for i := range klines {
@@ -89,9 +92,50 @@ func (s *klineServiceTestSuite) TestKline() {
 func (s *klineServiceTestSuite) assertKlineEqual(e, a *Kline) {
 	r := s.r()
 	r.Equal(e.OpenTime, a.OpenTime, "OpenTime")
+	r.Equal(e.CloseTime, a.CloseTime, "CloseTime")
 	r.Equal(e.Open, a.Open, "Open")
 	r.Equal(e.High, a.High, "High")
 	r.Equal(e.Low, a.Low, "Low")
 	r.Equal(e.Close, a.Close, "Close")
 	r.Equal(e.Volume, a.Volume, "Volume")
 }
+
+func (s *klineServiceTestSuite) TestKlineNumbers() {
+	data := []byte(`[[1499040000000, 1.5, 2, 1, 1.75, 10]]`)
+	s.mockDo(data, nil)
+	defer s.assertDo()
+	klines, err := s.client.NewKlinesService().Symbol("BTC/USD_LEVERAGE").
+		Interval(CandlestickInterval1h).Do(newContext())
+	r := s.r()
+	r.NoError(err)
+	r.Len(klines, 1)
+	r.Equal(1.75, klines[0].Close)
+	r.Equal(time.UnixMilli(1499043600000).UTC(), klines[0].CloseTime)
+}
+
+func (s *klineServiceTestSuite) TestInvalidKline() {
+	data := []byte(`[[1499040000000, "x", "2", "1", "1.75", "10"]]`)
+	s.mockDo(data, nil)
+	defer s.assertDo()
+	_, err := s.client.NewKlinesService().Symbol("BTC/USD_LEVERAGE").
+		Interval(CandlestickInterval1h).Do(newContext())
+	s.r().Error(err)
+}
+
+func (s *klineServiceTestSuite) TestCandlestickInterval() {
+	r := s.r()
+	r.Equal(15*time.Minute, CandlestickInterval15m.Duration())
+	r.Equal(7*24*time.Hour, CandlestickInterval1w.Duration())
+	r.Zero(CandlestickInterval("1y").Duration())
+	r.Error(CandlestickInterval("m").Validate())
+	r.NoError(CandlestickInterval("10s").Validate())
+
+	t := time.Date(2023, 3, 16, 13, 47, 12, 0, time.FixedZone("UTC+2", 2*3600))
+	r.Equal(time.Date(2023, 3, 16, 11, 45, 0, 0, time.UTC), CandlestickInterval15m.Align(t))
+	r.Equal(time.Date(2023, 3, 16, 8, 0, 0, 0, time.UTC), CandlestickInterval4h.Align(t))
+	r.Equal(time.Date(2023, 3, 16, 0, 0, 0, 0, time.UTC), CandlestickInterval1d.Align(t))
+	// weeks open on Monday
+	r.Equal(time.Date(2023, 3, 13, 0, 0, 0, 0, time.UTC), CandlestickInterval1w.Align(t))
+	r.Equal(time.Date(2023, 3, 20, 0, 0, 0, 0, time.UTC), CandlestickInterval1w.NextOpen(t))
+	r.Equal(time.Date(2023, 3, 16, 12, 0, 0, 0, time.UTC), CandlestickInterval1h.NextOpen(t))
+}
@@ -0,0 +1,397 @@
+package go_currencycom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KlineStoreKey identify a kline series in a KlineStore
+type KlineStoreKey struct {
+	Symbol    string
+	Interval  CandlestickInterval
+	PriceType KlinePriceType
+	Type      KlineType
+}
+
+// KlineCoverage is the range of open times of a series known to be complete
+type KlineCoverage struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+// KlineStore persist kline series
+type KlineStore interface {
+	// Klines returns the stored klines of key opened between startTime and endTime
+	Klines(key KlineStoreKey, startTime, endTime int64) ([]*Kline, error)
+	// Save replace the stored klines with the same open time and add the
+	// range the klines were downloaded for to the coverage of key
+	Save(key KlineStoreKey, klines []*Kline, coverage KlineCoverage) error
+	// Coverage returns the complete ranges of key sorted by time, empty if
+	// nothing was saved. Overlapping and adjacent ranges are merged.
+	Coverage(key KlineStoreKey) ([]KlineCoverage, error)
+}
+
+// klineSeries is the stored form of a series
+type klineSeries struct {
+	Coverage []KlineCoverage `json:"coverage,omitempty"`
+	Klines   []*Kline        `json:"klines"`
+}
+
+func (s *klineSeries) merge(klines []*Kline, coverage KlineCoverage, step int64) {
+	s.mergeKlines(klines)
+	s.mergeCoverage(coverage, step)
+}
+
+func (s *klineSeries) mergeKlines(klines []*Kline) {
+	byTime := make(map[int64]*Kline, len(s.Klines)+len(klines))
+	for _, kline := range s.Klines {
+		byTime[kline.OpenTime.UnixMilli()] = kline
+	}
+	for _, kline := range klines {
+		byTime[kline.OpenTime.UnixMilli()] = kline
+	}
+	s.Klines = make([]*Kline, 0, len(byTime))
+	for _, kline := range byTime {
+		s.Klines = append(s.Klines, kline)
+	}
+	sort.Slice(s.Klines, func(i, j int) bool {
+		return s.Klines[i].OpenTime.Before(s.Klines[j].OpenTime)
+	})
+}
+
+// mergeCoverage add a range to the coverage, merging the ranges that
+// overlap or that are less than step apart, i.e. without missing open time
+func (s *klineSeries) mergeCoverage(coverage KlineCoverage, step int64) {
+	if coverage.From > coverage.To {
+		return
+	}
+	if step < 1 {
+		step = 1
+	}
+	ranges := append(append(make([]KlineCoverage, 0, len(s.Coverage)+1), s.Coverage...), coverage)
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].From < ranges[j].From
+	})
+	s.Coverage = ranges[:1:1]
+	for _, r := range ranges[1:] {
+		last := &s.Coverage[len(s.Coverage)-1]
+		if r.From > last.To+step {
+			s.Coverage = append(s.Coverage, r)
+			continue
+		}
+		if r.To > last.To {
+			last.To = r.To
+		}
+	}
+}
+
+// klineCoverageGaps returns the parts of the range between startTime and
+// endTime out of the sorted ranges
+func klineCoverageGaps(ranges []KlineCoverage, startTime, endTime int64) []KlineCoverage {
+	gaps := make([]KlineCoverage, 0)
+	for _, r := range ranges {
+		if startTime > endTime {
+			break
+		}
+		if r.To < startTime {
+			continue
+		}
+		if r.From > endTime {
+			break
+		}
+		if r.From > startTime {
+			gaps = append(gaps, KlineCoverage{From: startTime, To: r.From - 1})
+		}
+		startTime = r.To + 1
+	}
+	if startTime <= endTime {
+		gaps = append(gaps, KlineCoverage{From: startTime, To: endTime})
+	}
+	return gaps
+}
+
+func (s *klineSeries) between(startTime, endTime int64) []*Kline {
+	i := sort.Search(len(s.Klines), func(i int) bool {
+		return s.Klines[i].OpenTime.UnixMilli() >= startTime
+	})
+	res := make([]*Kline, 0)
+	for ; i < len(s.Klines) && s.Klines[i].OpenTime.UnixMilli() <= endTime; i++ {
+		kline := *s.Klines[i]
+		res = append(res, &kline)
+	}
+	return res
+}
+
+// MemoryKlineStore is a KlineStore kept in memory
+type MemoryKlineStore struct {
+	mu     sync.RWMutex
+	series map[KlineStoreKey]*klineSeries
+}
+
+// NewMemoryKlineStore init an empty store
+func NewMemoryKlineStore() *MemoryKlineStore {
+	return &MemoryKlineStore{series: make(map[KlineStoreKey]*klineSeries)}
+}
+
+// Klines implements KlineStore
+func (m *MemoryKlineStore) Klines(key KlineStoreKey, startTime, endTime int64) ([]*Kline, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	series, ok := m.series[key]
+	if !ok {
+		return []*Kline{}, nil
+	}
+	return series.between(startTime, endTime), nil
+}
+
+// Save implements KlineStore
+func (m *MemoryKlineStore) Save(key KlineStoreKey, klines []*Kline, coverage KlineCoverage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	series, ok := m.series[key]
+	if !ok {
+		series = new(klineSeries)
+		m.series[key] = series
+	}
+	series.merge(klines, coverage, key.Interval.Duration().Milliseconds())
+	return nil
+}
+
+// Coverage implements KlineStore
+func (m *MemoryKlineStore) Coverage(key KlineStoreKey) ([]KlineCoverage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	series, ok := m.series[key]
+	if !ok {
+		return []KlineCoverage{}, nil
+	}
+	return append([]KlineCoverage{}, series.Coverage...), nil
+}
+
+// FileKlineStore is a KlineStore writing a directory per series, holding a
+// JSON file of klines per month of open time (UTC) and a coverage file, so
+// that saving or reading a range only touches the months it spans.
+type FileKlineStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+const (
+	klineCoverageFile = "coverage.json"
+	klineMonthLayout  = "2006-01"
+)
+
+// NewFileKlineStore init a store in dir, creating it if needed
+func NewFileKlineStore(dir string) (*FileKlineStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileKlineStore{dir: dir}, nil
+}
+
+func (f *FileKlineStore) path(key KlineStoreKey) string {
+	name := fmt.Sprintf("%s_%s_%s_%s", url.PathEscape(key.Symbol), url.PathEscape(string(key.Interval)),
+		url.PathEscape(string(key.PriceType)), url.PathEscape(string(key.Type)))
+	return filepath.Join(f.dir, name)
+}
+
+// load read a file of a series, an empty series if it does not exist
+func (f *FileKlineStore) load(path string) (*klineSeries, error) {
+	series := new(klineSeries)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return series, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, series); err != nil {
+		return nil, err
+	}
+	return series, nil
+}
+
+// write replace a file of a series atomically
+func (f *FileKlineStore) write(path string, series *klineSeries) error {
+	data, err := json.Marshal(series)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".klines-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Klines implements KlineStore, reading the months between startTime and endTime
+func (f *FileKlineStore) Klines(key KlineStoreKey, startTime, endTime int64) ([]*Kline, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	dir := f.path(key)
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []*Kline{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*Kline, 0)
+	// the entries are sorted by name, hence by month
+	for _, entry := range entries {
+		month, err := time.Parse(klineMonthLayout, strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			continue
+		}
+		if month.AddDate(0, 1, 0).UnixMilli() <= startTime || month.UnixMilli() > endTime {
+			continue
+		}
+		series, err := f.load(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, series.between(startTime, endTime)...)
+	}
+	return res, nil
+}
+
+// Save implements KlineStore. The files of the months of klines are
+// replaced atomically, then the coverage.
+func (f *FileKlineStore) Save(key KlineStoreKey, klines []*Kline, coverage KlineCoverage) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	dir := f.path(key)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	months := make(map[string][]*Kline)
+	for _, kline := range klines {
+		month := kline.OpenTime.UTC().Format(klineMonthLayout)
+		months[month] = append(months[month], kline)
+	}
+	for month, klines := range months {
+		path := filepath.Join(dir, month+".json")
+		series, err := f.load(path)
+		if err != nil {
+			return err
+		}
+		series.mergeKlines(klines)
+		if err = f.write(path, series); err != nil {
+			return err
+		}
+	}
+	path := filepath.Join(dir, klineCoverageFile)
+	series, err := f.load(path)
+	if err != nil {
+		return err
+	}
+	series.mergeCoverage(coverage, key.Interval.Duration().Milliseconds())
+	return f.write(path, series)
+}
+
+// Coverage implements KlineStore
+func (f *FileKlineStore) Coverage(key KlineStoreKey) ([]KlineCoverage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	series, err := f.load(filepath.Join(f.path(key), klineCoverageFile))
+	if err != nil {
+		return []KlineCoverage{}, err
+	}
+	return append([]KlineCoverage{}, series.Coverage...), nil
+}
+
+// klineFinalTime returns the latest open time of a kline that can no longer change
+func klineFinalTime(interval CandlestickInterval, now time.Time) (int64, error) {
+	if err := interval.Validate(); err != nil {
+		return 0, err
+	}
+	return now.Add(-interval.Duration()).UnixMilli(), nil
+}
+
+// SyncKlineStore download the klines of key missing from store between
+// startTime and now, i.e. out of its coverage. Each page is saved with its
+// range as it arrives, so that an interrupted sync resumes where it stopped.
+// It returns the number of klines downloaded.
+func SyncKlineStore(ctx context.Context, c *Client, store KlineStore, key KlineStoreKey, startTime int64, opts ...RequestOption) (n int, err error) {
+	ranges, err := store.Coverage(key)
+	if err != nil {
+		return 0, err
+	}
+	endTime, err := klineFinalTime(key.Interval, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	for _, gap := range klineCoverageGaps(ranges, startTime, endTime) {
+		downloaded, err := syncKlineRange(ctx, c, store, key, gap.From, gap.To, opts...)
+		n += downloaded
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// syncKlineRange download the klines of key between startTime and endTime
+// into store, saving them a page at a time
+func syncKlineRange(ctx context.Context, c *Client, store KlineStore, key KlineStoreKey, startTime, endTime int64, opts ...RequestOption) (n int, err error) {
+	d := c.NewKlineDownloader().Symbol(key.Symbol).Interval(key.Interval).
+		StartTime(startTime).EndTime(endTime)
+	if key.PriceType != "" {
+		d.PriceType(key.PriceType)
+	}
+	if key.Type != "" {
+		d.Type(key.Type)
+	}
+	pageLimit := d.PageLimit
+	if pageLimit <= 0 {
+		pageLimit = klineDownloaderPageLimit
+	}
+	// the downloader is stopped if a page cannot be saved
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var saveErr error
+	page := make([]*Kline, 0, pageLimit)
+	err = d.Do(ctx, func(kline *Kline) {
+		if saveErr != nil {
+			return
+		}
+		page = append(page, kline)
+		if len(page) < pageLimit {
+			return
+		}
+		// the range is only known to be complete up to the last kline
+		saveErr = store.Save(key, page, KlineCoverage{From: startTime, To: kline.OpenTime.UnixMilli()})
+		if saveErr != nil {
+			cancel()
+			return
+		}
+		n += len(page)
+		page = make([]*Kline, 0, pageLimit)
+	}, opts...)
+	if saveErr != nil {
+		return n, saveErr
+	}
+	if err != nil {
+		return n, err
+	}
+	if err = store.Save(key, page, KlineCoverage{From: startTime, To: endTime}); err != nil {
+		return n, err
+	}
+	return n + len(page), nil
+}
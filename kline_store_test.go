@@ -0,0 +1,224 @@
+package go_currencycom
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type klineStoreTestSuite struct {
+	baseTestSuite
+	requests int
+	// failAt, if set, is the request answered with a server error
+	failAt int
+	starts []int64
+}
+
+func TestKlineStore(t *testing.T) {
+	suite.Run(t, new(klineStoreTestSuite))
+}
+
+func (s *klineStoreTestSuite) SetupTest() {
+	s.baseTestSuite.SetupTest()
+	s.requests = 0
+	s.failAt = 0
+	s.starts = nil
+	s.client.Client.do = s.serve
+}
+
+// serve answer a kline every minute between startTime and endTime
+func (s *klineStoreTestSuite) serve(req *http.Request) (*http.Response, error) {
+	s.requests++
+	if s.requests == s.failAt {
+		return newHTTPResponse([]byte(`{"code":-1000,"msg":"unknown error"}`), http.StatusBadRequest), nil
+	}
+	q := req.URL.Query()
+	start, _ := strconv.ParseInt(q.Get("startTime"), 10, 64)
+	s.starts = append(s.starts, start)
+	end, _ := strconv.ParseInt(q.Get("endTime"), 10, 64)
+	limit, err := strconv.Atoi(q.Get("limit"))
+	if err != nil {
+		limit = 1000
+	}
+	var rows []string
+	for t := start - start%60000; t <= end && len(rows) < limit; t += 60000 {
+		if t >= start {
+			rows = append(rows, fmt.Sprintf(`[%d,"1","2","0.5","1.5","10"]`, t))
+		}
+	}
+	return newHTTPResponse([]byte("["+strings.Join(rows, ",")+"]"), http.StatusOK), nil
+}
+
+func ms(t int64) time.Time {
+	return time.UnixMilli(t).UTC()
+}
+
+func (s *klineStoreTestSuite) key() KlineStoreKey {
+	return KlineStoreKey{Symbol: "BTC/USD_LEVERAGE", Interval: CandlestickInterval1m, PriceType: KlinePriceTypeBid}
+}
+
+func (s *klineStoreTestSuite) assertStore(store KlineStore) {
+	r := s.r()
+	key := s.key()
+	coverage, err := store.Coverage(key)
+	r.NoError(err)
+	r.Empty(coverage)
+
+	r.NoError(store.Save(key, []*Kline{{OpenTime: ms(120000), Close: 1}, {OpenTime: ms(60000)}}, KlineCoverage{From: 60000, To: 120000}))
+	r.NoError(store.Save(key, []*Kline{{OpenTime: ms(120000), Close: 2}, {OpenTime: ms(180000)}}, KlineCoverage{From: 120000, To: 180000}))
+	// disjoint ranges are kept apart
+	r.NoError(store.Save(key, []*Kline{{OpenTime: ms(600000)}}, KlineCoverage{From: 600000, To: 600000}))
+	coverage, err = store.Coverage(key)
+	r.NoError(err)
+	r.Equal([]KlineCoverage{{From: 60000, To: 180000}, {From: 600000, To: 600000}}, coverage)
+
+	// a range adjacent to a known one, one interval later, extends it
+	r.NoError(store.Save(key, []*Kline{{OpenTime: ms(240000)}}, KlineCoverage{From: 240000, To: 240000}))
+	coverage, err = store.Coverage(key)
+	r.NoError(err)
+	r.Equal([]KlineCoverage{{From: 60000, To: 240000}, {From: 600000, To: 600000}}, coverage)
+
+	klines, err := store.Klines(key, 60000, 180000)
+	r.NoError(err)
+	r.Equal([]*Kline{{OpenTime: ms(60000)}, {OpenTime: ms(120000), Close: 2}, {OpenTime: ms(180000)}}, klines)
+	klines, err = store.Klines(key, 600000, 600000)
+	r.NoError(err)
+	r.Equal([]*Kline{{OpenTime: ms(600000)}}, klines)
+
+	other := key
+	other.PriceType = KlinePriceTypeAsk
+	klines, err = store.Klines(other, 0, 600000)
+	r.NoError(err)
+	r.Empty(klines)
+}
+
+func (s *klineStoreTestSuite) TestMemoryStore() {
+	s.assertStore(NewMemoryKlineStore())
+}
+
+func (s *klineStoreTestSuite) TestFileStore() {
+	dir := s.T().TempDir()
+	store, err := NewFileKlineStore(dir)
+	s.r().NoError(err)
+	s.assertStore(store)
+
+	// a new store reads the same files
+	store, err = NewFileKlineStore(dir)
+	s.r().NoError(err)
+	klines, err := store.Klines(s.key(), 0, 1000000)
+	s.r().NoError(err)
+	s.r().Len(klines, 5)
+
+	// klines are written in a file per month
+	jan, feb := time.Date(2024, 1, 31, 23, 59, 0, 0, time.UTC), time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	r := s.r()
+	r.NoError(store.Save(s.key(), []*Kline{{OpenTime: jan}, {OpenTime: feb}},
+		KlineCoverage{From: jan.UnixMilli(), To: feb.UnixMilli()}))
+	entries, err := os.ReadDir(store.path(s.key()))
+	r.NoError(err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	r.Equal([]string{"1970-01.json", "2024-01.json", "2024-02.json", "coverage.json"}, names)
+	klines, err = store.Klines(s.key(), feb.UnixMilli(), feb.UnixMilli())
+	r.NoError(err)
+	r.Equal([]*Kline{{OpenTime: feb}}, klines)
+}
+
+func (s *klineStoreTestSuite) TestSync() {
+	store := NewMemoryKlineStore()
+	start := time.Now().Add(-10 * time.Minute).UnixMilli()
+	n, err := SyncKlineStore(newContext(), s.client.Client, store, s.key(), start)
+	r := s.r()
+	r.NoError(err)
+	r.True(n >= 8 && n <= 10, n)
+
+	first := s.requests
+	n, err = SyncKlineStore(newContext(), s.client.Client, store, s.key(), start)
+	r.NoError(err)
+	r.True(n <= 2, n)
+	// at most the klines closed since the first sync are requested
+	r.LessOrEqual(s.requests, first+1)
+
+	// only the ranges out of the coverage are downloaded
+	coverage, _ := store.Coverage(s.key())
+	first = s.requests
+	n, err = SyncKlineStore(newContext(), s.client.Client, store, s.key(), start-5*60000)
+	r.NoError(err)
+	r.True(n >= 5 && n <= 8, n)
+	r.LessOrEqual(s.requests, first+2)
+	extended, _ := store.Coverage(s.key())
+	r.Len(extended, 1)
+	r.Equal(start-5*60000, extended[0].From)
+	r.True(extended[0].To >= coverage[0].To)
+}
+
+func (s *klineStoreTestSuite) TestSyncResumes() {
+	store := NewMemoryKlineStore()
+	start := time.Now().Add(-2500 * time.Minute).UnixMilli()
+	// the second page fails, the first one is kept
+	s.failAt = 2
+	n, err := SyncKlineStore(newContext(), s.client.Client, store, s.key(), start)
+	r := s.r()
+	r.Error(err)
+	r.Equal(1000, n)
+	coverage, err := store.Coverage(s.key())
+	r.NoError(err)
+	r.Len(coverage, 1)
+	r.Equal(start, coverage[0].From)
+	last := coverage[0].To
+
+	s.starts = nil
+	n, err = SyncKlineStore(newContext(), s.client.Client, store, s.key(), start)
+	r.NoError(err)
+	r.True(n >= 1498 && n <= 1500, n)
+	// the rerun starts after the last saved kline
+	r.Equal(last+1, s.starts[0])
+	klines, err := store.Klines(s.key(), start, time.Now().UnixMilli())
+	r.NoError(err)
+	r.True(len(klines) >= 2498 && len(klines) <= 2500, len(klines))
+}
+
+func (s *klineStoreTestSuite) TestReadThroughCache() {
+	store := NewMemoryKlineStore()
+	klines, err := s.client.NewKlinesService().Symbol("BTC/USD_LEVERAGE").Interval(CandlestickInterval1m).PriceType(KlinePriceTypeBid).
+		StartTime(0).EndTime(300000).Cache(store).Do(newContext())
+	r := s.r()
+	r.NoError(err)
+	r.Len(klines, 6)
+	r.Equal(1, s.requests)
+
+	klines, err = s.client.NewKlinesService().Symbol("BTC/USD_LEVERAGE").Interval(CandlestickInterval1m).PriceType(KlinePriceTypeBid).
+		StartTime(60000).EndTime(180000).Limit(2).Cache(store).Do(newContext())
+	r.NoError(err)
+	r.Equal(1, s.requests)
+	r.Len(klines, 2)
+	r.Equal(ms(60000), klines[0].OpenTime)
+	r.Equal(1.5, klines[1].Close)
+
+	_, err = s.client.NewKlinesService().Symbol("BTC/USD_LEVERAGE").Interval(CandlestickInterval1m).PriceType(KlinePriceTypeBid).
+		StartTime(240000).EndTime(420000).Cache(store).Do(newContext())
+	r.NoError(err)
+	r.Equal(2, s.requests)
+	coverage, _ := store.Coverage(s.key())
+	r.Equal([]KlineCoverage{{From: 0, To: 420000}}, coverage)
+}
+
+func (s *klineStoreTestSuite) TestReadThroughCacheTruncated() {
+	// without a limit the server answers 1000 klines at most
+	store := NewMemoryKlineStore()
+	klines, err := s.client.NewKlinesService().Symbol("BTC/USD_LEVERAGE").Interval(CandlestickInterval1m).PriceType(KlinePriceTypeBid).
+		StartTime(0).EndTime(2000 * 60000).Cache(store).Do(newContext())
+	r := s.r()
+	r.NoError(err)
+	r.Len(klines, 1000)
+	coverage, _ := store.Coverage(s.key())
+	r.Equal([]KlineCoverage{{From: 0, To: 1000*60000 - 1}}, coverage)
+}
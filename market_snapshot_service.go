@@ -0,0 +1,263 @@
+package go_currencycom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultMarketSnapshotConcurrency = 8
+
+// MarketSnapshot is the depth and the latest kline of a symbol
+type MarketSnapshot struct {
+	Symbol string
+	Depth  *DepthResponse
+	Kline  *Kline
+	Err    error
+	// TimedOut is set when the deadline passed before the symbol was fetched
+	TimedOut bool
+}
+
+// MarketSnapshotError lists the symbols a MarketSnapshotService failed to fetch
+type MarketSnapshotError struct {
+	Errors map[string]error
+}
+
+// Error return the failed symbols
+func (e *MarketSnapshotError) Error() string {
+	symbols := make([]string, 0, len(e.Errors))
+	for symbol := range e.Errors {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	return fmt.Sprintf("market snapshot failed for %d symbols: %s", len(symbols), strings.Join(symbols, ", "))
+}
+
+// RequestInterval returns the minimum time between two requests allowed by
+// the REQUESTS and REQUEST_WEIGHT limits of limits, counting a weight of 1
+// per request
+func RequestInterval(limits []RateLimit) time.Duration {
+	var interval time.Duration
+	for _, l := range limits {
+		if l.Limit <= 0 || (l.RateLimitType != "REQUESTS" && l.RateLimitType != "REQUEST_WEIGHT") {
+			continue
+		}
+		var unit time.Duration
+		switch l.Interval {
+		case "SECOND":
+			unit = time.Second
+		case "MINUTE":
+			unit = time.Minute
+		case "HOUR":
+			unit = time.Hour
+		case "DAY":
+			unit = oneDay
+		default:
+			continue
+		}
+		num := l.IntervalNum
+		if num <= 0 {
+			num = 1
+		}
+		if d := unit * time.Duration(num) / time.Duration(l.Limit); d > interval {
+			interval = d
+		}
+	}
+	return interval
+}
+
+// rateLimitInterval returns the RequestInterval of the rate limits of the
+// exchange info, fetched once per client
+func (c *Client) rateLimitInterval(ctx context.Context, opts ...RequestOption) (time.Duration, error) {
+	if interval := c.rateLimitIntervalCache.Load(); interval != nil {
+		return *interval, nil
+	}
+	info, err := c.NewExchangeInfoService().Do(ctx, opts...)
+	if err != nil {
+		return 0, err
+	}
+	interval := RequestInterval(info.RateLimits)
+	c.rateLimitIntervalCache.Store(&interval)
+	return interval, nil
+}
+
+// MarketSnapshotService fetch the depth and the latest kline of many symbols
+// concurrently
+type MarketSnapshotService struct {
+	c               *Client
+	symbols         []string
+	depthLimit      *int
+	interval        CandlestickInterval
+	concurrency     int
+	requestInterval *time.Duration
+	info            *ExchangeInfo
+	registry        *SymbolRegistry
+	timeout         time.Duration
+}
+
+// Symbols set symbols
+func (s *MarketSnapshotService) Symbols(symbols ...string) *MarketSnapshotService {
+	s.symbols = symbols
+	return s
+}
+
+// DepthLimit set the limit of the depth requests
+func (s *MarketSnapshotService) DepthLimit(limit int) *MarketSnapshotService {
+	s.depthLimit = &limit
+	return s
+}
+
+// Interval set the interval of the latest kline, 1m by default
+func (s *MarketSnapshotService) Interval(interval CandlestickInterval) *MarketSnapshotService {
+	s.interval = interval
+	return s
+}
+
+// Concurrency set the number of symbols fetched at the same time, 8 by default
+func (s *MarketSnapshotService) Concurrency(concurrency int) *MarketSnapshotService {
+	s.concurrency = concurrency
+	return s
+}
+
+// RequestInterval set the minimum time between two requests, 0 to disable
+// pacing. By default it is derived from the rate limits of the exchange
+// info, see RequestInterval: of ExchangeInfo or Registry if set, otherwise
+// fetched by the first snapshot of the client and reused by the next ones.
+func (s *MarketSnapshotService) RequestInterval(interval time.Duration) *MarketSnapshotService {
+	s.requestInterval = &interval
+	return s
+}
+
+// ExchangeInfo set the exchange info whose rate limits pace the requests
+func (s *MarketSnapshotService) ExchangeInfo(info *ExchangeInfo) *MarketSnapshotService {
+	s.info = info
+	return s
+}
+
+// Registry set the registry whose cached exchange info paces the requests
+func (s *MarketSnapshotService) Registry(registry *SymbolRegistry) *MarketSnapshotService {
+	s.registry = registry
+	return s
+}
+
+// Timeout set the deadline after which the remaining symbols are returned as timed out
+func (s *MarketSnapshotService) Timeout(timeout time.Duration) *MarketSnapshotService {
+	s.timeout = timeout
+	return s
+}
+
+// Do fetch every symbol. The result has a snapshot per symbol in the order of
+// Symbols; err is a *MarketSnapshotError if some of them failed or timed out,
+// or the error of ctx if it was canceled.
+func (s *MarketSnapshotService) Do(ctx context.Context, opts ...RequestOption) (res []*MarketSnapshot, err error) {
+	var requestInterval time.Duration
+	switch {
+	case s.requestInterval != nil:
+		requestInterval = *s.requestInterval
+	case s.info != nil:
+		requestInterval = RequestInterval(s.info.RateLimits)
+	case s.registry != nil:
+		info, err := s.registry.ExchangeInfo(ctx, opts...)
+		if err != nil {
+			return nil, err
+		}
+		requestInterval = RequestInterval(info.RateLimits)
+	default:
+		if requestInterval, err = s.c.rateLimitInterval(ctx, opts...); err != nil {
+			return nil, err
+		}
+	}
+	parent := ctx
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	concurrency := s.concurrency
+	if concurrency <= 0 {
+		concurrency = defaultMarketSnapshotConcurrency
+	}
+	pacer := &requestPacer{interval: requestInterval}
+
+	res = make([]*MarketSnapshot, len(s.symbols))
+	indexC := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexC {
+				res[i] = s.snapshot(parent, ctx, pacer, s.symbols[i], opts...)
+			}
+		}()
+	}
+	for i := range s.symbols {
+		indexC <- i
+	}
+	close(indexC)
+	wg.Wait()
+	if err = parent.Err(); err != nil {
+		return res, err
+	}
+
+	failed := make(map[string]error)
+	for _, snapshot := range res {
+		if snapshot.Err != nil {
+			failed[snapshot.Symbol] = snapshot.Err
+		}
+	}
+	if len(failed) > 0 {
+		return res, &MarketSnapshotError{Errors: failed}
+	}
+	return res, nil
+}
+
+// snapshot fetch symbol with ctx, the deadline of the service derived from parent
+func (s *MarketSnapshotService) snapshot(parent, ctx context.Context, pacer *requestPacer, symbol string, opts ...RequestOption) *MarketSnapshot {
+	res := &MarketSnapshot{Symbol: symbol}
+	res.Err = func() error {
+		if err := pacer.wait(ctx); err != nil {
+			return err
+		}
+		depth := s.c.NewDepthService().Symbol(symbol)
+		if s.depthLimit != nil {
+			depth.Limit(*s.depthLimit)
+		}
+		var err error
+		if res.Depth, err = depth.Do(ctx, opts...); err != nil {
+			return err
+		}
+
+		if err = pacer.wait(ctx); err != nil {
+			return err
+		}
+		interval := s.interval
+		if interval == "" {
+			interval = CandlestickInterval1m
+		}
+		klines, err := s.c.NewKlinesService().Symbol(symbol).Interval(interval).Limit(1).Do(ctx, opts...)
+		if err != nil {
+			return err
+		}
+		if len(klines) > 0 {
+			res.Kline = klines[len(klines)-1]
+		}
+		return nil
+	}()
+	switch {
+	case res.Err == nil:
+	case parent.Err() != nil:
+		// canceled by the caller, not timed out
+		res.Err = parent.Err()
+	case ctx.Err() != nil:
+		res.TimedOut = true
+		if !errors.Is(res.Err, ctx.Err()) {
+			res.Err = ctx.Err()
+		}
+	}
+	return res
+}
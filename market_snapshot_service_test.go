@@ -0,0 +1,182 @@
+package go_currencycom
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type marketSnapshotServiceTestSuite struct {
+	baseTestSuite
+	inFlight    atomic.Int64
+	maxInFlight atomic.Int64
+	mu          sync.Mutex
+	times       []time.Time
+	infos       atomic.Int64
+}
+
+func TestMarketSnapshotService(t *testing.T) {
+	suite.Run(t, new(marketSnapshotServiceTestSuite))
+}
+
+func (s *marketSnapshotServiceTestSuite) SetupTest() {
+	s.baseTestSuite.SetupTest()
+	s.inFlight.Store(0)
+	s.maxInFlight.Store(0)
+	s.times = nil
+	s.infos.Store(0)
+	s.client.Client.do = s.serve
+}
+
+// serve answer depth and klines, fails for FAIL and never answers for SLOW.
+// The exchange info allows a request every 20ms.
+func (s *marketSnapshotServiceTestSuite) serve(req *http.Request) (*http.Response, error) {
+	if strings.HasSuffix(req.URL.Path, "exchangeInfo") {
+		s.infos.Add(1)
+		return newHTTPResponse([]byte(`{"rateLimits":[{"interval":"SECOND","intervalNum":1,"limit":50,"rateLimitType":"REQUESTS"}]}`), http.StatusOK), nil
+	}
+	n := s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+	for {
+		peak := s.maxInFlight.Load()
+		if n <= peak || s.maxInFlight.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	s.mu.Lock()
+	s.times = append(s.times, time.Now())
+	s.mu.Unlock()
+	time.Sleep(5 * time.Millisecond)
+
+	symbol := req.URL.Query().Get("symbol")
+	switch symbol {
+	case "FAIL":
+		return newHTTPResponse([]byte(`{"code":-1121,"msg":"Invalid symbol."}`), http.StatusBadRequest), nil
+	case "SLOW":
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	}
+	if strings.HasSuffix(req.URL.Path, "depth") {
+		return newHTTPResponse([]byte(`{"lastUpdateId":1,"bids":[[99,1]],"asks":[[101,2]]}`), http.StatusOK), nil
+	}
+	return newHTTPResponse([]byte(`[[1499040000000,"1","2","0.5","1.5","10"]]`), http.StatusOK), nil
+}
+
+func (s *marketSnapshotServiceTestSuite) TestDo() {
+	symbols := []string{"A", "B", "C", "D", "E", "F"}
+	res, err := s.client.NewMarketSnapshotService().Symbols(symbols...).
+		DepthLimit(5).Interval(CandlestickInterval1h).Concurrency(2).Do(newContext())
+	r := s.r()
+	r.NoError(err)
+	r.Len(res, len(symbols))
+	for i, snapshot := range res {
+		r.Equal(symbols[i], snapshot.Symbol)
+		r.NoError(snapshot.Err)
+		r.Equal(101.0, snapshot.Depth.Asks[0].Price)
+		r.Equal(1.5, snapshot.Kline.Close)
+		r.Equal(time.Hour, snapshot.Kline.CloseTime.Sub(snapshot.Kline.OpenTime))
+	}
+	r.LessOrEqual(s.maxInFlight.Load(), int64(2))
+}
+
+func (s *marketSnapshotServiceTestSuite) TestPartialFailure() {
+	res, err := s.client.NewMarketSnapshotService().Symbols("A", "FAIL", "SLOW", "B").
+		Timeout(100 * time.Millisecond).Do(newContext())
+	r := s.r()
+	r.Error(err)
+	snapshotErr, ok := err.(*MarketSnapshotError)
+	r.True(ok)
+	r.Len(snapshotErr.Errors, 2)
+	r.Contains(err.Error(), "FAIL, SLOW")
+
+	r.NoError(res[0].Err)
+	r.True(IsAPIError(res[1].Err))
+	r.False(res[1].TimedOut)
+	r.True(res[2].TimedOut)
+	r.NoError(res[3].Err)
+}
+
+// span returns the time between the first and the last request
+func (s *marketSnapshotServiceTestSuite) span() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	first, last := s.times[0], s.times[0]
+	for _, t := range s.times {
+		if t.Before(first) {
+			first = t
+		}
+		if t.After(last) {
+			last = t
+		}
+	}
+	return last.Sub(first)
+}
+
+func (s *marketSnapshotServiceTestSuite) TestCanceled() {
+	ctx, cancel := context.WithCancel(newContext())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	res, err := s.client.NewMarketSnapshotService().Symbols("A", "SLOW").
+		RequestInterval(0).Timeout(time.Second).Do(ctx)
+	r := s.r()
+	r.ErrorIs(err, context.Canceled)
+	r.NoError(res[0].Err)
+	r.ErrorIs(res[1].Err, context.Canceled)
+	r.False(res[1].TimedOut)
+}
+
+func (s *marketSnapshotServiceTestSuite) TestRequestInterval() {
+	_, err := s.client.NewMarketSnapshotService().Symbols("A", "B").
+		RequestInterval(20 * time.Millisecond).Do(newContext())
+	r := s.r()
+	r.NoError(err)
+	r.Len(s.times, 4)
+	r.GreaterOrEqual(s.span(), 55*time.Millisecond)
+
+	// paced by the rate limits of the exchange info by default, fetched
+	// once per client
+	for i := 0; i < 2; i++ {
+		s.times = nil
+		_, err = s.client.NewMarketSnapshotService().Symbols("A", "B").Do(newContext())
+		r.NoError(err)
+		r.Len(s.times, 4)
+		r.GreaterOrEqual(s.span(), 55*time.Millisecond)
+	}
+	r.Equal(int64(1), s.infos.Load())
+
+	// or of a given exchange info, on a client without cache
+	s.times = nil
+	c := NewClient(s.apiKey, s.secretKey)
+	c.do = s.serve
+	info := &ExchangeInfo{RateLimits: []RateLimit{{Interval: "SECOND", IntervalNum: 1, Limit: 50, RateLimitType: "REQUESTS"}}}
+	_, err = c.NewMarketSnapshotService().Symbols("A", "B").ExchangeInfo(info).Do(newContext())
+	r.NoError(err)
+	r.GreaterOrEqual(s.span(), 55*time.Millisecond)
+	r.Equal(int64(1), s.infos.Load())
+
+	// or of the cache of a registry
+	registry, err := NewSymbolRegistry(c, time.Hour, nil)
+	r.NoError(err)
+	r.NoError(registry.Refresh(newContext()))
+	s.times = nil
+	_, err = c.NewMarketSnapshotService().Symbols("A", "B").Registry(registry).Do(newContext())
+	r.NoError(err)
+	r.GreaterOrEqual(s.span(), 55*time.Millisecond)
+	r.Equal(int64(2), s.infos.Load())
+
+	s.times = nil
+	_, err = s.client.NewMarketSnapshotService().Symbols("A", "B").RequestInterval(0).Do(newContext())
+	r.NoError(err)
+	r.Less(s.span(), 55*time.Millisecond)
+
+	r.Equal(50*time.Millisecond, RequestInterval([]RateLimit{
+		{Interval: "MINUTE", IntervalNum: 1, Limit: 1200, RateLimitType: "REQUEST_WEIGHT"},
+		{Interval: "SECOND", IntervalNum: 1, Limit: 20, RateLimitType: "REQUESTS"},
+		{Interval: "SECOND", IntervalNum: 10, Limit: 1, RateLimitType: "ORDERS"},
+	}))
+}
@@ -0,0 +1,311 @@
+package go_currencycom
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrOrderBookCrossed is reported when an update leaves the best bid at or above the best ask
+var ErrOrderBookCrossed = errors.New("order book crossed")
+
+// LocalOrderBook maintains the order book of a symbol from depth websocket
+// events, optionally seeded by a DepthService snapshot until the first one.
+// Each marketdepth.event carries the full depth of the symbol, so it
+// replaces the book rather than updating it; events older than the last
+// applied one are ignored. A crossed book is not ready: the readers report
+// no level until an event uncrosses it, or until it is re-seeded from a
+// DepthService snapshot, which Handle does in the background by default.
+// It is safe for concurrent readers.
+type LocalOrderBook struct {
+	c          *Client
+	symbol     string
+	limit      *int
+	errHandler ErrHandler
+	autoResync bool
+	// Timeout bounds each automatic re-seed, 10 seconds by default
+	Timeout time.Duration
+
+	mu           sync.RWMutex
+	bids         PriceLevelList
+	asks         PriceLevelList
+	lastUpdateID int64
+	timestamp    int64
+	synced       bool
+	resyncing    bool
+	wg           sync.WaitGroup
+}
+
+// NewLocalOrderBook init the order book of symbol, errHandler receives the
+// inconsistencies met by Handle and the errors of the automatic re-seeds.
+func NewLocalOrderBook(c *Client, symbol string, errHandler ErrHandler) *LocalOrderBook {
+	return &LocalOrderBook{
+		c:          c,
+		symbol:     symbol,
+		errHandler: errHandler,
+		autoResync: true,
+		Timeout:    10 * time.Second,
+	}
+}
+
+// Limit set the depth of the snapshot
+func (b *LocalOrderBook) Limit(limit int) *LocalOrderBook {
+	b.limit = &limit
+	return b
+}
+
+// AutoResync set whether Handle re-seeds a crossed book from a DepthService
+// snapshot, true by default
+func (b *LocalOrderBook) AutoResync(enabled bool) *LocalOrderBook {
+	b.autoResync = enabled
+	return b
+}
+
+// Symbol returns the symbol of the book
+func (b *LocalOrderBook) Symbol() string {
+	return b.symbol
+}
+
+// Sync seed the book from a DepthService snapshot, or re-seed it. A
+// snapshot whose LastUpdateID is not above the one of the last applied
+// snapshot is stale and discarded. Websocket events older than the last
+// applied one are still ignored afterwards.
+func (b *LocalOrderBook) Sync(ctx context.Context) error {
+	return b.sync(ctx, false)
+}
+
+// sync apply a DepthService snapshot. If unsynced is set, it is discarded
+// unless the book is still out of sync once it was fetched.
+func (b *LocalOrderBook) sync(ctx context.Context, unsynced bool) error {
+	s := b.c.NewDepthService().Symbol(b.symbol)
+	if b.limit != nil {
+		s.Limit(*b.limit)
+	}
+	res, err := s.Do(ctx)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if res.LastUpdateID <= b.lastUpdateID || (unsynced && b.synced) {
+		return nil
+	}
+	b.lastUpdateID = res.LastUpdateID
+	return b.replaceLocked(res.Bids, res.Asks)
+}
+
+// resync re-seed the book in the background, unless it is already being
+// re-seeded
+func (b *LocalOrderBook) resync() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.resyncing {
+		return
+	}
+	b.resyncing = true
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), b.Timeout)
+		err := b.sync(ctx, true)
+		cancel()
+		b.mu.Lock()
+		b.resyncing = false
+		b.mu.Unlock()
+		if err != nil {
+			b.reportErr(err)
+		}
+	}()
+}
+
+// Wait for the running re-seed to complete
+func (b *LocalOrderBook) Wait() {
+	b.wg.Wait()
+}
+
+// Apply a depth event to the book. Events of other symbols or older than
+// the last applied one are ignored.
+func (b *LocalOrderBook) Apply(event *WsDepthEvent) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if event.Symbol != b.symbol || event.Timestamp <= b.timestamp {
+		return nil
+	}
+	b.timestamp = event.Timestamp
+	return b.replaceLocked(event.Bids, event.Asks)
+}
+
+// replaceLocked replace both sides of the book, dropping empty levels
+func (b *LocalOrderBook) replaceLocked(bids, asks PriceLevelList) error {
+	b.bids = b.bids[:0]
+	for _, level := range bids {
+		b.bids = setPriceLevel(b.bids, level, true)
+	}
+	b.asks = b.asks[:0]
+	for _, level := range asks {
+		b.asks = setPriceLevel(b.asks, level, false)
+	}
+	if len(b.bids) > 0 && len(b.asks) > 0 && b.bids[0].Price >= b.asks[0].Price {
+		b.synced = false
+		return ErrOrderBookCrossed
+	}
+	b.synced = true
+	return nil
+}
+
+// setPriceLevel insert, update or remove level in a list sorted by
+// descending price if desc is set, ascending price otherwise.
+func setPriceLevel(levels PriceLevelList, level PriceLevel, desc bool) PriceLevelList {
+	i := sort.Search(len(levels), func(i int) bool {
+		if desc {
+			return levels[i].Price <= level.Price
+		}
+		return levels[i].Price >= level.Price
+	})
+	found := i < len(levels) && levels[i].Price == level.Price
+	switch {
+	case level.Quantity <= 0 && found:
+		return append(levels[:i], levels[i+1:]...)
+	case level.Quantity <= 0:
+		return levels
+	case found:
+		levels[i].Quantity = level.Quantity
+		return levels
+	}
+	levels = append(levels, PriceLevel{})
+	copy(levels[i+1:], levels[i:])
+	levels[i] = level
+	return levels
+}
+
+// Handle apply a depth event, reporting a crossed book to the error handler
+// and re-seeding it unless AutoResync is disabled
+func (b *LocalOrderBook) Handle(event *WsDepthEvent) {
+	if err := b.Apply(event); err != nil {
+		b.reportErr(err)
+		if b.autoResync && errors.Is(err, ErrOrderBookCrossed) {
+			b.resync()
+		}
+	}
+}
+
+// Handler returns Handle as a WsDepthHandler
+func (b *LocalOrderBook) Handler() WsDepthHandler {
+	return b.Handle
+}
+
+func (b *LocalOrderBook) reportErr(err error) {
+	if b.errHandler != nil {
+		b.errHandler(err)
+	}
+}
+
+// Synced reports whether the book is seeded and not crossed. The readers
+// report no level while it is not.
+func (b *LocalOrderBook) Synced() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.synced
+}
+
+// LastUpdateID returns the id of the last applied DepthService snapshot
+func (b *LocalOrderBook) LastUpdateID() int64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.lastUpdateID
+}
+
+// Timestamp returns the timestamp of the last applied event
+func (b *LocalOrderBook) Timestamp() int64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.timestamp
+}
+
+// BestBid returns the highest bid
+func (b *LocalOrderBook) BestBid() (level PriceLevel, ok bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if !b.synced || len(b.bids) == 0 {
+		return PriceLevel{}, false
+	}
+	return b.bids[0], true
+}
+
+// BestAsk returns the lowest ask
+func (b *LocalOrderBook) BestAsk() (level PriceLevel, ok bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if !b.synced || len(b.asks) == 0 {
+		return PriceLevel{}, false
+	}
+	return b.asks[0], true
+}
+
+// Spread returns the difference between the best ask and the best bid
+func (b *LocalOrderBook) Spread() (spread float64, ok bool) {
+	bid, okBid := b.BestBid()
+	ask, okAsk := b.BestAsk()
+	if !okBid || !okAsk {
+		return 0, false
+	}
+	return ask.Price - bid.Price, true
+}
+
+// Mid returns the average of the best bid and the best ask
+func (b *LocalOrderBook) Mid() (mid float64, ok bool) {
+	bid, okBid := b.BestBid()
+	ask, okAsk := b.BestAsk()
+	if !okBid || !okAsk {
+		return 0, false
+	}
+	return (ask.Price + bid.Price) / 2, true
+}
+
+// Depth returns a copy of the n best levels of each side, every level if
+// n <= 0, and empty lists if the book is not synced
+func (b *LocalOrderBook) Depth(n int) (bids, asks PriceLevelList) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if !b.synced {
+		return PriceLevelList{}, PriceLevelList{}
+	}
+	return copyPriceLevels(b.bids, n), copyPriceLevels(b.asks, n)
+}
+
+func copyPriceLevels(levels PriceLevelList, n int) PriceLevelList {
+	if n <= 0 || n > len(levels) {
+		n = len(levels)
+	}
+	return append(PriceLevelList{}, levels[:n]...)
+}
+
+// CumulativeSize returns the quantity available to a taker order of side
+// up to price: the asks at or below price for a buy, the bids at or above
+// price for a sell. It is 0 if the book is not synced.
+func (b *LocalOrderBook) CumulativeSize(side SideType, price float64) float64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var size float64
+	if !b.synced {
+		return 0
+	}
+	if side == SideTypeBuy {
+		for _, level := range b.asks {
+			if level.Price > price {
+				break
+			}
+			size += level.Quantity
+		}
+		return size
+	}
+	for _, level := range b.bids {
+		if level.Price < price {
+			break
+		}
+		size += level.Quantity
+	}
+	return size
+}
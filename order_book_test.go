@@ -0,0 +1,173 @@
+package go_currencycom
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type orderBookTestSuite struct {
+	baseTestSuite
+}
+
+func TestLocalOrderBook(t *testing.T) {
+	suite.Run(t, new(orderBookTestSuite))
+}
+
+// mockDepth answer the next snapshot request
+func (s *orderBookTestSuite) mockDepth(lastUpdateID int64) {
+	data := []byte(fmt.Sprintf(`{
+		"lastUpdateId": %d,
+		"asks": [[101, 1], [103, 3], [102, 2]],
+		"bids": [[98, 2], [99, 1], [97, 3]]
+	}`, lastUpdateID))
+	s.client.ExpectedCalls = nil
+	s.client.Calls = nil
+	s.mockDo(data, nil)
+}
+
+func (s *orderBookTestSuite) newBook() *LocalOrderBook {
+	s.mockDepth(1000)
+	b := NewLocalOrderBook(s.client.Client, "BTC/USD", func(err error) {
+		s.Fail("unexpected error", err)
+	})
+	s.r().NoError(b.Sync(newContext()))
+	return b
+}
+
+func (s *orderBookTestSuite) TestSnapshot() {
+	b := s.newBook()
+	r := s.r()
+	r.True(b.Synced())
+	r.Equal(int64(1000), b.LastUpdateID())
+	bid, ok := b.BestBid()
+	r.True(ok)
+	r.Equal(PriceLevel{Price: 99, Quantity: 1}, bid)
+	ask, ok := b.BestAsk()
+	r.True(ok)
+	r.Equal(PriceLevel{Price: 101, Quantity: 1}, ask)
+	spread, _ := b.Spread()
+	r.Equal(2.0, spread)
+	mid, _ := b.Mid()
+	r.Equal(100.0, mid)
+	bids, asks := b.Depth(2)
+	r.Equal(PriceLevelList{{99, 1}, {98, 2}}, bids)
+	r.Equal(PriceLevelList{{101, 1}, {102, 2}}, asks)
+	r.Equal(3.0, b.CumulativeSize(SideTypeBuy, 102.5))
+	r.Equal(6.0, b.CumulativeSize(SideTypeSell, 97))
+}
+
+func (s *orderBookTestSuite) TestApply() {
+	b := s.newBook()
+	r := s.r()
+	// events carry the full depth and replace the snapshot
+	r.NoError(b.Apply(&WsDepthEvent{
+		Symbol:    "BTC/USD",
+		Timestamp: 1597850971558,
+		Bids:      PriceLevelList{{100, 5}, {98, 0}, {99, 4}},
+		Asks:      PriceLevelList{{101, 0}, {104, 1}, {102, 2}},
+	}))
+	bids, asks := b.Depth(0)
+	r.Equal(PriceLevelList{{100, 5}, {99, 4}}, bids)
+	r.Equal(PriceLevelList{{102, 2}, {104, 1}}, asks)
+	r.Equal(int64(1597850971558), b.Timestamp())
+	r.Equal(int64(1000), b.LastUpdateID())
+
+	// older events and stale snapshots are ignored
+	r.NoError(b.Apply(&WsDepthEvent{Symbol: "BTC/USD", Timestamp: 1597850971000, Bids: PriceLevelList{{90, 5}}}))
+	s.mockDepth(1000)
+	r.NoError(b.Sync(newContext()))
+	bid, _ := b.BestBid()
+	r.Equal(100.0, bid.Price)
+
+	// a newer snapshot replaces the book
+	s.mockDepth(1001)
+	r.NoError(b.Sync(newContext()))
+	bid, _ = b.BestBid()
+	r.Equal(99.0, bid.Price)
+	r.Equal(int64(1001), b.LastUpdateID())
+	r.Equal(int64(1597850971558), b.Timestamp())
+
+	r.NoError(b.Apply(&WsDepthEvent{Symbol: "ETH/USD", Timestamp: 1597850972000}))
+	r.True(b.Synced())
+}
+
+func (s *orderBookTestSuite) TestCrossedBook() {
+	b := s.newBook().AutoResync(false)
+	var errs []error
+	b.errHandler = func(err error) {
+		errs = append(errs, err)
+	}
+	handler := b.Handler()
+	handler(&WsDepthEvent{Symbol: "BTC/USD", Timestamp: 1001, Bids: PriceLevelList{{102, 1}}, Asks: PriceLevelList{{101, 1}}})
+	b.Wait()
+	r := s.r()
+	r.Len(errs, 1)
+	r.ErrorIs(errs[0], ErrOrderBookCrossed)
+	r.False(b.Synced())
+	// a crossed book is not readable
+	_, ok := b.BestBid()
+	r.False(ok)
+	_, ok = b.Mid()
+	r.False(ok)
+	bids, asks := b.Depth(0)
+	r.Empty(bids)
+	r.Empty(asks)
+	r.Zero(b.CumulativeSize(SideTypeBuy, 200))
+
+	// the next event replaces the book
+	handler(&WsDepthEvent{Symbol: "BTC/USD", Timestamp: 1003, Bids: PriceLevelList{{99, 1}}, Asks: PriceLevelList{{100.5, 1}}})
+	r.Len(errs, 1)
+	r.True(b.Synced())
+	bid, _ := b.BestBid()
+	r.Equal(99.0, bid.Price)
+	ask, _ := b.BestAsk()
+	r.Equal(100.5, ask.Price)
+}
+
+func (s *orderBookTestSuite) TestResync() {
+	b := s.newBook()
+	var mu sync.Mutex
+	var errs []error
+	b.errHandler = func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	}
+	handler := b.Handler()
+	crossed := &WsDepthEvent{Symbol: "BTC/USD", Timestamp: 1001, Bids: PriceLevelList{{102, 1}}, Asks: PriceLevelList{{101, 1}}}
+
+	// a stale snapshot is discarded, the book stays out of sync
+	s.mockDepth(1000)
+	handler(crossed)
+	b.Wait()
+	r := s.r()
+	s.assertDo()
+	r.False(b.Synced())
+	r.Equal(int64(1000), b.LastUpdateID())
+
+	// a crossed update re-seeds the book from a newer snapshot
+	s.mockDepth(1002)
+	crossed.Timestamp = 1002
+	handler(crossed)
+	b.Wait()
+	s.assertDo()
+	r.True(b.Synced())
+	r.Equal(int64(1002), b.LastUpdateID())
+	bid, _ := b.BestBid()
+	r.Equal(99.0, bid.Price)
+	ask, _ := b.BestAsk()
+	r.Equal(101.0, ask.Price)
+	// events not newer than the crossed one are still ignored
+	r.NoError(b.Apply(&WsDepthEvent{Symbol: "BTC/USD", Timestamp: 1002, Bids: PriceLevelList{{90, 1}}}))
+	bid, _ = b.BestBid()
+	r.Equal(99.0, bid.Price)
+
+	mu.Lock()
+	defer mu.Unlock()
+	r.Len(errs, 2)
+	r.ErrorIs(errs[0], ErrOrderBookCrossed)
+	r.ErrorIs(errs[1], ErrOrderBookCrossed)
+}
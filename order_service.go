@@ -3,6 +3,7 @@ package go_currencycom
 import (
 	"context"
 	"net/http"
+	"time"
 )
 
 type CreateOrderService struct {
@@ -22,6 +23,9 @@ type CreateOrderService struct {
 	takeProfit         *float64
 	trailingStopLoss   *bool
 	orderType          OrderType
+	maxSlippageBps     *float64
+	tickSize           float64
+	schedule           *TradingSchedule
 }
 
 // Symbol set symbol
@@ -114,6 +118,58 @@ func (s *CreateOrderService) Type(orderType OrderType) *CreateOrderService {
 	return s
 }
 
+// MaxSlippage refuse market orders whose estimated slippage against the
+// current depth exceeds maxBps basis points, or that the depth cannot fill
+func (s *CreateOrderService) MaxSlippage(maxBps float64) *CreateOrderService {
+	s.maxSlippageBps = &maxBps
+	return s
+}
+
+// TickSize set the tick size of the symbol used to estimate the slippage
+// in ticks, e.g. from SymbolRegistry.TickSize
+func (s *CreateOrderService) TickSize(tickSize float64) *CreateOrderService {
+	s.tickSize = tickSize
+	return s
+}
+
+// EstimateSlippage estimate the fill of the order against the current depth
+func (s *CreateOrderService) EstimateSlippage(ctx context.Context, opts ...RequestOption) (*SlippageEstimate, error) {
+	depth, err := s.c.NewDepthService().Symbol(s.symbol).Do(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return EstimateSlippage(depth, s.side, s.quantity, s.tickSize)
+}
+
+func (s *CreateOrderService) checkSlippage(ctx context.Context, opts ...RequestOption) error {
+	if s.maxSlippageBps == nil || s.orderType != OrderTypeMarket {
+		return nil
+	}
+	estimate, err := s.EstimateSlippage(ctx, opts...)
+	if err != nil {
+		return err
+	}
+	return estimate.Check(*s.maxSlippageBps)
+}
+
+// RequireMarketOpen refuse orders with a *MarketClosedError when the market
+// is closed according to schedule, e.g. from SymbolRegistry.TradingSchedule
+func (s *CreateOrderService) RequireMarketOpen(schedule *TradingSchedule) *CreateOrderService {
+	s.schedule = schedule
+	return s
+}
+
+func (s *CreateOrderService) checkMarketOpen() error {
+	if s.schedule == nil {
+		return nil
+	}
+	now := time.Now()
+	if s.schedule.IsOpen(now) {
+		return nil
+	}
+	return &MarketClosedError{Symbol: s.symbol, NextOpen: s.schedule.NextOpen(now)}
+}
+
 func (s *CreateOrderService) createOrder(ctx context.Context, endpoint string, opts ...RequestOption) (data []byte, err error) {
 	r := &request{
 		method:   http.MethodPost,
@@ -191,6 +247,12 @@ type CreateOrderResponse struct {
 
 // Do send request
 func (s *CreateOrderService) Do(ctx context.Context, opts ...RequestOption) (res *CreateOrderResponse, err error) {
+	if err = s.checkMarketOpen(); err != nil {
+		return nil, err
+	}
+	if err = s.checkSlippage(ctx, opts...); err != nil {
+		return nil, err
+	}
 	data, err := s.createOrder(ctx, "/api/v2/order", opts...)
 	if err != nil {
 		return nil, err
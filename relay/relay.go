@@ -0,0 +1,425 @@
+// Package relay re-serves the Currency.com websocket streams on a local
+// endpoint. A single Server shares one upstream connection per stream between
+// its clients and speaks the same /connect protocol, so that WsXxxServe clients can connect
+// to it by setting WebsocketEndpoint or WithWsEndpoint.
+package relay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	currencycom "github.com/radovsky1/go-currencycom"
+)
+
+const (
+	statusOK         = "OK"
+	statusBadRequest = "BAD_REQUEST"
+
+	destinationPing = "ping"
+
+	streamMarketData     = "marketData"
+	streamOHLCMarketData = "OHLCMarketData"
+	streamTrades         = "trades"
+	streamDepth          = "depthMarketData"
+
+	actionSubscribe   = "subscribe"
+	actionUnsubscribe = "unsubscribe"
+
+	// errorCodeBadRequest is returned for unknown destinations and invalid payloads
+	errorCodeBadRequest = -1
+	// errorCodeUpstream is returned when the upstream subscription fails
+	// without error code, e.g. when it can't be dialed
+	errorCodeUpstream = -2
+
+	writeTimeout = 10 * time.Second
+)
+
+// DefaultQueueSize is the number of messages buffered per client. Clients
+// that fall further behind are disconnected.
+var DefaultQueueSize = 1024
+
+type request struct {
+	Destination   string          `json:"destination"`
+	CorrelationID json.RawMessage `json:"correlationId"`
+	Payload       struct {
+		Symbols   []string `json:"symbols"`
+		Intervals []string `json:"intervals"`
+	} `json:"payload"`
+}
+
+type response struct {
+	Status        string          `json:"status"`
+	Destination   string          `json:"destination"`
+	CorrelationID json.RawMessage `json:"correlationId,omitempty"`
+	Payload       interface{}     `json:"payload"`
+}
+
+type errorPayload struct {
+	ErrorCode    int64  `json:"errorCode"`
+	ErrorMessage string `json:"errorMessage"`
+}
+
+// Server is an http.Handler relaying the upstream streams to local clients
+type Server struct {
+	hub *currencycom.WsHub
+	// QueueSize overrides DefaultQueueSize if set
+	QueueSize int
+	// ErrorLog receives the upstream and client errors, nothing is logged if nil
+	ErrorLog *log.Logger
+	// CheckOrigin returns true if the websocket of a request may be upgraded.
+	// If nil, requests with an Origin header must come from the host of the
+	// relay, see websocket.Upgrader.
+	CheckOrigin func(r *http.Request) bool
+}
+
+// NewServer init a relay, opts are applied to every upstream subscription
+func NewServer(opts ...currencycom.WsOption) *Server {
+	s := &Server{}
+	s.hub = currencycom.NewWsHub(func(err error) {
+		s.logf("upstream: %s", err)
+	}, opts...)
+	return s
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.ErrorLog != nil {
+		s.ErrorLog.Printf(format, args...)
+	}
+}
+
+// Subscribers returns the number of local subscribers per symbol of a stream
+func (s *Server) Subscribers(stream string) map[string]int {
+	return s.hub.Subscribers(stream)
+}
+
+// Close stop the upstream subscriptions
+func (s *Server) Close() {
+	s.hub.Close()
+}
+
+// ServeHTTP upgrade the request to a websocket and serve it until the client disconnects
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{CheckOrigin: s.CheckOrigin}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		s.logf("upgrade: %s", err)
+		return
+	}
+	queueSize := s.QueueSize
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+	c := &client{
+		server:        s,
+		conn:          conn,
+		sendC:         make(chan []byte, queueSize),
+		doneC:         make(chan struct{}),
+		subscriptions: make(map[subscriptionKey]*currencycom.WsHubSubscription),
+	}
+	go c.writeLoop()
+	c.readLoop()
+}
+
+type client struct {
+	server *Server
+	conn   *websocket.Conn
+	sendC  chan []byte
+	doneC  chan struct{}
+
+	mu     sync.Mutex
+	closed bool
+	// subscriptions maps each subscribed key to the hub subscription
+	// delivering it, which may deliver other keys too
+	subscriptions map[subscriptionKey]*currencycom.WsHubSubscription
+}
+
+// subscriptionKey is a symbol of a stream, and an interval for candles
+type subscriptionKey struct {
+	stream   string
+	symbol   string
+	interval string
+}
+
+func (c *client) readLoop() {
+	defer c.close()
+	for {
+		_, message, err := c.conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				c.server.logf("client %s: %s", c.conn.RemoteAddr(), err)
+			}
+			return
+		}
+		req := new(request)
+		if err := json.Unmarshal(message, req); err != nil {
+			c.replyError(req, errorCodeBadRequest, fmt.Sprintf("invalid request: %s", err))
+			continue
+		}
+		c.handle(req)
+	}
+}
+
+func (c *client) handle(req *request) {
+	if req.Destination == destinationPing {
+		c.reply(req, struct{}{})
+		return
+	}
+	stream, action, _ := strings.Cut(req.Destination, ".")
+	switch stream {
+	case streamMarketData, streamOHLCMarketData, streamTrades, streamDepth:
+	default:
+		c.replyError(req, errorCodeBadRequest, fmt.Sprintf("unknown destination %q", req.Destination))
+		return
+	}
+	intervals := []string{""}
+	if stream == streamOHLCMarketData {
+		intervals = req.Payload.Intervals
+	}
+	switch action {
+	case actionSubscribe:
+		c.subscribe(req, stream, intervals)
+	case actionUnsubscribe:
+		c.unsubscribe(req, stream, intervals)
+	default:
+		c.replyError(req, errorCodeBadRequest, fmt.Sprintf("unknown destination %q", req.Destination))
+	}
+}
+
+// subscribe the keys of the request the client is not subscribed to yet,
+// with one hub subscription per interval
+func (c *client) subscribe(req *request, stream string, intervals []string) {
+	subscribed := make([]*currencycom.WsHubSubscription, 0, len(intervals))
+	added := make(map[subscriptionKey]*currencycom.WsHubSubscription)
+	acks := make([]*currencycom.WsResponse, 0)
+	for _, interval := range intervals {
+		symbols := c.unsubscribed(stream, interval, req.Payload.Symbols)
+		if len(symbols) == 0 {
+			continue
+		}
+		sub, err := c.subscribeHub(stream, interval, symbols)
+		if err != nil {
+			for _, sub := range subscribed {
+				sub.Close()
+			}
+			wsErr := new(currencycom.WsError)
+			if errors.As(err, &wsErr) {
+				c.replyError(req, wsErr.Code, wsErr.Message)
+				return
+			}
+			c.replyError(req, errorCodeUpstream, err.Error())
+			return
+		}
+		subscribed = append(subscribed, sub)
+		acks = append(acks, sub.Acks...)
+		for _, symbol := range symbols {
+			added[subscriptionKey{stream: stream, symbol: symbol, interval: interval}] = sub
+		}
+	}
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		for _, sub := range subscribed {
+			sub.Close()
+		}
+		return
+	}
+	for key, sub := range added {
+		c.subscriptions[key] = sub
+	}
+	c.mu.Unlock()
+
+	c.reply(req, map[string]interface{}{"subscriptions": subscriptionStatus(req.Payload.Symbols, acks)})
+}
+
+// unsubscribed returns the symbols of a stream and interval the client is
+// not subscribed to, without duplicates
+func (c *client) unsubscribed(stream, interval string, symbols []string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res := make([]string, 0, len(symbols))
+	seen := make(map[string]bool, len(symbols))
+	for _, symbol := range symbols {
+		key := subscriptionKey{stream: stream, symbol: symbol, interval: interval}
+		if seen[symbol] || c.subscriptions[key] != nil {
+			continue
+		}
+		seen[symbol] = true
+		res = append(res, symbol)
+	}
+	return res
+}
+
+func (c *client) subscribeHub(stream, interval string, symbols []string) (*currencycom.WsHubSubscription, error) {
+	hub := c.server.hub
+	switch stream {
+	case streamMarketData:
+		return hub.SubscribeMarketData(symbols, func(event *currencycom.WsMarketDataEvent) {
+			c.push(currencycom.WsDestinationQuote, event)
+		}, nil)
+	case streamOHLCMarketData:
+		return hub.SubscribeOHLCMarketData(symbols, []string{interval}, func(event *currencycom.WsOHLCMarketDataEvent) {
+			c.push(currencycom.WsDestinationOHLC, event)
+		}, nil)
+	case streamTrades:
+		return hub.SubscribeTrades(symbols, func(event *currencycom.WsTradesEvent) {
+			c.push(currencycom.WsDestinationTrade, event)
+		}, nil)
+	default:
+		return hub.SubscribeDepth(symbols, func(event *currencycom.WsDepthEvent) {
+			c.push(currencycom.WsDestinationDepth, newDepthPayload(event))
+		}, nil)
+	}
+}
+
+// unsubscribe remove the keys of the request from their hub subscription.
+// Symbols the client is not subscribed to are ignored.
+func (c *client) unsubscribe(req *request, stream string, intervals []string) {
+	removed := make(map[subscriptionKey]*currencycom.WsHubSubscription)
+	c.mu.Lock()
+	for _, interval := range intervals {
+		for _, symbol := range req.Payload.Symbols {
+			key := subscriptionKey{stream: stream, symbol: symbol, interval: interval}
+			if sub := c.subscriptions[key]; sub != nil {
+				delete(c.subscriptions, key)
+				removed[key] = sub
+			}
+		}
+	}
+	c.mu.Unlock()
+	for key, sub := range removed {
+		sub.Unsubscribe(key.symbol)
+	}
+
+	status := make(map[string]string, len(req.Payload.Symbols))
+	for _, symbol := range req.Payload.Symbols {
+		status[symbol] = statusOK
+	}
+	c.reply(req, map[string]interface{}{"subscriptions": status})
+}
+
+// subscriptionStatus returns the status of each symbol as replied by the
+// upstream. Symbols that already had a subscriber were accepted before and
+// are not in the replies.
+func subscriptionStatus(symbols []string, acks []*currencycom.WsResponse) map[string]string {
+	res := make(map[string]string, len(symbols))
+	for _, symbol := range symbols {
+		res[symbol] = statusOK
+	}
+	for _, ack := range acks {
+		p := new(struct {
+			Subscriptions map[string]string `json:"subscriptions"`
+		})
+		if err := json.Unmarshal(ack.Payload, p); err != nil {
+			continue
+		}
+		for symbol, status := range p.Subscriptions {
+			res[symbol] = status
+		}
+	}
+	return res
+}
+
+// newDepthPayload encode a depth event back in the format of the upstream
+func newDepthPayload(event *currencycom.WsDepthEvent) interface{} {
+	levels := func(list currencycom.PriceLevelList) map[string]float64 {
+		res := make(map[string]float64, len(list))
+		for _, level := range list {
+			res[strconv.FormatFloat(level.Price, 'f', -1, 64)] = level.Quantity
+		}
+		return res
+	}
+	return map[string]interface{}{
+		"symbol": event.Symbol,
+		"data": map[string]interface{}{
+			"ts":  event.Timestamp,
+			"bid": levels(event.Bids),
+			"ofr": levels(event.Asks),
+		},
+	}
+}
+
+func (c *client) reply(req *request, payload interface{}) {
+	c.send(&response{
+		Status:        statusOK,
+		Destination:   req.Destination,
+		CorrelationID: req.CorrelationID,
+		Payload:       payload,
+	})
+}
+
+func (c *client) replyError(req *request, code int64, message string) {
+	c.send(&response{
+		Status:        statusBadRequest,
+		Destination:   req.Destination,
+		CorrelationID: req.CorrelationID,
+		Payload: &errorPayload{
+			ErrorCode:    code,
+			ErrorMessage: message,
+		},
+	})
+}
+
+func (c *client) push(destination string, payload interface{}) {
+	c.send(&response{
+		Status:      statusOK,
+		Destination: destination,
+		Payload:     payload,
+	})
+}
+
+// send queue a message, disconnecting the client if its queue is full
+func (c *client) send(res *response) {
+	message, err := json.Marshal(res)
+	if err != nil {
+		c.server.logf("marshal: %s", err)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	select {
+	case c.sendC <- message:
+	default:
+		c.server.logf("client %s: too slow, disconnecting", c.conn.RemoteAddr())
+		_ = c.conn.Close()
+	}
+}
+
+func (c *client) writeLoop() {
+	for {
+		select {
+		case message := <-c.sendC:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				_ = c.conn.Close()
+				return
+			}
+		case <-c.doneC:
+			return
+		}
+	}
+}
+
+func (c *client) close() {
+	c.mu.Lock()
+	c.closed = true
+	subscriptions := c.subscriptions
+	c.subscriptions = nil
+	c.mu.Unlock()
+	// Close is idempotent, a hub subscription may deliver several keys
+	for _, sub := range subscriptions {
+		sub.Close()
+	}
+	close(c.doneC)
+	_ = c.conn.Close()
+}
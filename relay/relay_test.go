@@ -0,0 +1,294 @@
+package relay
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	currencycom "github.com/radovsky1/go-currencycom"
+	"github.com/stretchr/testify/suite"
+)
+
+type relayTestSuite struct {
+	suite.Suite
+	upstream      *httptest.Server
+	relay         *Server
+	relayServer   *httptest.Server
+	mu            sync.Mutex
+	subscriptions int
+	destinations  []string
+}
+
+func TestRelay(t *testing.T) {
+	suite.Run(t, new(relayTestSuite))
+}
+
+func wsURL(s *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(s.URL, "http") + "/connect"
+}
+
+// serveUpstream acknowledges every subscription but of the INVALID symbol,
+// and pushes one quote or order book per symbol
+func (s *relayTestSuite) serveUpstream(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	for {
+		req := new(request)
+		if err := conn.ReadJSON(req); err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.subscriptions++
+		s.destinations = append(s.destinations, req.Destination)
+		s.mu.Unlock()
+		subscriptions := make(map[string]string)
+		for _, symbol := range req.Payload.Symbols {
+			if symbol == "INVALID" {
+				_ = conn.WriteJSON(&response{
+					Status:        statusBadRequest,
+					Destination:   req.Destination,
+					CorrelationID: req.CorrelationID,
+					Payload:       &errorPayload{ErrorCode: -1121, ErrorMessage: "Invalid symbol."},
+				})
+				subscriptions = nil
+				break
+			}
+			subscriptions[symbol] = statusOK
+		}
+		if subscriptions == nil {
+			continue
+		}
+		_ = conn.WriteJSON(&response{
+			Status:        statusOK,
+			Destination:   req.Destination,
+			CorrelationID: req.CorrelationID,
+			Payload:       map[string]interface{}{"subscriptions": subscriptions},
+		})
+		for _, symbol := range req.Payload.Symbols {
+			switch req.Destination {
+			case streamMarketData + "." + actionSubscribe:
+				_ = conn.WriteJSON(&response{
+					Status:      statusOK,
+					Destination: currencycom.WsDestinationQuote,
+					Payload: &currencycom.WsMarketDataEvent{
+						SymbolName: symbol,
+						Bid:        139.85,
+						Ofr:        139.92,
+						Timestamp:  1597850971558,
+					},
+				})
+			case streamDepth + "." + actionSubscribe:
+				_ = conn.WriteJSON(&response{
+					Status:      statusOK,
+					Destination: currencycom.WsDestinationDepth,
+					Payload: map[string]interface{}{
+						"symbol": symbol,
+						"data":   `{"ts":1597850971558,"bid":{"139.85":2,"139.8":5},"ofr":{"139.92":1}}`,
+					},
+				})
+			}
+		}
+	}
+}
+
+func (s *relayTestSuite) SetupTest() {
+	s.subscriptions = 0
+	s.destinations = nil
+	s.upstream = httptest.NewServer(http.HandlerFunc(s.serveUpstream))
+	s.relay = NewServer(currencycom.WithWsEndpoint(wsURL(s.upstream)))
+	mux := http.NewServeMux()
+	mux.Handle("/connect", s.relay)
+	s.relayServer = httptest.NewServer(mux)
+}
+
+func (s *relayTestSuite) TearDownTest() {
+	s.relayServer.Close()
+	s.relay.Close()
+	s.upstream.Close()
+}
+
+func (s *relayTestSuite) TestRelayMarketData() {
+	r := s.Require()
+	events := make(chan *currencycom.WsMarketDataEvent, 2)
+	errHandler := func(err error) {}
+	for i := 0; i < 2; i++ {
+		doneC, stopC, err := currencycom.WsMarketDataServe([]string{"TXN"}, func(event *currencycom.WsMarketDataEvent) {
+			events <- event
+		}, errHandler, currencycom.WithWsEndpoint(wsURL(s.relayServer)))
+		r.NoError(err)
+		defer func() {
+			close(stopC)
+			<-doneC
+		}()
+		r.Eventually(func() bool {
+			return s.relay.Subscribers("marketData")["TXN"] == i+1
+		}, time.Second, 10*time.Millisecond)
+	}
+
+	select {
+	case event := <-events:
+		r.Equal("TXN", event.SymbolName)
+		r.Equal(139.85, event.Bid)
+	case <-time.After(time.Second):
+		s.Fail("no event relayed")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r.Equal(1, s.subscriptions)
+}
+
+func (s *relayTestSuite) TestRelayRequests() {
+	r := s.Require()
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL(s.relayServer), nil)
+	r.NoError(err)
+	defer conn.Close()
+
+	r.NoError(conn.WriteMessage(websocket.TextMessage, []byte(`{"destination":"ping","correlationId":7,"payload":{}}`)))
+	res := new(currencycom.WsResponse)
+	r.NoError(conn.ReadJSON(res))
+	r.Equal(statusOK, res.Status)
+	r.Equal("ping", res.Destination)
+	r.Equal(currencycom.WsCorrelationID("7"), res.CorrelationID)
+
+	r.NoError(conn.WriteMessage(websocket.TextMessage, []byte(`{"destination":"unknown","correlationId":8,"payload":{}}`)))
+	res = new(currencycom.WsResponse)
+	r.NoError(conn.ReadJSON(res))
+	r.Equal(statusBadRequest, res.Status)
+	p := new(errorPayload)
+	r.NoError(json.Unmarshal(res.Payload, p))
+	r.Equal(int64(errorCodeBadRequest), p.ErrorCode)
+}
+
+func (s *relayTestSuite) TestRelayDepth() {
+	r := s.Require()
+	events := make(chan *currencycom.WsDepthEvent, 1)
+	doneC, stopC, err := currencycom.WsDepthServe([]string{"TXN"}, func(event *currencycom.WsDepthEvent) {
+		events <- event
+	}, func(err error) {}, currencycom.WithWsEndpoint(wsURL(s.relayServer)))
+	r.NoError(err)
+	defer func() {
+		close(stopC)
+		<-doneC
+	}()
+
+	select {
+	case event := <-events:
+		r.Equal("TXN", event.Symbol)
+		r.Equal(int64(1597850971558), event.Timestamp)
+		r.Equal(currencycom.PriceLevelList{{Price: 139.85, Quantity: 2}, {Price: 139.8, Quantity: 5}}, event.Bids)
+		r.Equal(currencycom.PriceLevelList{{Price: 139.92, Quantity: 1}}, event.Asks)
+	case <-time.After(time.Second):
+		s.Fail("no event relayed")
+	}
+}
+
+func (s *relayTestSuite) TestRelaySubscriptionResult() {
+	r := s.Require()
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL(s.relayServer), nil)
+	r.NoError(err)
+	defer conn.Close()
+
+	// the upstream reply is forwarded
+	r.NoError(conn.WriteMessage(websocket.TextMessage, []byte(`{"destination":"marketData.subscribe","correlationId":1,"payload":{"symbols":["INVALID"]}}`)))
+	res := new(currencycom.WsResponse)
+	r.NoError(conn.ReadJSON(res))
+	r.Equal(statusBadRequest, res.Status)
+	r.Equal(currencycom.WsCorrelationID("1"), res.CorrelationID)
+	p := new(errorPayload)
+	r.NoError(json.Unmarshal(res.Payload, p))
+	r.Equal(int64(-1121), p.ErrorCode)
+	r.Equal("Invalid symbol.", p.ErrorMessage)
+	r.Empty(s.relay.Subscribers("marketData"))
+
+	r.NoError(conn.WriteMessage(websocket.TextMessage, []byte(`{"destination":"marketData.subscribe","correlationId":2,"payload":{"symbols":["TXN"]}}`)))
+	for {
+		res = new(currencycom.WsResponse)
+		r.NoError(conn.ReadJSON(res))
+		if res.Destination == "marketData.subscribe" {
+			break
+		}
+	}
+	r.Equal(statusOK, res.Status)
+	r.JSONEq(`{"subscriptions":{"TXN":"OK"}}`, string(res.Payload))
+}
+
+// readReply returns the next reply to destination, skipping the events
+func (s *relayTestSuite) readReply(conn *websocket.Conn, destination string) *currencycom.WsResponse {
+	for {
+		res := new(currencycom.WsResponse)
+		s.Require().NoError(conn.ReadJSON(res))
+		if res.Destination == destination {
+			return res
+		}
+	}
+}
+
+func (s *relayTestSuite) TestRelayDuplicateSubscription() {
+	r := s.Require()
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL(s.relayServer), nil)
+	r.NoError(err)
+	defer conn.Close()
+
+	for i := 1; i <= 2; i++ {
+		r.NoError(conn.WriteMessage(websocket.TextMessage, []byte(`{"destination":"marketData.subscribe","correlationId":1,"payload":{"symbols":["TXN","TXN"]}}`)))
+		res := s.readReply(conn, "marketData.subscribe")
+		r.Equal(statusOK, res.Status)
+		r.JSONEq(`{"subscriptions":{"TXN":"OK"}}`, string(res.Payload))
+	}
+	// each event is delivered once to the client
+	r.Equal(map[string]int{"TXN": 1}, s.relay.Subscribers("marketData"))
+}
+
+func (s *relayTestSuite) TestRelayUnsubscribe() {
+	r := s.Require()
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL(s.relayServer), nil)
+	r.NoError(err)
+	defer conn.Close()
+
+	r.NoError(conn.WriteMessage(websocket.TextMessage, []byte(`{"destination":"marketData.subscribe","correlationId":1,"payload":{"symbols":["TXN","BTC/USD"]}}`)))
+	s.readReply(conn, "marketData.subscribe")
+	r.NoError(conn.WriteMessage(websocket.TextMessage, []byte(`{"destination":"marketData.unsubscribe","correlationId":2,"payload":{"symbols":["BTC/USD"]}}`)))
+	res := s.readReply(conn, "marketData.unsubscribe")
+	r.Equal(statusOK, res.Status)
+	r.Equal(currencycom.WsCorrelationID("2"), res.CorrelationID)
+	r.Equal(map[string]int{"TXN": 1}, s.relay.Subscribers("marketData"))
+	r.Eventually(func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return len(s.destinations) == 2 && s.destinations[1] == "marketData.unsubscribe"
+	}, time.Second, 10*time.Millisecond)
+
+	// the symbol can be subscribed again
+	r.NoError(conn.WriteMessage(websocket.TextMessage, []byte(`{"destination":"marketData.subscribe","correlationId":3,"payload":{"symbols":["BTC/USD"]}}`)))
+	s.readReply(conn, "marketData.subscribe")
+	r.Equal(map[string]int{"TXN": 1, "BTC/USD": 1}, s.relay.Subscribers("marketData"))
+}
+
+func (s *relayTestSuite) TestRelayCheckOrigin() {
+	r := s.Require()
+	header := http.Header{"Origin": []string{"https://example.com"}}
+	_, res, err := websocket.DefaultDialer.Dial(wsURL(s.relayServer), header)
+	r.ErrorIs(err, websocket.ErrBadHandshake)
+	r.Equal(http.StatusForbidden, res.StatusCode)
+
+	// same origin
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL(s.relayServer), http.Header{"Origin": []string{s.relayServer.URL}})
+	r.NoError(err)
+	conn.Close()
+
+	s.relay.CheckOrigin = func(r *http.Request) bool {
+		return r.Header.Get("Origin") == "https://example.com"
+	}
+	conn, _, err = websocket.DefaultDialer.Dial(wsURL(s.relayServer), header)
+	r.NoError(err)
+	conn.Close()
+}
@@ -0,0 +1,266 @@
+package go_currencycom
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RollingStats are the statistics of the trades of a symbol over a rolling window
+type RollingStats struct {
+	Symbol string
+	// OpenTime and CloseTime are the times of the first and last trades of the window
+	OpenTime           int64
+	CloseTime          int64
+	Open               float64
+	High               float64
+	Low                float64
+	Last               float64
+	Volume             float64
+	QuoteVolume        float64
+	Trades             int64
+	PriceChange        float64
+	PriceChangePercent float64
+}
+
+type rollingBucket struct {
+	start       int64
+	firstTime   int64
+	lastTime    int64
+	open        float64
+	high        float64
+	low         float64
+	close       float64
+	volume      float64
+	quoteVolume float64
+	trades      int64
+}
+
+func (b *rollingBucket) add(event *WsTradesEvent) {
+	if b.trades == 0 {
+		*b = rollingBucket{
+			start:     b.start,
+			firstTime: event.Timestamp,
+			lastTime:  event.Timestamp,
+			open:      event.Price,
+			high:      event.Price,
+			low:       event.Price,
+			close:     event.Price,
+		}
+	}
+	if event.Timestamp < b.firstTime {
+		b.firstTime, b.open = event.Timestamp, event.Price
+	}
+	if event.Timestamp >= b.lastTime {
+		b.lastTime, b.close = event.Timestamp, event.Price
+	}
+	b.high = math.Max(b.high, event.Price)
+	b.low = math.Min(b.low, event.Price)
+	b.volume += event.Size
+	b.quoteVolume += event.Size * event.Price
+	b.trades++
+}
+
+// rollingMark is the last seeded or the first live trade of a symbol
+type rollingMark struct {
+	id        int64
+	timestamp int64
+	set       bool
+}
+
+// compare returns -1, 0 or 1 as event is before, at or after the mark, by
+// trade ID when both have one, by timestamp otherwise
+func (m rollingMark) compare(event *WsTradesEvent) int {
+	a, b := event.Timestamp, m.timestamp
+	if event.ID != 0 && m.id != 0 {
+		a, b = event.ID, m.id
+	}
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+type rollingSymbol struct {
+	ring   []rollingBucket
+	seeded rollingMark
+	live   rollingMark
+}
+
+// RollingStatsTracker maintains rolling statistics per symbol from the trade
+// stream. Trades are aggregated in buckets of resolution kept in a ring
+// covering the window, so the memory of a symbol is fixed and the window
+// moves by steps of resolution.
+type RollingStatsTracker struct {
+	window     time.Duration
+	resolution time.Duration
+
+	mu      sync.RWMutex
+	symbols map[string]*rollingSymbol
+}
+
+// MaxRollingStatsBuckets is the largest ring a RollingStatsTracker accepts
+// per symbol, i.e. the window divided by the resolution
+var MaxRollingStatsBuckets int64 = 100000
+
+// NewRollingStatsTracker init a tracker, e.g. with a window of 24 hours and a
+// resolution of a minute. The resolution must be a whole number of
+// milliseconds no longer than the window, and split it into at most
+// MaxRollingStatsBuckets buckets.
+func NewRollingStatsTracker(window, resolution time.Duration) (*RollingStatsTracker, error) {
+	if resolution < time.Millisecond || resolution%time.Millisecond != 0 {
+		return nil, fmt.Errorf("invalid rolling stats resolution %s: not a positive number of milliseconds", resolution)
+	}
+	if window < resolution {
+		return nil, fmt.Errorf("invalid rolling stats window %s: shorter than the resolution %s", window, resolution)
+	}
+	t := &RollingStatsTracker{
+		window:     window,
+		resolution: resolution,
+		symbols:    make(map[string]*rollingSymbol),
+	}
+	if size := t.size(); size > MaxRollingStatsBuckets {
+		return nil, fmt.Errorf("invalid rolling stats resolution %s: %d buckets over the window %s, at most %d",
+			resolution, size, window, MaxRollingStatsBuckets)
+	}
+	return t, nil
+}
+
+func (t *RollingStatsTracker) size() int64 {
+	return int64((t.window + t.resolution - 1) / t.resolution)
+}
+
+// Handle add a trade of the stream. Trades older than the window of the ring
+// are dropped, and so are trades at or before the last trade seeded for the
+// symbol, which Seed already counted.
+func (t *RollingStatsTracker) Handle(event *WsTradesEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	sym := t.symbol(event.Symbol)
+	if sym.seeded.set && sym.seeded.compare(event) <= 0 {
+		return
+	}
+	if !sym.live.set {
+		sym.live = rollingMark{id: event.ID, timestamp: event.Timestamp, set: true}
+	}
+	t.add(sym, event)
+}
+
+func (t *RollingStatsTracker) symbol(symbol string) *rollingSymbol {
+	sym, ok := t.symbols[symbol]
+	if !ok {
+		sym = &rollingSymbol{ring: make([]rollingBucket, t.size())}
+		t.symbols[symbol] = sym
+	}
+	return sym
+}
+
+func (t *RollingStatsTracker) add(sym *rollingSymbol, event *WsTradesEvent) {
+	resolution := t.resolution.Milliseconds()
+	start := event.Timestamp - event.Timestamp%resolution
+	ring := sym.ring
+	b := &ring[(start/resolution)%int64(len(ring))]
+	switch {
+	case b.start > start:
+		return
+	case b.start < start:
+		*b = rollingBucket{start: start}
+	}
+	b.add(event)
+}
+
+// Handler returns Handle as a WsTradesHandler
+func (t *RollingStatsTracker) Handler() WsTradesHandler {
+	return t.Handle
+}
+
+// Seed add the aggregate trades of symbol over the window ending at now. It
+// can run before or after the stream starts: backfilled trades at or after
+// the first trade of the stream are skipped, and trades of the stream at or
+// before the last backfilled one are skipped afterwards.
+func (t *RollingStatsTracker) Seed(ctx context.Context, c *Client, symbol string, now time.Time) error {
+	trades, err := AggTradesBackfill(c)(ctx, symbol, -1, math.MaxInt64, now.Add(-t.window).UnixMilli(), now.UnixMilli())
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	sym := t.symbol(symbol)
+	for _, trade := range trades {
+		if sym.live.set && sym.live.compare(trade) >= 0 {
+			continue
+		}
+		t.add(sym, trade)
+		if !sym.seeded.set || sym.seeded.compare(trade) > 0 {
+			sym.seeded = rollingMark{id: trade.ID, timestamp: trade.Timestamp, set: true}
+		}
+	}
+	return err
+}
+
+// Stats returns the statistics of symbol over the window ending at now
+func (t *RollingStatsTracker) Stats(symbol string, now time.Time) (stats RollingStats, ok bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	sym, found := t.symbols[symbol]
+	if !found {
+		return RollingStats{}, false
+	}
+	return t.stats(symbol, sym.ring, now)
+}
+
+func (t *RollingStatsTracker) stats(symbol string, ring []rollingBucket, now time.Time) (stats RollingStats, ok bool) {
+	end := now.UnixMilli()
+	from := end - t.window.Milliseconds()
+	stats.Symbol = symbol
+	for i := range ring {
+		b := &ring[i]
+		if b.trades == 0 || b.start+t.resolution.Milliseconds() <= from || b.start > end {
+			continue
+		}
+		if !ok || b.firstTime < stats.OpenTime {
+			stats.OpenTime, stats.Open = b.firstTime, b.open
+		}
+		if !ok || b.lastTime >= stats.CloseTime {
+			stats.CloseTime, stats.Last = b.lastTime, b.close
+		}
+		if !ok || b.high > stats.High {
+			stats.High = b.high
+		}
+		if !ok || b.low < stats.Low {
+			stats.Low = b.low
+		}
+		stats.Volume += b.volume
+		stats.QuoteVolume += b.quoteVolume
+		stats.Trades += b.trades
+		ok = true
+	}
+	if !ok {
+		return RollingStats{}, false
+	}
+	stats.PriceChange = stats.Last - stats.Open
+	if stats.Open != 0 {
+		stats.PriceChangePercent = stats.PriceChange / stats.Open * 100
+	}
+	return stats, true
+}
+
+// Snapshot returns the statistics of every symbol traded during the window
+// ending at now, sorted by symbol
+func (t *RollingStatsTracker) Snapshot(now time.Time) []RollingStats {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	res := make([]RollingStats, 0, len(t.symbols))
+	for symbol, sym := range t.symbols {
+		if stats, ok := t.stats(symbol, sym.ring, now); ok {
+			res = append(res, stats)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Symbol < res[j].Symbol
+	})
+	return res
+}
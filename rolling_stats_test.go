@@ -0,0 +1,156 @@
+package go_currencycom
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type rollingStatsTestSuite struct {
+	baseTestSuite
+}
+
+func TestRollingStats(t *testing.T) {
+	suite.Run(t, new(rollingStatsTestSuite))
+}
+
+func (s *rollingStatsTestSuite) trade(t *RollingStatsTracker, symbol string, ts time.Time, price, size float64) {
+	t.Handle(&WsTradesEvent{Symbol: symbol, Timestamp: ts.UnixMilli(), Price: price, Size: size})
+}
+
+func (s *rollingStatsTestSuite) TestStats() {
+	tracker, err := NewRollingStatsTracker(time.Hour, time.Minute)
+	s.r().NoError(err)
+	start := time.Date(2023, 3, 13, 10, 0, 0, 0, time.UTC)
+	s.trade(tracker, "TXN", start.Add(30*time.Second), 100, 1)
+	s.trade(tracker, "TXN", start.Add(10*time.Minute), 110, 2)
+	s.trade(tracker, "TXN", start.Add(5*time.Minute), 90, 1) // late
+	s.trade(tracker, "TXN", start.Add(50*time.Minute), 105, 1)
+	s.trade(tracker, "BTC/USD", start.Add(20*time.Minute), 20000, 0.5)
+
+	r := s.r()
+	stats, ok := tracker.Stats("TXN", start.Add(55*time.Minute))
+	r.True(ok)
+	r.Equal(start.Add(30*time.Second).UnixMilli(), stats.OpenTime)
+	r.Equal(start.Add(50*time.Minute).UnixMilli(), stats.CloseTime)
+	r.Equal(100.0, stats.Open)
+	r.Equal(110.0, stats.High)
+	r.Equal(90.0, stats.Low)
+	r.Equal(105.0, stats.Last)
+	r.Equal(5.0, stats.Volume)
+	r.Equal(515.0, stats.QuoteVolume)
+	r.Equal(int64(4), stats.Trades)
+	r.Equal(5.0, stats.PriceChange)
+	r.Equal(5.0, stats.PriceChangePercent)
+
+	// the first minute leaves the window
+	stats, ok = tracker.Stats("TXN", start.Add(61*time.Minute))
+	r.True(ok)
+	r.Equal(90.0, stats.Open)
+	r.Equal(int64(3), stats.Trades)
+
+	_, ok = tracker.Stats("TXN", start.Add(2*time.Hour))
+	r.False(ok)
+	_, ok = tracker.Stats("ETH/USD", start)
+	r.False(ok)
+
+	snapshot := tracker.Snapshot(start.Add(55 * time.Minute))
+	r.Len(snapshot, 2)
+	r.Equal("BTC/USD", snapshot[0].Symbol)
+	r.Equal("TXN", snapshot[1].Symbol)
+}
+
+func (s *rollingStatsTestSuite) TestRingReuse() {
+	tracker, err := NewRollingStatsTracker(time.Hour, time.Minute)
+	s.r().NoError(err)
+	start := time.Date(2023, 3, 13, 10, 0, 0, 0, time.UTC)
+	s.trade(tracker, "TXN", start, 100, 1)
+	// same slot of the ring an hour later
+	s.trade(tracker, "TXN", start.Add(time.Hour), 120, 3)
+	// too old for the slot now in use
+	s.trade(tracker, "TXN", start.Add(time.Second), 80, 1)
+
+	stats, ok := tracker.Stats("TXN", start.Add(time.Hour))
+	r := s.r()
+	r.True(ok)
+	r.Equal(int64(1), stats.Trades)
+	r.Equal(120.0, stats.Open)
+	r.Equal(3.0, stats.Volume)
+}
+
+func (s *rollingStatsTestSuite) TestSeed() {
+	now := time.Date(2023, 3, 13, 10, 0, 0, 0, time.UTC)
+	data := []byte(`[
+		{"a": 1, "p": 100, "q": 1, "T": 1678698000000, "m": true},
+		{"a": 2, "p": 102, "q": 2, "T": 1678699800000, "m": false}
+	]`)
+	s.mockDo(data, nil)
+	defer s.assertDo()
+	s.assertReq(func(r *request) {
+		e := newRequest().setParams(params{
+			"symbol":    "TXN",
+			"startTime": now.Add(-24 * time.Hour).UnixMilli(),
+			"endTime":   now.UnixMilli(),
+			"limit":     1000,
+		})
+		s.assertRequestEqual(e, r)
+	})
+	tracker, err := NewRollingStatsTracker(24*time.Hour, time.Minute)
+	r := s.r()
+	r.NoError(err)
+	r.NoError(tracker.Seed(newContext(), s.client.Client, "TXN", now))
+	stats, ok := tracker.Stats("TXN", now)
+	r.True(ok)
+	r.Equal(int64(2), stats.Trades)
+	r.Equal(100.0, stats.Open)
+	r.Equal(102.0, stats.Last)
+	r.Equal(3.0, stats.Volume)
+}
+
+func (s *rollingStatsTestSuite) TestSeedOverlap() {
+	now := time.Date(2023, 3, 13, 10, 0, 0, 0, time.UTC)
+	s.client.Client.do = func(req *http.Request) (*http.Response, error) {
+		return newHTTPResponse([]byte(`[
+			{"a": 1, "p": 100, "q": 1, "T": 1678698000000, "m": true},
+			{"a": 2, "p": 102, "q": 2, "T": 1678699800000, "m": false},
+			{"a": 3, "p": 103, "q": 1, "T": 1678699900000, "m": false}
+		]`), http.StatusOK), nil
+	}
+	r := s.r()
+
+	// live trades after seeding: the backfilled ones are skipped
+	tracker, err := NewRollingStatsTracker(24*time.Hour, time.Minute)
+	r.NoError(err)
+	r.NoError(tracker.Seed(newContext(), s.client.Client, "TXN", now))
+	tracker.Handle(&WsTradesEvent{Symbol: "TXN", ID: 3, Timestamp: 1678699900000, Price: 103, Size: 1})
+	tracker.Handle(&WsTradesEvent{Symbol: "TXN", ID: 4, Timestamp: 1678699950000, Price: 104, Size: 1})
+	stats, _ := tracker.Stats("TXN", now)
+	r.Equal(int64(4), stats.Trades)
+	r.Equal(5.0, stats.Volume)
+
+	// seeding after the stream started: the backfill stops before the first live trade
+	tracker, err = NewRollingStatsTracker(24*time.Hour, time.Minute)
+	r.NoError(err)
+	tracker.Handle(&WsTradesEvent{Symbol: "TXN", ID: 2, Timestamp: 1678699800000, Price: 102, Size: 2})
+	tracker.Handle(&WsTradesEvent{Symbol: "TXN", ID: 3, Timestamp: 1678699900000, Price: 103, Size: 1})
+	r.NoError(tracker.Seed(newContext(), s.client.Client, "TXN", now))
+	stats, _ = tracker.Stats("TXN", now)
+	r.Equal(int64(3), stats.Trades)
+	r.Equal(4.0, stats.Volume)
+	r.Equal(100.0, stats.Open)
+}
+
+func (s *rollingStatsTestSuite) TestInvalidResolution() {
+	r := s.r()
+	for _, resolution := range []time.Duration{0, -time.Minute, time.Microsecond, 1500 * time.Microsecond, 2 * time.Hour} {
+		_, err := NewRollingStatsTracker(time.Hour, resolution)
+		r.Error(err, resolution)
+	}
+	// 86.4M buckets
+	_, err := NewRollingStatsTracker(24*time.Hour, time.Millisecond)
+	r.ErrorContains(err, "86400000 buckets")
+	_, err = NewRollingStatsTracker(24*time.Hour, time.Second)
+	r.NoError(err)
+}
@@ -0,0 +1,123 @@
+package go_currencycom
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var (
+	// ErrNoLiquidity is returned when the side of the book an order would take is empty
+	ErrNoLiquidity = errors.New("no liquidity")
+	// ErrInvalidQuantity is returned for a quantity that is not positive
+	ErrInvalidQuantity = errors.New("invalid quantity")
+)
+
+// SlippageEstimate is the expected fill of a market order against an order book
+type SlippageEstimate struct {
+	Side     SideType
+	Quantity float64
+	// Filled is the quantity available in the book, lower than Quantity if Insufficient
+	Filled float64
+	// VWAP is the average price of the filled quantity
+	VWAP float64
+	// BestPrice is the price of the first level taken
+	BestPrice float64
+	// WorstPrice is the price of the last level taken
+	WorstPrice float64
+	// SlippageTicks is the distance between VWAP and BestPrice in ticks, 0 without tick size
+	SlippageTicks float64
+	// SlippageBps is the distance between VWAP and BestPrice in basis points of BestPrice
+	SlippageBps float64
+	// Levels is the number of price levels taken
+	Levels int
+	// Insufficient is set when the book cannot fill the whole quantity
+	Insufficient bool
+}
+
+// SlippageError is returned when an order would slip more than its budget
+type SlippageError struct {
+	Estimate *SlippageEstimate
+	MaxBps   float64
+}
+
+// Error return the estimated and allowed slippage
+func (e *SlippageError) Error() string {
+	if e.Estimate.Insufficient {
+		return fmt.Sprintf("insufficient depth: %v of %v available", e.Estimate.Filled, e.Estimate.Quantity)
+	}
+	return fmt.Sprintf("slippage %.2f bps exceeds budget of %.2f bps", e.Estimate.SlippageBps, e.MaxBps)
+}
+
+// IsSlippageError check if e is a slippage error
+func IsSlippageError(e error) bool {
+	var err *SlippageError
+	return errors.As(e, &err)
+}
+
+// EstimateSlippage walk the asks of depth for a buy, or the bids for a sell,
+// until quantity is filled. tickSize is optional, SlippageTicks is 0 if it is not positive.
+func EstimateSlippage(depth *DepthResponse, side SideType, quantity, tickSize float64) (*SlippageEstimate, error) {
+	if quantity <= 0 {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQuantity, quantity)
+	}
+	var levels PriceLevelList
+	if side == SideTypeBuy {
+		levels = append(levels, depth.Asks...)
+		sort.Sort(levels)
+	} else {
+		levels = append(levels, depth.Bids...)
+		sort.Sort(sort.Reverse(levels))
+	}
+	if len(levels) == 0 {
+		return nil, ErrNoLiquidity
+	}
+
+	e := &SlippageEstimate{
+		Side:      side,
+		Quantity:  quantity,
+		BestPrice: levels[0].Price,
+	}
+	var notional float64
+	for _, level := range levels {
+		if e.Filled >= quantity {
+			break
+		}
+		size := level.Quantity
+		if remaining := quantity - e.Filled; size > remaining {
+			size = remaining
+		}
+		if size <= 0 {
+			continue
+		}
+		notional += size * level.Price
+		e.Filled += size
+		e.WorstPrice = level.Price
+		e.Levels++
+	}
+	if e.Filled == 0 {
+		return nil, ErrNoLiquidity
+	}
+	e.Insufficient = e.Filled < quantity
+	e.VWAP = notional / e.Filled
+
+	diff := e.VWAP - e.BestPrice
+	if side != SideTypeBuy {
+		diff = -diff
+	}
+	if e.BestPrice != 0 {
+		e.SlippageBps = diff / e.BestPrice * 1e4
+	}
+	if tickSize > 0 {
+		e.SlippageTicks = diff / tickSize
+	}
+	return e, nil
+}
+
+// Check returns a SlippageError if the estimate is insufficient or slips more than maxBps
+func (e *SlippageEstimate) Check(maxBps float64) error {
+	if e.Insufficient || e.SlippageBps > maxBps {
+		return &SlippageError{Estimate: e, MaxBps: maxBps}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package go_currencycom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type slippageTestSuite struct {
+	baseTestSuite
+}
+
+func TestSlippage(t *testing.T) {
+	suite.Run(t, new(slippageTestSuite))
+}
+
+func (s *slippageTestSuite) depth() *DepthResponse {
+	return &DepthResponse{
+		Bids: []Bid{{Price: 99, Quantity: 1}, {Price: 100, Quantity: 2}, {Price: 98, Quantity: 5}},
+		Asks: []Ask{{Price: 102, Quantity: 3}, {Price: 101, Quantity: 1}},
+	}
+}
+
+func (s *slippageTestSuite) TestEstimateBuy() {
+	e, err := EstimateSlippage(s.depth(), SideTypeBuy, 3, 0.5)
+	r := s.r()
+	r.NoError(err)
+	r.Equal(3.0, e.Filled)
+	r.False(e.Insufficient)
+	r.Equal(101.0, e.BestPrice)
+	r.Equal(102.0, e.WorstPrice)
+	r.Equal(2, e.Levels)
+	r.InDelta(305.0/3, e.VWAP, 1e-9)
+	r.InDelta((305.0/3-101)/0.5, e.SlippageTicks, 1e-9)
+	r.InDelta((305.0/3-101)/101*1e4, e.SlippageBps, 1e-9)
+}
+
+func (s *slippageTestSuite) TestEstimateSell() {
+	e, err := EstimateSlippage(s.depth(), SideTypeSell, 10, 0)
+	r := s.r()
+	r.NoError(err)
+	r.True(e.Insufficient)
+	r.Equal(8.0, e.Filled)
+	r.Equal(100.0, e.BestPrice)
+	r.Equal(98.0, e.WorstPrice)
+	r.Equal(3, e.Levels)
+	r.InDelta(789.0/8, e.VWAP, 1e-9)
+	r.Zero(e.SlippageTicks)
+	r.InDelta((100-789.0/8)/100*1e4, e.SlippageBps, 1e-9)
+	r.True(IsSlippageError(e.Check(1000)))
+}
+
+func (s *slippageTestSuite) TestEstimateEmpty() {
+	_, err := EstimateSlippage(&DepthResponse{}, SideTypeBuy, 1, 0)
+	s.r().Equal(ErrNoLiquidity, err)
+}
+
+func (s *slippageTestSuite) TestEstimateInvalidQuantity() {
+	_, err := EstimateSlippage(s.depth(), SideTypeBuy, 0, 0)
+	s.r().ErrorIs(err, ErrInvalidQuantity)
+	_, err = EstimateSlippage(s.depth(), SideTypeSell, -1, 0)
+	s.r().ErrorIs(err, ErrInvalidQuantity)
+}
+
+func (s *slippageTestSuite) TestCheck() {
+	e, err := EstimateSlippage(s.depth(), SideTypeBuy, 1, 0)
+	s.r().NoError(err)
+	s.r().Zero(e.SlippageBps)
+	s.r().NoError(e.Check(0))
+}
+
+func (s *slippageTestSuite) TestCreateOrderMaxSlippage() {
+	data := []byte(`{
+        "lastUpdateId": 1027024,
+        "asks": [[101, 1], [102, 3]],
+        "bids": [[100, 2]]
+    }`)
+	s.mockDo(data, nil)
+	defer s.assertDo()
+	s.assertReq(func(r *request) {
+		e := newRequest().setParam("symbol", "BTC/USD_LEVERAGE")
+		s.assertRequestEqual(e, r)
+	})
+	_, err := s.client.NewCreateOrderService().Symbol("BTC/USD_LEVERAGE").
+		Side(SideTypeBuy).Type(OrderTypeMarket).Quantity(3).
+		MaxSlippage(10).Do(newContext())
+	s.r().True(IsSlippageError(err))
+	s.r().Contains(err.Error(), "exceeds budget of 10.00 bps")
+}
+
+func (s *slippageTestSuite) TestCreateOrderEstimateSlippageTicks() {
+	data := []byte(`{
+        "lastUpdateId": 1027024,
+        "asks": [[101, 1], [102, 3]],
+        "bids": [[100, 2]]
+    }`)
+	s.mockDo(data, nil)
+	defer s.assertDo()
+	e, err := s.client.NewCreateOrderService().Symbol("BTC/USD_LEVERAGE").
+		Side(SideTypeBuy).Type(OrderTypeMarket).Quantity(2).
+		TickSize(0.25).EstimateSlippage(newContext())
+	r := s.r()
+	r.NoError(err)
+	r.Equal(101.5, e.VWAP)
+	r.Equal(2.0, e.SlippageTicks)
+}
@@ -0,0 +1,164 @@
+package go_currencycom
+
+import (
+	"fmt"
+	"time"
+)
+
+// ForexSwapMultipliers charge no swap on weekends and a triple swap on
+// Wednesday, as forex markets settle the weekend two days after the trade
+var ForexSwapMultipliers = map[time.Weekday]float64{
+	time.Wednesday: 3,
+	time.Saturday:  0,
+	time.Sunday:    0,
+}
+
+// SwapCharge is a swap charge of a position
+type SwapCharge struct {
+	Time       time.Time
+	Multiplier float64
+	// Amount is in the quote asset, negative when the position pays
+	Amount float64
+}
+
+// SwapEstimate is the swap a position would be charged over a holding period
+type SwapEstimate struct {
+	Symbol   string
+	Side     SideType
+	Quantity float64
+	Price    float64
+	// Rate is the percentage of the notional charged per charge interval
+	Rate    float64
+	Charges []SwapCharge
+	// Total is the sum of the charges in Currency, the quote asset of the symbol
+	Total    float64
+	Currency string
+	// Converted is Total in the account currency, nil without converter
+	Converted *Conversion
+}
+
+// SwapCalculatorConfig define how swaps are charged
+type SwapCalculatorConfig struct {
+	// ChargeTime is the offset from midnight in Location of the daily charges,
+	// shorter intervals are charged every SwapChargeInterval from it
+	ChargeTime time.Duration
+	// Location defaults to UTC
+	Location *time.Location
+	// Multipliers of the charges by weekday, 1 for days not set.
+	// See ForexSwapMultipliers.
+	Multipliers map[time.Weekday]float64
+	// Converter and AccountCurrency convert the total to the account currency
+	Converter       *CurrencyConverter
+	AccountCurrency string
+}
+
+// SwapCalculator estimates the swap of leverage positions from the LongRate,
+// ShortRate and SwapChargeInterval of their symbol. Rates are percentages of
+// the notional, quantity times price, charged every SwapChargeInterval
+// minutes, e.g. -0.0165 per 1440 minutes is 0.0165% a day; a negative rate
+// is a cost. The notional is valued at a single price over the whole
+// period, while the exchange charges the notional at the time of each
+// charge: the estimate is as good as that price.
+type SwapCalculator struct {
+	cfg SwapCalculatorConfig
+}
+
+// NewSwapCalculator init a calculator
+func NewSwapCalculator(cfg SwapCalculatorConfig) *SwapCalculator {
+	if cfg.Location == nil {
+		cfg.Location = time.UTC
+	}
+	return &SwapCalculator{cfg: cfg}
+}
+
+// Estimate the swap of a position of quantity valued at price on side,
+// charged after from until to included
+func (c *SwapCalculator) Estimate(info *ExchangeSymbolInfo, side SideType, quantity, price float64, from, to time.Time) (*SwapEstimate, error) {
+	res := &SwapEstimate{
+		Symbol:   info.Symbol,
+		Side:     side,
+		Quantity: quantity,
+		Price:    price,
+		Charges:  []SwapCharge{},
+		Currency: info.QuoteAsset,
+	}
+	switch side {
+	case SideTypeBuy:
+		res.Rate = info.LongRate
+	case SideTypeSell:
+		res.Rate = info.ShortRate
+	default:
+		return nil, fmt.Errorf("invalid side: %q", side)
+	}
+	notional := quantity * price
+	for _, t := range c.chargeTimes(info.SwapChargeInterval, from, to) {
+		multiplier, ok := c.cfg.Multipliers[t.Weekday()]
+		if !ok {
+			multiplier = 1
+		}
+		if multiplier == 0 {
+			continue
+		}
+		charge := SwapCharge{Time: t, Multiplier: multiplier, Amount: notional * res.Rate / 100 * multiplier}
+		res.Charges = append(res.Charges, charge)
+		res.Total += charge.Amount
+	}
+	if c.cfg.Converter != nil && c.cfg.AccountCurrency != "" {
+		conversion, err := c.cfg.Converter.Convert(res.Total, res.Currency, c.cfg.AccountCurrency)
+		if err != nil {
+			return nil, err
+		}
+		res.Converted = conversion
+	}
+	return res, nil
+}
+
+// EstimatePosition estimate the swap of an open position from from until to.
+// The side is the sign of OpenQuantity and the notional is valued at price,
+// the current or mark price of the symbol, or at OpenPrice if price is 0.
+func (c *SwapCalculator) EstimatePosition(info *ExchangeSymbolInfo, position *TradingPositionDto, price float64, from, to time.Time) (*SwapEstimate, error) {
+	side, quantity := SideTypeBuy, position.OpenQuantity
+	if quantity < 0 {
+		side, quantity = SideTypeSell, -quantity
+	}
+	if price == 0 {
+		price = position.OpenPrice
+	}
+	res, err := c.Estimate(info, side, quantity, price, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("position %s: %w", position.ID, err)
+	}
+	return res, nil
+}
+
+// chargeTimes returns the charge times after from until to included, none if
+// interval, in minutes, is not positive
+func (c *SwapCalculator) chargeTimes(interval int64, from, to time.Time) []time.Time {
+	res := make([]time.Time, 0)
+	if interval <= 0 || !to.After(from) {
+		return res
+	}
+	step := time.Duration(interval) * time.Minute
+	// daily intervals keep the wall clock time across DST changes
+	days := 0
+	if step%oneDay == 0 {
+		days = int(step / oneDay)
+	}
+	next := func(t time.Time) time.Time {
+		if days > 0 {
+			return t.AddDate(0, 0, days)
+		}
+		return t.Add(step)
+	}
+
+	y, m, d := from.In(c.cfg.Location).Date()
+	// start a day early, the charge time of the day of from may come after it
+	t := time.Date(y, m, d-1, 0, 0, 0, 0, c.cfg.Location).Add(c.cfg.ChargeTime)
+	for !t.After(from) {
+		t = next(t)
+	}
+	for ; !t.After(to); t = next(t) {
+		res = append(res, t)
+	}
+	return res
+}
@@ -0,0 +1,157 @@
+package go_currencycom
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type swapCalculatorTestSuite struct {
+	baseTestSuite
+}
+
+func TestSwapCalculator(t *testing.T) {
+	suite.Run(t, new(swapCalculatorTestSuite))
+}
+
+var swapSymbolInfo = &ExchangeSymbolInfo{
+	Symbol:             "BTC/USD_LEVERAGE",
+	BaseAsset:          "BTC",
+	QuoteAsset:         "USD",
+	LongRate:           -0.1,
+	ShortRate:          0.05,
+	SwapChargeInterval: 1440,
+}
+
+func (s *swapCalculatorTestSuite) TestEstimate() {
+	calculator := NewSwapCalculator(SwapCalculatorConfig{
+		ChargeTime:  22 * time.Hour,
+		Multipliers: ForexSwapMultipliers,
+	})
+	r := s.r()
+	res, err := calculator.Estimate(swapSymbolInfo, SideTypeBuy, 2, 100, utcTime(1, 10, 0), utcTime(8, 10, 0))
+	r.NoError(err)
+	r.Equal(-0.1, res.Rate)
+	r.Equal("USD", res.Currency)
+	r.Len(res.Charges, 5)
+	r.Equal(utcTime(1, 22, 0), res.Charges[0].Time)
+	r.Equal(1.0, res.Charges[0].Multiplier)
+	r.InDelta(-0.2, res.Charges[0].Amount, 1e-12)
+	r.Equal(utcTime(3, 22, 0), res.Charges[2].Time)
+	r.Equal(3.0, res.Charges[2].Multiplier)
+	r.InDelta(-0.6, res.Charges[2].Amount, 1e-12)
+	r.Equal(utcTime(5, 22, 0), res.Charges[4].Time)
+	r.InDelta(-1.4, res.Total, 1e-12)
+	r.Nil(res.Converted)
+
+	res, err = calculator.Estimate(swapSymbolInfo, SideTypeSell, 2, 100, utcTime(1, 10, 0), utcTime(8, 10, 0))
+	r.NoError(err)
+	r.InDelta(0.7, res.Total, 1e-12)
+
+	// charged after from until to included
+	res, err = calculator.Estimate(swapSymbolInfo, SideTypeBuy, 1, 100, utcTime(1, 22, 0), utcTime(2, 22, 0))
+	r.NoError(err)
+	r.Len(res.Charges, 1)
+	r.Equal(utcTime(2, 22, 0), res.Charges[0].Time)
+
+	res, err = calculator.Estimate(swapSymbolInfo, SideTypeBuy, 1, 100, utcTime(2, 22, 0), utcTime(1, 22, 0))
+	r.NoError(err)
+	r.Empty(res.Charges)
+
+	_, err = calculator.Estimate(swapSymbolInfo, SideType("HOLD"), 1, 100, utcTime(1, 0, 0), utcTime(2, 0, 0))
+	r.EqualError(err, `invalid side: "HOLD"`)
+}
+
+func (s *swapCalculatorTestSuite) TestChargeInterval() {
+	calculator := NewSwapCalculator(SwapCalculatorConfig{})
+	info := *swapSymbolInfo
+	info.SwapChargeInterval = 480
+	r := s.r()
+	res, err := calculator.Estimate(&info, SideTypeBuy, 1, 100, utcTime(6, 0, 0), utcTime(7, 0, 0))
+	r.NoError(err)
+	r.Len(res.Charges, 3)
+	r.Equal(utcTime(6, 8, 0), res.Charges[0].Time)
+	r.Equal(utcTime(6, 16, 0), res.Charges[1].Time)
+	r.Equal(utcTime(7, 0, 0), res.Charges[2].Time)
+	r.Equal(1.0, res.Charges[2].Multiplier)
+
+	// no swap without charge interval
+	info.SwapChargeInterval = 0
+	res, err = calculator.Estimate(&info, SideTypeBuy, 1, 100, utcTime(6, 0, 0), utcTime(7, 0, 0))
+	r.NoError(err)
+	r.Empty(res.Charges)
+	r.Zero(res.Total)
+}
+
+func (s *swapCalculatorTestSuite) TestEstimatePosition() {
+	converter := NewCurrencyConverter(s.client.Client, &ExchangeInfo{Symbols: []ExchangeSymbolInfo{
+		{Symbol: "EUR/USD", BaseAsset: "EUR", QuoteAsset: "USD"},
+	}})
+	converter.SetQuote("EUR/USD", 1.1, 1.25, utcTime(1, 0, 0))
+	calculator := NewSwapCalculator(SwapCalculatorConfig{
+		ChargeTime:      22 * time.Hour,
+		Multipliers:     ForexSwapMultipliers,
+		Converter:       converter,
+		AccountCurrency: "EUR",
+	})
+	position := &TradingPositionDto{ID: "1", Symbol: "BTC/USD_LEVERAGE", OpenQuantity: -2, OpenPrice: 100}
+	r := s.r()
+	res, err := calculator.EstimatePosition(swapSymbolInfo, position, 0, utcTime(1, 10, 0), utcTime(8, 10, 0))
+	r.NoError(err)
+	r.Equal(100.0, res.Price)
+	r.Equal(SideTypeSell, res.Side)
+	r.Equal(2.0, res.Quantity)
+	r.InDelta(0.7, res.Total, 1e-12)
+	r.Equal("EUR", res.Converted.To)
+	r.InDelta(0.56, res.Converted.Result, 1e-12)
+	r.Equal("EUR/USD", res.Converted.Path[0].Symbol)
+
+	// valued at the current price
+	res, err = calculator.EstimatePosition(swapSymbolInfo, position, 120, utcTime(1, 10, 0), utcTime(8, 10, 0))
+	r.NoError(err)
+	r.InDelta(0.84, res.Total, 1e-12)
+
+	calculator = NewSwapCalculator(SwapCalculatorConfig{Converter: converter, AccountCurrency: "GBP"})
+	_, err = calculator.EstimatePosition(swapSymbolInfo, position, 0, utcTime(1, 10, 0), utcTime(8, 10, 0))
+	r.True(errors.Is(err, ErrNoConversionPath))
+	r.EqualError(err, "position 1: no conversion path from USD to GBP")
+}
+
+// TestPositionSwap checks the estimate against the swap of a position after
+// one daily charge, decoded as the exchange info and trading positions
+// endpoints answer them: rates are percentages of the notional
+func (s *swapCalculatorTestSuite) TestPositionSwap() {
+	info := new(ExchangeSymbolInfo)
+	r := s.r()
+	r.NoError(json.Unmarshal([]byte(`{
+		"symbol": "BTC/USD_LEVERAGE",
+		"baseAsset": "BTC",
+		"quoteAsset": "USD",
+		"marketType": "LEVERAGE",
+		"longRate": -0.0165,
+		"shortRate": -0.0035,
+		"swapChargeInterval": 1440
+	}`), info))
+	positions := new(ListTradingPositionsResponse)
+	r.NoError(json.Unmarshal([]byte(`{"positions": [{
+		"id": "00a02503-0079-54c4-0000-00004067006b",
+		"symbol": "BTC/USD_LEVERAGE",
+		"currency": "USD",
+		"openQuantity": 0.5,
+		"openPrice": 40000,
+		"openTimestamp": 1704103200000,
+		"swap": -3.3,
+		"state": "ACTIVE",
+		"type": "NET"
+	}]}`), positions))
+	position := &positions.Positions[0]
+
+	calculator := NewSwapCalculator(SwapCalculatorConfig{ChargeTime: 22 * time.Hour})
+	opened := time.UnixMilli(position.OpenTimestamp)
+	res, err := calculator.EstimatePosition(info, position, 0, opened, opened.Add(oneDay))
+	r.NoError(err)
+	r.Len(res.Charges, 1)
+	r.InDelta(position.Swap, res.Total, 1e-9)
+}
@@ -0,0 +1,384 @@
+package go_currencycom
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Symbol filter types
+const (
+	SymbolFilterTypeLotSize     = "LOT_SIZE"
+	SymbolFilterTypeMinNotional = "MIN_NOTIONAL"
+	SymbolFilterTypePrice       = "PRICE_FILTER"
+)
+
+// SymbolFilters are the trading rules of a symbol read from its filters
+type SymbolFilters struct {
+	TickSize    float64
+	StepSize    float64
+	MinQty      float64
+	MaxQty      float64
+	MinNotional float64
+}
+
+// SymbolFilters parse the filters of the symbol. TickSize defaults to the
+// tick size of the symbol if no price filter sets it.
+func (s *ExchangeSymbolInfo) SymbolFilters() (SymbolFilters, error) {
+	res := SymbolFilters{TickSize: s.TickSize}
+	for _, f := range s.Filters {
+		var err error
+		switch f.FilterType {
+		case SymbolFilterTypeLotSize:
+			err = parseFilterValues(f.FilterType, []filterValue{
+				{f.MinQty, &res.MinQty},
+				{f.MaxQty, &res.MaxQty},
+				{f.StepSize, &res.StepSize},
+			})
+		case SymbolFilterTypeMinNotional:
+			err = parseFilterValues(f.FilterType, []filterValue{{f.MinNotional, &res.MinNotional}})
+		case SymbolFilterTypePrice:
+			err = parseFilterValues(f.FilterType, []filterValue{{f.TickSize, &res.TickSize}})
+		}
+		if err != nil {
+			return SymbolFilters{}, fmt.Errorf("%s: %w", s.Symbol, err)
+		}
+	}
+	return res, nil
+}
+
+type filterValue struct {
+	value string
+	dst   *float64
+}
+
+// parseFilterValues parse the non empty values into their destination
+func parseFilterValues(filterType string, values []filterValue) error {
+	for _, v := range values {
+		if v.value == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(v.value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s filter value %q", filterType, v.value)
+		}
+		*v.dst = f
+	}
+	return nil
+}
+
+// SymbolRegistry caches the exchange info and indexes its symbols. The cache
+// is refreshed by Refresh, by ExchangeInfo once it is older than the TTL, or
+// in the background between Start and Stop.
+type SymbolRegistry struct {
+	c          *Client
+	ttl        time.Duration
+	errHandler ErrHandler
+
+	mu           sync.RWMutex
+	info         *ExchangeInfo
+	fetched      time.Time
+	bySymbol     map[string]*ExchangeSymbolInfo
+	filters      map[string]SymbolFilters
+	schedules    map[string]*TradingSchedule
+	byBaseAsset  map[string][]*ExchangeSymbolInfo
+	byQuoteAsset map[string][]*ExchangeSymbolInfo
+	byAssetType  map[string][]*ExchangeSymbolInfo
+	byMarketType map[string][]*ExchangeSymbolInfo
+	byMarketMode map[string][]*ExchangeSymbolInfo
+	bySector     map[string][]*ExchangeSymbolInfo
+	byIndustry   map[string][]*ExchangeSymbolInfo
+
+	refreshMu  sync.Mutex
+	refreshing *registryRefresh
+
+	stopC chan struct{}
+	wg    sync.WaitGroup
+}
+
+// registryRefresh is a download of the exchange info shared by its callers
+type registryRefresh struct {
+	doneC chan struct{}
+	err   error
+}
+
+// NewSymbolRegistry init an empty registry refreshed every ttl. errHandler
+// receives the errors of the background refresh and the symbols whose
+// filters or trading hours cannot be parsed.
+func NewSymbolRegistry(c *Client, ttl time.Duration, errHandler ErrHandler) (*SymbolRegistry, error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("invalid symbol registry ttl %s", ttl)
+	}
+	return &SymbolRegistry{c: c, ttl: ttl, errHandler: errHandler}, nil
+}
+
+// Refresh download the exchange info and rebuild the indexes. Concurrent
+// calls share a single download.
+func (r *SymbolRegistry) Refresh(ctx context.Context, opts ...RequestOption) error {
+	r.refreshMu.Lock()
+	refresh := r.refreshing
+	if refresh == nil {
+		refresh = &registryRefresh{doneC: make(chan struct{})}
+		r.refreshing = refresh
+		r.refreshMu.Unlock()
+		var info *ExchangeInfo
+		info, refresh.err = r.c.NewExchangeInfoService().Do(ctx, opts...)
+		if refresh.err == nil {
+			r.Set(info)
+		}
+		r.refreshMu.Lock()
+		r.refreshing = nil
+		r.refreshMu.Unlock()
+		close(refresh.doneC)
+		return refresh.err
+	}
+	r.refreshMu.Unlock()
+	select {
+	case <-refresh.doneC:
+		return refresh.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Set replace the cached exchange info, e.g. with one loaded from disk. The
+// symbols whose filters or trading hours cannot be parsed are reported to
+// the error handler and left out of Filters and TradingSchedule, as are the
+// symbols without trading hours.
+func (r *SymbolRegistry) Set(info *ExchangeInfo) {
+	var errs []error
+	bySymbol := make(map[string]*ExchangeSymbolInfo, len(info.Symbols))
+	filters := make(map[string]SymbolFilters, len(info.Symbols))
+	schedules := make(map[string]*TradingSchedule, len(info.Symbols))
+	byBaseAsset := make(map[string][]*ExchangeSymbolInfo)
+	byQuoteAsset := make(map[string][]*ExchangeSymbolInfo)
+	byAssetType := make(map[string][]*ExchangeSymbolInfo)
+	byMarketType := make(map[string][]*ExchangeSymbolInfo)
+	byMarketMode := make(map[string][]*ExchangeSymbolInfo)
+	bySector := make(map[string][]*ExchangeSymbolInfo)
+	byIndustry := make(map[string][]*ExchangeSymbolInfo)
+	for i := range info.Symbols {
+		s := &info.Symbols[i]
+		bySymbol[s.Symbol] = s
+		if f, err := s.SymbolFilters(); err == nil {
+			filters[s.Symbol] = f
+		} else {
+			errs = append(errs, err)
+		}
+		if s.TradingHours != "" {
+			if schedule, err := s.TradingSchedule(); err == nil {
+				schedules[s.Symbol] = schedule
+			} else {
+				errs = append(errs, fmt.Errorf("%s: %w", s.Symbol, err))
+			}
+		}
+		byBaseAsset[s.BaseAsset] = append(byBaseAsset[s.BaseAsset], s)
+		byQuoteAsset[s.QuoteAsset] = append(byQuoteAsset[s.QuoteAsset], s)
+		byAssetType[s.AssetType] = append(byAssetType[s.AssetType], s)
+		byMarketType[s.MarketType] = append(byMarketType[s.MarketType], s)
+		for _, mode := range s.MarketModes {
+			byMarketMode[mode] = append(byMarketMode[mode], s)
+		}
+		if s.Sector != "" {
+			bySector[s.Sector] = append(bySector[s.Sector], s)
+		}
+		if s.Industry != "" {
+			byIndustry[s.Industry] = append(byIndustry[s.Industry], s)
+		}
+	}
+
+	r.mu.Lock()
+	r.info = info
+	r.fetched = time.Now()
+	r.bySymbol = bySymbol
+	r.filters = filters
+	r.schedules = schedules
+	r.byBaseAsset = byBaseAsset
+	r.byQuoteAsset = byQuoteAsset
+	r.byAssetType = byAssetType
+	r.byMarketType = byMarketType
+	r.byMarketMode = byMarketMode
+	r.bySector = bySector
+	r.byIndustry = byIndustry
+	r.mu.Unlock()
+
+	if r.errHandler != nil {
+		for _, err := range errs {
+			r.errHandler(err)
+		}
+	}
+}
+
+// Fresh reports whether the cache is younger than the TTL
+func (r *SymbolRegistry) Fresh() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.info != nil && time.Since(r.fetched) < r.ttl
+}
+
+// ExchangeInfo returns the cached exchange info, refreshing it first if it
+// is missing or older than the TTL
+func (r *SymbolRegistry) ExchangeInfo(ctx context.Context, opts ...RequestOption) (*ExchangeInfo, error) {
+	if !r.Fresh() {
+		if err := r.Refresh(ctx, opts...); err != nil {
+			return nil, err
+		}
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.info, nil
+}
+
+// Start refresh the cache every TTL until Stop is called
+func (r *SymbolRegistry) Start() {
+	r.stopC = make(chan struct{})
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(r.ttl)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), r.ttl)
+				err := r.Refresh(ctx)
+				cancel()
+				if err != nil && r.errHandler != nil {
+					r.errHandler(err)
+				}
+			case <-r.stopC:
+				return
+			}
+		}
+	}()
+}
+
+// Stop the background refresh
+func (r *SymbolRegistry) Stop() {
+	if r.stopC == nil {
+		return
+	}
+	close(r.stopC)
+	r.wg.Wait()
+	r.stopC = nil
+}
+
+// Symbol returns the info of symbol
+func (r *SymbolRegistry) Symbol(symbol string) (info *ExchangeSymbolInfo, ok bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	info, ok = r.bySymbol[symbol]
+	return info, ok
+}
+
+// Filters returns the parsed filters of symbol
+func (r *SymbolRegistry) Filters(symbol string) (filters SymbolFilters, ok bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	filters, ok = r.filters[symbol]
+	return filters, ok
+}
+
+// TickSize returns the tick size of symbol
+func (r *SymbolRegistry) TickSize(symbol string) (tickSize float64, ok bool) {
+	filters, ok := r.Filters(symbol)
+	return filters.TickSize, ok
+}
+
+// StepSize returns the quantity step of symbol
+func (r *SymbolRegistry) StepSize(symbol string) (stepSize float64, ok bool) {
+	filters, ok := r.Filters(symbol)
+	return filters.StepSize, ok
+}
+
+// MinQty returns the minimum quantity of symbol
+func (r *SymbolRegistry) MinQty(symbol string) (minQty float64, ok bool) {
+	filters, ok := r.Filters(symbol)
+	return filters.MinQty, ok
+}
+
+// MaxQty returns the maximum quantity of symbol
+func (r *SymbolRegistry) MaxQty(symbol string) (maxQty float64, ok bool) {
+	filters, ok := r.Filters(symbol)
+	return filters.MaxQty, ok
+}
+
+// MinNotional returns the minimum notional of symbol
+func (r *SymbolRegistry) MinNotional(symbol string) (minNotional float64, ok bool) {
+	filters, ok := r.Filters(symbol)
+	return filters.MinNotional, ok
+}
+
+// TradingSchedule returns the parsed trading hours of symbol
+func (r *SymbolRegistry) TradingSchedule(symbol string) (schedule *TradingSchedule, ok bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	schedule, ok = r.schedules[symbol]
+	return schedule, ok
+}
+
+// MarketOpen reports whether the market of symbol is open at t, true if its
+// trading hours are unknown. It fits WsStaleMonitorConfig.MarketOpen.
+func (r *SymbolRegistry) MarketOpen(symbol string, t time.Time) bool {
+	schedule, ok := r.TradingSchedule(symbol)
+	return !ok || schedule.IsOpen(t)
+}
+
+// lookup returns a copy of the symbols of key in index, read under the read
+// lock as Set replaces the indexes
+func (r *SymbolRegistry) lookup(index *map[string][]*ExchangeSymbolInfo, key string) []*ExchangeSymbolInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]*ExchangeSymbolInfo{}, (*index)[key]...)
+}
+
+// Symbols returns every symbol, in the order of the exchange info
+func (r *SymbolRegistry) Symbols() []*ExchangeSymbolInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.info == nil {
+		return []*ExchangeSymbolInfo{}
+	}
+	res := make([]*ExchangeSymbolInfo, len(r.info.Symbols))
+	for i := range r.info.Symbols {
+		res[i] = &r.info.Symbols[i]
+	}
+	return res
+}
+
+// ByBaseAsset returns the symbols of a base asset
+func (r *SymbolRegistry) ByBaseAsset(asset string) []*ExchangeSymbolInfo {
+	return r.lookup(&r.byBaseAsset, asset)
+}
+
+// ByQuoteAsset returns the symbols of a quote asset
+func (r *SymbolRegistry) ByQuoteAsset(asset string) []*ExchangeSymbolInfo {
+	return r.lookup(&r.byQuoteAsset, asset)
+}
+
+// ByAssetType returns the symbols of an asset type
+func (r *SymbolRegistry) ByAssetType(assetType string) []*ExchangeSymbolInfo {
+	return r.lookup(&r.byAssetType, assetType)
+}
+
+// ByMarketType returns the symbols of a market type
+func (r *SymbolRegistry) ByMarketType(marketType string) []*ExchangeSymbolInfo {
+	return r.lookup(&r.byMarketType, marketType)
+}
+
+// ByMarketMode returns the symbols available in a market mode
+func (r *SymbolRegistry) ByMarketMode(mode string) []*ExchangeSymbolInfo {
+	return r.lookup(&r.byMarketMode, mode)
+}
+
+// BySector returns the symbols of a sector
+func (r *SymbolRegistry) BySector(sector string) []*ExchangeSymbolInfo {
+	return r.lookup(&r.bySector, sector)
+}
+
+// ByIndustry returns the symbols of an industry
+func (r *SymbolRegistry) ByIndustry(industry string) []*ExchangeSymbolInfo {
+	return r.lookup(&r.byIndustry, industry)
+}
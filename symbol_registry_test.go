@@ -0,0 +1,226 @@
+package go_currencycom
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type symbolRegistryTestSuite struct {
+	baseTestSuite
+}
+
+func TestSymbolRegistry(t *testing.T) {
+	suite.Run(t, new(symbolRegistryTestSuite))
+}
+
+var symbolRegistryData = []byte(`{
+	"timezone": "UTC",
+	"serverTime": 1577178958852,
+	"rateLimits": [],
+	"exchangeFilters": [],
+	"symbols": [
+		{
+			"symbol": "EVK",
+			"name": "Evonik",
+			"status": "BREAK",
+			"baseAsset": "EVK",
+			"quoteAsset": "EUR",
+			"filters": [
+				{"filterType": "LOT_SIZE", "minQty": "1", "maxQty": "27000", "stepSize": "1"},
+				{"filterType": "MIN_NOTIONAL", "minNotional": "29"}
+			],
+			"marketModes": ["REGULAR"],
+			"marketType": "SPOT",
+			"assetType": "EQUITY",
+			"sector": "Basic Materials",
+			"industry": "Diversified Chemicals",
+			"tickSize": 0.005
+		},
+		{
+			"symbol": "BTC/USD_LEVERAGE",
+			"name": "Bitcoin / USD",
+			"status": "TRADING",
+			"baseAsset": "BTC",
+			"quoteAsset": "USD",
+			"filters": [
+				{"filterType": "LOT_SIZE", "minQty": "0.0001", "maxQty": "100", "stepSize": "0.0001"},
+				{"filterType": "PRICE_FILTER", "tickSize": "0.01"}
+			],
+			"marketModes": ["REGULAR"],
+			"marketType": "LEVERAGE",
+			"assetType": "CRYPTOCURRENCY",
+			"tickSize": 0.1
+		},
+		{
+			"symbol": "BTC/EUR",
+			"name": "Bitcoin / Euro",
+			"status": "TRADING",
+			"baseAsset": "BTC",
+			"quoteAsset": "EUR",
+			"filters": [{"filterType": "LOT_SIZE", "minQty": "x"}],
+			"marketModes": ["REGULAR"],
+			"marketType": "SPOT",
+			"assetType": "CRYPTOCURRENCY"
+		}
+	]
+}`)
+
+func (s *symbolRegistryTestSuite) symbols(infos []*ExchangeSymbolInfo) []string {
+	res := make([]string, len(infos))
+	for i, info := range infos {
+		res[i] = info.Symbol
+	}
+	return res
+}
+
+func (s *symbolRegistryTestSuite) TestIndexes() {
+	s.mockDo(symbolRegistryData, nil)
+	defer s.assertDo()
+	registry, err := NewSymbolRegistry(s.client.Client, time.Hour, nil)
+	s.r().NoError(err)
+	r := s.r()
+	r.False(registry.Fresh())
+	info, err := registry.ExchangeInfo(newContext())
+	r.NoError(err)
+	r.Len(info.Symbols, 3)
+	r.True(registry.Fresh())
+
+	evk, ok := registry.Symbol("EVK")
+	r.True(ok)
+	r.Equal("Evonik", evk.Name)
+	_, ok = registry.Symbol("AAPL")
+	r.False(ok)
+
+	r.Equal([]string{"BTC/USD_LEVERAGE", "BTC/EUR"}, s.symbols(registry.ByBaseAsset("BTC")))
+	r.Equal([]string{"EVK", "BTC/EUR"}, s.symbols(registry.ByQuoteAsset("EUR")))
+	r.Equal([]string{"BTC/USD_LEVERAGE", "BTC/EUR"}, s.symbols(registry.ByAssetType("CRYPTOCURRENCY")))
+	r.Equal([]string{"BTC/USD_LEVERAGE"}, s.symbols(registry.ByMarketType("LEVERAGE")))
+	r.Len(registry.ByMarketMode("REGULAR"), 3)
+	r.Equal([]string{"EVK"}, s.symbols(registry.BySector("Basic Materials")))
+	r.Equal([]string{"EVK"}, s.symbols(registry.ByIndustry("Diversified Chemicals")))
+	r.Empty(registry.ByIndustry("Banks"))
+	r.Len(registry.Symbols(), 3)
+
+	// cached until the TTL
+	_, err = registry.ExchangeInfo(newContext())
+	r.NoError(err)
+	s.client.AssertNumberOfCalls(s.T(), "do", 1)
+}
+
+func (s *symbolRegistryTestSuite) TestFilters() {
+	var errs []error
+	registry, err := NewSymbolRegistry(s.client.Client, time.Hour, func(err error) {
+		errs = append(errs, err)
+	})
+	r := s.r()
+	r.NoError(err)
+	info := new(ExchangeInfo)
+	r.NoError(json.Unmarshal(symbolRegistryData, info))
+	registry.Set(info)
+
+	filters, ok := registry.Filters("EVK")
+	r.True(ok)
+	r.Equal(SymbolFilters{TickSize: 0.005, StepSize: 1, MinQty: 1, MaxQty: 27000, MinNotional: 29}, filters)
+
+	tickSize, ok := registry.TickSize("BTC/USD_LEVERAGE")
+	r.True(ok)
+	r.Equal(0.01, tickSize)
+	stepSize, _ := registry.StepSize("BTC/USD_LEVERAGE")
+	r.Equal(0.0001, stepSize)
+	minQty, _ := registry.MinQty("BTC/USD_LEVERAGE")
+	r.Equal(0.0001, minQty)
+	maxQty, _ := registry.MaxQty("BTC/USD_LEVERAGE")
+	r.Equal(100.0, maxQty)
+	minNotional, _ := registry.MinNotional("EVK")
+	r.Equal(29.0, minNotional)
+
+	// invalid filters are reported and not indexed
+	_, ok = registry.Filters("BTC/EUR")
+	r.False(ok)
+	r.Len(errs, 1)
+	r.EqualError(errs[0], `BTC/EUR: invalid LOT_SIZE filter value "x"`)
+}
+
+func (s *symbolRegistryTestSuite) TestBackgroundRefresh() {
+	var calls atomic.Int64
+	s.client.Client.do = func(req *http.Request) (*http.Response, error) {
+		if calls.Add(1) > 2 {
+			return newHTTPResponse([]byte(`{"code":-1000,"msg":"unavailable"}`), http.StatusServiceUnavailable), nil
+		}
+		return newHTTPResponse(symbolRegistryData, http.StatusOK), nil
+	}
+	var errs atomic.Int64
+	registry, err := NewSymbolRegistry(s.client.Client, 10*time.Millisecond, func(err error) {
+		if IsAPIError(err) {
+			errs.Add(1)
+		}
+	})
+	s.r().NoError(err)
+	registry.Start()
+	s.r().Eventually(func() bool {
+		return errs.Load() > 0
+	}, time.Second, 5*time.Millisecond)
+	registry.Stop()
+	registry.Stop()
+
+	_, ok := registry.Symbol("EVK")
+	s.r().True(ok)
+}
+
+func (s *symbolRegistryTestSuite) TestInvalidTTL() {
+	_, err := NewSymbolRegistry(s.client.Client, 0, nil)
+	s.r().Error(err)
+}
+
+func (s *symbolRegistryTestSuite) TestConcurrentRefresh() {
+	var calls atomic.Int64
+	releaseC := make(chan struct{})
+	s.client.Client.do = func(req *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		<-releaseC
+		return newHTTPResponse(symbolRegistryData, http.StatusOK), nil
+	}
+	registry, err := NewSymbolRegistry(s.client.Client, time.Hour, nil)
+	r := s.r()
+	r.NoError(err)
+
+	// stale callers share a single download
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			info, err := registry.ExchangeInfo(newContext())
+			r.NoError(err)
+			r.Len(info.Symbols, 3)
+		}()
+	}
+	r.Eventually(func() bool {
+		return calls.Load() == 1
+	}, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	close(releaseC)
+	wg.Wait()
+	r.Equal(int64(1), calls.Load())
+
+	// the indexes are read under the lock while refreshed, run with -race
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			r.NoError(registry.Refresh(newContext()))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			r.Len(registry.ByBaseAsset("BTC"), 2)
+		}
+	}()
+	wg.Wait()
+}
@@ -0,0 +1,312 @@
+package go_currencycom
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const aggTradesBackfillLimit = 1000
+
+// AggTradesBackfillInterval is the minimum time between two page requests
+// of AggTradesBackfill
+var AggTradesBackfillInterval = 100 * time.Millisecond
+
+// TradeBackfillFunc fetch the trades of symbol whose ID is strictly between
+// fromID and toID, executed between fromTime and toTime in milliseconds.
+type TradeBackfillFunc func(ctx context.Context, symbol string, fromID, toID, fromTime, toTime int64) ([]*WsTradesEvent, error)
+
+// TradeGapError is reported when missing trades could not be backfilled
+type TradeGapError struct {
+	Symbol  string
+	FromID  int64
+	ToID    int64
+	Missing int64
+	Err     error
+}
+
+// Error return the symbol and the range of missing trades
+func (e *TradeGapError) Error() string {
+	msg := fmt.Sprintf("%d trades of %s missing between %d and %d", e.Missing, e.Symbol, e.FromID, e.ToID)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *TradeGapError) Unwrap() error {
+	return e.Err
+}
+
+// AggTradesBackfill returns a TradeBackfillFunc reading the aggregate trades endpoint
+func AggTradesBackfill(c *Client) TradeBackfillFunc {
+	return func(ctx context.Context, symbol string, fromID, toID, fromTime, toTime int64) ([]*WsTradesEvent, error) {
+		res := make([]*WsTradesEvent, 0)
+		startTime := fromTime
+		pacer := &requestPacer{interval: AggTradesBackfillInterval}
+		for {
+			if err := pacer.wait(ctx); err != nil {
+				return res, err
+			}
+			trades, err := c.NewAggTradesService().Symbol(symbol).
+				StartTime(startTime).EndTime(toTime).
+				Limit(aggTradesBackfillLimit).Do(ctx)
+			if err != nil {
+				return res, err
+			}
+			next := startTime
+			for _, trade := range trades {
+				if trade.Timestamp > next {
+					next = trade.Timestamp
+				}
+				if trade.AggTradeID <= fromID || trade.AggTradeID >= toID {
+					continue
+				}
+				res = append(res, &WsTradesEvent{
+					Price:     trade.Price,
+					Size:      trade.Quantity,
+					ID:        trade.AggTradeID,
+					Timestamp: trade.Timestamp,
+					Symbol:    symbol,
+					Buyer:     !trade.IsBuyerMaker,
+				})
+			}
+			// stop on the last page or if the page did not move the window
+			if len(trades) < aggTradesBackfillLimit || next == startTime {
+				return res, nil
+			}
+			startTime = next
+		}
+	}
+}
+
+// TradeSequencerStats count the irregularities met by a TradeSequencer
+type TradeSequencerStats struct {
+	Gaps       uint64
+	Duplicates uint64
+	Backfilled uint64
+	Missing    uint64
+}
+
+// TradeSequencer delivers a strictly ordered and de-duplicated trade stream.
+// It tracks the last trade ID of each symbol, drops duplicate and late trades,
+// and fills gaps with trades fetched by the backfill function before passing
+// the trade that revealed the gap to the handler. Backfills run on their own
+// goroutine: the live trades of the symbol are buffered meanwhile and
+// delivered after the backfilled ones, so the handler may be called from
+// that goroutine too, but never concurrently.
+//
+// The handler and errHandler are called without the lock of the sequencer
+// held, so they may call its methods but Wait. Trades handled while they
+// run are delivered once they return.
+type TradeSequencer struct {
+	handler    WsTradesHandler
+	backfill   TradeBackfillFunc
+	errHandler ErrHandler
+	// Timeout bounds each backfill, 10 seconds by default
+	Timeout time.Duration
+
+	mu      sync.Mutex
+	last    map[string]*WsTradesEvent
+	filling map[string]*tradeFill
+	stats   TradeSequencerStats
+	wg      sync.WaitGroup
+	// queue holds the trades and errors to pass to the handlers, in order
+	queue    []tradeDelivery
+	draining bool
+	idle     *sync.Cond
+}
+
+// tradeDelivery is a trade or an error queued for the handlers
+type tradeDelivery struct {
+	event *WsTradesEvent
+	err   error
+}
+
+// tradeFill is a running backfill of the gap between last and event, and
+// the live trades received meanwhile
+type tradeFill struct {
+	last   *WsTradesEvent
+	event  *WsTradesEvent
+	buffer []*WsTradesEvent
+}
+
+// NewTradeSequencer init a sequencer. If backfill is nil, gaps are only
+// reported to errHandler.
+func NewTradeSequencer(handler WsTradesHandler, backfill TradeBackfillFunc, errHandler ErrHandler) *TradeSequencer {
+	s := &TradeSequencer{
+		handler:    handler,
+		backfill:   backfill,
+		errHandler: errHandler,
+		Timeout:    10 * time.Second,
+		last:       make(map[string]*WsTradesEvent),
+		filling:    make(map[string]*tradeFill),
+	}
+	s.idle = sync.NewCond(&s.mu)
+	return s
+}
+
+// Handler returns Handle as a WsTradesHandler
+func (s *TradeSequencer) Handler() WsTradesHandler {
+	return s.Handle
+}
+
+// Handle sequence a trade
+func (s *TradeSequencer) Handle(event *WsTradesEvent) {
+	s.mu.Lock()
+	s.handleLocked(event)
+	s.drain()
+}
+
+func (s *TradeSequencer) handleLocked(event *WsTradesEvent) {
+	if f, ok := s.filling[event.Symbol]; ok {
+		f.buffer = append(f.buffer, event)
+		return
+	}
+	last, ok := s.last[event.Symbol]
+	if ok && event.ID <= last.ID {
+		s.stats.Duplicates++
+		return
+	}
+	if ok && event.ID > last.ID+1 {
+		s.stats.Gaps++
+		if s.backfill == nil {
+			s.reportGap(last, event, event.ID-last.ID-1, nil)
+			s.deliver(event)
+			return
+		}
+		f := &tradeFill{last: last, event: event}
+		s.filling[event.Symbol] = f
+		s.wg.Add(1)
+		go s.fill(f)
+		return
+	}
+	s.deliver(event)
+}
+
+// fill backfill the trades between f.last and f.event, then deliver them
+// followed by the live trades buffered meanwhile
+func (s *TradeSequencer) fill(f *tradeFill) {
+	defer s.wg.Done()
+	symbol := f.event.Symbol
+	ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
+	trades, err := s.backfill(ctx, symbol, f.last.ID, f.event.ID, f.last.Timestamp, f.event.Timestamp)
+	cancel()
+	sort.Slice(trades, func(i, j int) bool {
+		return trades[i].ID < trades[j].ID
+	})
+
+	s.mu.Lock()
+	defer s.drain()
+	if s.filling[symbol] != f {
+		// the symbol was reset, sequence the live trades from scratch
+		s.handleLocked(f.event)
+		for _, event := range f.buffer {
+			s.handleLocked(event)
+		}
+		return
+	}
+	delete(s.filling, symbol)
+	missing := f.event.ID - f.last.ID - 1
+	for _, trade := range trades {
+		if trade.ID <= s.last[symbol].ID || trade.ID >= f.event.ID {
+			continue
+		}
+		s.deliver(trade)
+		s.stats.Backfilled++
+		missing--
+	}
+	if missing > 0 {
+		s.reportGap(f.last, f.event, missing, err)
+	}
+	s.deliver(f.event)
+	// buffered trades may reveal another gap, and start another backfill
+	for _, event := range f.buffer {
+		s.handleLocked(event)
+	}
+}
+
+func (s *TradeSequencer) reportGap(last, event *WsTradesEvent, missing int64, err error) {
+	s.stats.Missing += uint64(missing)
+	if s.errHandler != nil {
+		s.queue = append(s.queue, tradeDelivery{err: &TradeGapError{
+			Symbol:  event.Symbol,
+			FromID:  last.ID,
+			ToID:    event.ID,
+			Missing: missing,
+			Err:     err,
+		}})
+	}
+}
+
+// Wait for the running backfills to complete and their trades to be delivered
+func (s *TradeSequencer) Wait() {
+	s.wg.Wait()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.draining {
+		s.idle.Wait()
+	}
+}
+
+func (s *TradeSequencer) deliver(event *WsTradesEvent) {
+	s.last[event.Symbol] = event
+	s.queue = append(s.queue, tradeDelivery{event: event})
+}
+
+// drain pass the queued trades and errors to the handlers without holding
+// the lock. It is called with s.mu held and releases it. If another
+// goroutine is draining, it delivers the queued items after its own.
+func (s *TradeSequencer) drain() {
+	if s.draining {
+		s.mu.Unlock()
+		return
+	}
+	s.draining = true
+	for len(s.queue) > 0 {
+		d := s.queue[0]
+		s.queue[0] = tradeDelivery{}
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+		if d.err != nil {
+			s.errHandler(d.err)
+		} else {
+			s.handler(d.event)
+		}
+		s.mu.Lock()
+	}
+	s.draining = false
+	s.idle.Broadcast()
+	s.mu.Unlock()
+}
+
+// LastID returns the ID of the last trade sequenced for symbol, which may
+// still be queued for the handler
+func (s *TradeSequencer) LastID(symbol string) (id int64, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	last, ok := s.last[symbol]
+	if !ok {
+		return 0, false
+	}
+	return last.ID, true
+}
+
+// Reset forget the last trade of symbol, e.g. after resubscribing. The
+// trades buffered by a running backfill are delivered as if they had been
+// received after the reset.
+func (s *TradeSequencer) Reset(symbol string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.last, symbol)
+	delete(s.filling, symbol)
+}
+
+// Stats returns the counters of the sequencer
+func (s *TradeSequencer) Stats() TradeSequencerStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
@@ -0,0 +1,198 @@
+package go_currencycom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type tradeSequencerTestSuite struct {
+	baseTestSuite
+	delivered []int64
+	errs      []error
+}
+
+func TestTradeSequencer(t *testing.T) {
+	suite.Run(t, new(tradeSequencerTestSuite))
+}
+
+func (s *tradeSequencerTestSuite) SetupTest() {
+	s.baseTestSuite.SetupTest()
+	s.delivered = nil
+	s.errs = nil
+}
+
+func (s *tradeSequencerTestSuite) newSequencer(backfill TradeBackfillFunc) *TradeSequencer {
+	return NewTradeSequencer(func(event *WsTradesEvent) {
+		s.delivered = append(s.delivered, event.ID)
+	}, backfill, func(err error) {
+		s.errs = append(s.errs, err)
+	})
+}
+
+func trade(id int64) *WsTradesEvent {
+	return &WsTradesEvent{Symbol: "BTC/USD", ID: id, Timestamp: 1000 + id}
+}
+
+func (s *tradeSequencerTestSuite) TestDuplicatesAndGaps() {
+	var calls [][]int64
+	seq := s.newSequencer(func(ctx context.Context, symbol string, fromID, toID, fromTime, toTime int64) ([]*WsTradesEvent, error) {
+		calls = append(calls, []int64{fromID, toID, fromTime, toTime})
+		// unordered and overlapping on purpose
+		return []*WsTradesEvent{trade(4), trade(3), trade(2), trade(5)}, nil
+	})
+	handler := seq.Handler()
+	for _, id := range []int64{1, 2, 2, 5, 4, 6} {
+		handler(trade(id))
+	}
+	seq.Wait()
+	r := s.r()
+	r.Equal([]int64{1, 2, 3, 4, 5, 6}, s.delivered)
+	r.Equal([][]int64{{2, 5, 1002, 1005}}, calls)
+	r.Equal(TradeSequencerStats{Gaps: 1, Duplicates: 2, Backfilled: 2}, seq.Stats())
+	r.Empty(s.errs)
+	id, ok := seq.LastID("BTC/USD")
+	r.True(ok)
+	r.Equal(int64(6), id)
+}
+
+func (s *tradeSequencerTestSuite) TestBackfillFailure() {
+	fakeErr := errors.New("fake error")
+	seq := s.newSequencer(func(ctx context.Context, symbol string, fromID, toID, fromTime, toTime int64) ([]*WsTradesEvent, error) {
+		return []*WsTradesEvent{trade(2)}, fakeErr
+	})
+	seq.Handle(trade(1))
+	seq.Handle(trade(5))
+	seq.Wait()
+	r := s.r()
+	r.Equal([]int64{1, 2, 5}, s.delivered)
+	r.Equal(TradeSequencerStats{Gaps: 1, Backfilled: 1, Missing: 2}, seq.Stats())
+	r.Len(s.errs, 1)
+	r.ErrorIs(s.errs[0], fakeErr)
+	gapErr := new(TradeGapError)
+	r.ErrorAs(s.errs[0], &gapErr)
+	r.Equal(int64(2), gapErr.Missing)
+
+	seq.Reset("BTC/USD")
+	seq.Handle(trade(3))
+	r.Equal([]int64{1, 2, 5, 3}, s.delivered)
+}
+
+func (s *tradeSequencerTestSuite) TestBackfillBuffersLiveTrades() {
+	releaseC := make(chan struct{})
+	seq := s.newSequencer(func(ctx context.Context, symbol string, fromID, toID, fromTime, toTime int64) ([]*WsTradesEvent, error) {
+		<-releaseC
+		return []*WsTradesEvent{trade(2), trade(3)}, nil
+	})
+	seq.Handle(trade(1))
+	// the gap is filled in the background, Handle does not block
+	seq.Handle(trade(4))
+	seq.Handle(trade(3))
+	seq.Handle(trade(5))
+	seq.Handle(trade(8))
+	seq.Handle(trade(6))
+	r := s.r()
+	seq.mu.Lock()
+	r.Equal([]int64{1}, s.delivered)
+	seq.mu.Unlock()
+	close(releaseC)
+	seq.Wait()
+	// 3 is a duplicate of the backfill, 8 revealed a second gap and 6 is late
+	r.Equal([]int64{1, 2, 3, 4, 5, 8}, s.delivered)
+	r.Equal(TradeSequencerStats{Gaps: 2, Duplicates: 2, Backfilled: 2, Missing: 2}, seq.Stats())
+	r.Len(s.errs, 1)
+}
+
+func (s *tradeSequencerTestSuite) TestHandlersCallSequencer() {
+	var seq *TradeSequencer
+	var lastIDs []int64
+	var stats []TradeSequencerStats
+	seq = NewTradeSequencer(func(event *WsTradesEvent) {
+		id, _ := seq.LastID(event.Symbol)
+		lastIDs = append(lastIDs, id)
+		// trades handled by the handler are delivered once it returns
+		if event.ID == 1 {
+			seq.Handle(trade(2))
+		}
+	}, func(ctx context.Context, symbol string, fromID, toID, fromTime, toTime int64) ([]*WsTradesEvent, error) {
+		return nil, errors.New("fake error")
+	}, func(err error) {
+		stats = append(stats, seq.Stats())
+	})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		seq.Handle(trade(1))
+		seq.Handle(trade(4))
+		seq.Wait()
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		s.FailNow("handlers deadlocked the sequencer")
+	}
+	r := s.r()
+	r.Equal([]int64{1, 2, 4}, lastIDs)
+	r.Equal([]TradeSequencerStats{{Gaps: 1, Missing: 1}}, stats)
+}
+
+func (s *tradeSequencerTestSuite) TestAggTradesBackfill() {
+	data := []byte(`[
+		{"a": 10, "p": 1.5, "q": 2, "T": 1010, "m": true},
+		{"a": 11, "p": 1.6, "q": 3, "T": 1011, "m": false},
+		{"a": 12, "p": 1.7, "q": 4, "T": 1012, "m": false}
+	]`)
+	s.mockDo(data, nil)
+	defer s.assertDo()
+	s.assertReq(func(r *request) {
+		e := newRequest().setParams(params{
+			"symbol":    "BTC/USD",
+			"startTime": 1010,
+			"endTime":   1012,
+			"limit":     aggTradesBackfillLimit,
+		})
+		s.assertRequestEqual(e, r)
+	})
+	trades, err := AggTradesBackfill(s.client.Client)(newContext(), "BTC/USD", 10, 12, 1010, 1012)
+	r := s.r()
+	r.NoError(err)
+	r.Equal([]*WsTradesEvent{{
+		Price:     1.6,
+		Size:      3,
+		ID:        11,
+		Timestamp: 1011,
+		Symbol:    "BTC/USD",
+		Buyer:     true,
+	}}, trades)
+}
+
+func (s *tradeSequencerTestSuite) TestAggTradesBackfillPacing() {
+	defer func(d time.Duration) {
+		AggTradesBackfillInterval = d
+	}(AggTradesBackfillInterval)
+	AggTradesBackfillInterval = 50 * time.Millisecond
+	var times []time.Time
+	s.client.Client.do = func(req *http.Request) (*http.Response, error) {
+		times = append(times, time.Now())
+		n := 1
+		if len(times) == 1 {
+			n = aggTradesBackfillLimit
+		}
+		trades := make([]string, 0, n)
+		for i := 0; i < n; i++ {
+			trades = append(trades, fmt.Sprintf(`{"a":%d,"T":%d}`, 10+len(times)*n+i, 1000+len(times)*n+i))
+		}
+		return newHTTPResponse([]byte("["+strings.Join(trades, ",")+"]"), http.StatusOK), nil
+	}
+	_, err := AggTradesBackfill(s.client.Client)(newContext(), "BTC/USD", 0, 10000, 1000, 10000)
+	r := s.r()
+	r.NoError(err)
+	r.Len(times, 2)
+	r.GreaterOrEqual(times[1].Sub(times[0]), AggTradesBackfillInterval)
+}
@@ -0,0 +1,219 @@
+package go_currencycom
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tradingHoursWindow is the number of days around a time in which the
+// sessions of a weekly schedule are laid out; more than a week, so that a
+// period reaching the edge of the window never closes
+const tradingHoursWindow = 8
+
+var weekdays = map[string]time.Weekday{
+	"Sun": time.Sunday,
+	"Mon": time.Monday,
+	"Tue": time.Tuesday,
+	"Wed": time.Wednesday,
+	"Thu": time.Thursday,
+	"Fri": time.Friday,
+	"Sat": time.Saturday,
+}
+
+// TradingSession is a daily session of a weekly schedule. Open and Close are
+// offsets from midnight in the location of the schedule, Close is at most 24h.
+type TradingSession struct {
+	Day   time.Weekday
+	Open  time.Duration
+	Close time.Duration
+}
+
+// TradingSchedule is the weekly schedule of ExchangeSymbolInfo.TradingHours.
+// Sessions ending at midnight and starting at midnight the next day form a
+// single period, so NextClose skips the midnight between them.
+type TradingSchedule struct {
+	Location *time.Location
+	Sessions []TradingSession
+}
+
+// MarketClosedError is returned by CreateOrderService when the market of the
+// symbol is closed
+type MarketClosedError struct {
+	Symbol   string
+	NextOpen time.Time
+}
+
+// Error return the symbol and the time it opens
+func (e *MarketClosedError) Error() string {
+	return fmt.Sprintf("market of %s is closed until %s", e.Symbol, e.NextOpen.Format(time.RFC3339))
+}
+
+// IsMarketClosedError check if e is a market closed error
+func IsMarketClosedError(e error) bool {
+	var err *MarketClosedError
+	return errors.As(e, &err)
+}
+
+// ParseTradingHours parse trading hours such as
+// "UTC; Mon 07:02 - 15:30; Tue 07:02 - 15:30" or "UTC; Mon - 21:00, 21:05 -".
+// The first field is the time zone, then each day lists its sessions
+// separated by commas; a session without open time opens at midnight and
+// one without close time closes at midnight.
+func ParseTradingHours(hours string) (*TradingSchedule, error) {
+	fields := strings.Split(hours, ";")
+	loc, err := parseLocation(strings.TrimSpace(fields[0]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid trading hours %q: %w", hours, err)
+	}
+	res := &TradingSchedule{Location: loc}
+	for _, field := range fields[1:] {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		name, sessions, _ := strings.Cut(field, " ")
+		day, ok := weekdays[name]
+		if !ok {
+			return nil, fmt.Errorf("invalid trading hours %q: unknown day %q", hours, name)
+		}
+		for _, session := range strings.Split(sessions, ",") {
+			s, err := parseTradingSession(day, session)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trading hours %q: %w", hours, err)
+			}
+			res.Sessions = append(res.Sessions, s)
+		}
+	}
+	if len(res.Sessions) == 0 {
+		return nil, fmt.Errorf("invalid trading hours %q: no session", hours)
+	}
+	return res, nil
+}
+
+func parseTradingSession(day time.Weekday, session string) (TradingSession, error) {
+	open, close, ok := strings.Cut(session, "-")
+	if !ok {
+		return TradingSession{}, fmt.Errorf("invalid session %q", strings.TrimSpace(session))
+	}
+	res := TradingSession{Day: day, Close: oneDay}
+	var err error
+	if open = strings.TrimSpace(open); open != "" {
+		if res.Open, err = parseTimeOfDay(open); err != nil {
+			return TradingSession{}, err
+		}
+	}
+	if close = strings.TrimSpace(close); close != "" {
+		if res.Close, err = parseTimeOfDay(close); err != nil {
+			return TradingSession{}, err
+		}
+	}
+	if res.Open >= res.Close {
+		return TradingSession{}, fmt.Errorf("invalid session %q", strings.TrimSpace(session))
+	}
+	return res, nil
+}
+
+// parseTimeOfDay parse HH:MM, up to 24:00
+func parseTimeOfDay(s string) (time.Duration, error) {
+	hours, minutes, ok := strings.Cut(s, ":")
+	h, err := strconv.Atoi(hours)
+	if !ok || err != nil || h < 0 || h > 24 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	m, err := strconv.Atoi(minutes)
+	if err != nil || m < 0 || m > 59 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
+
+// TradingSchedule parse the trading hours of the symbol
+func (s *ExchangeSymbolInfo) TradingSchedule() (*TradingSchedule, error) {
+	return ParseTradingHours(s.TradingHours)
+}
+
+type tradingPeriod struct {
+	open, close time.Time
+}
+
+// periods lay out the sessions of the days around t, merging adjacent ones.
+// The last period ends at the end of the window if the market is always open.
+func (s *TradingSchedule) periods(t time.Time) (periods []tradingPeriod, end time.Time) {
+	y, m, d := t.In(s.Location).Date()
+	at := func(day int, offset time.Duration) time.Time {
+		return time.Date(y, m, day, int(offset/time.Hour), int(offset%time.Hour/time.Minute), 0, 0, s.Location)
+	}
+	for day := d - tradingHoursWindow; day <= d+tradingHoursWindow; day++ {
+		weekday := at(day, 0).Weekday()
+		for _, session := range s.Sessions {
+			if session.Day == weekday {
+				periods = append(periods, tradingPeriod{at(day, session.Open), at(day, session.Close)})
+			}
+		}
+	}
+	sort.Slice(periods, func(i, j int) bool {
+		return periods[i].open.Before(periods[j].open)
+	})
+	merged := periods[:0]
+	for _, p := range periods {
+		if n := len(merged); n > 0 && !p.open.After(merged[n-1].close) {
+			if p.close.After(merged[n-1].close) {
+				merged[n-1].close = p.close
+			}
+			continue
+		}
+		merged = append(merged, p)
+	}
+	return merged, at(d+tradingHoursWindow+1, 0)
+}
+
+// IsOpen reports whether the market is open at t
+func (s *TradingSchedule) IsOpen(t time.Time) bool {
+	periods, _ := s.periods(t)
+	for _, p := range periods {
+		if !t.Before(p.open) && t.Before(p.close) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextOpen returns t if the market is open at t, the next time it opens otherwise
+func (s *TradingSchedule) NextOpen(t time.Time) time.Time {
+	periods, _ := s.periods(t)
+	for _, p := range periods {
+		if t.Before(p.close) {
+			if t.Before(p.open) {
+				return p.open
+			}
+			return t
+		}
+	}
+	// unreachable with at least a session a week
+	return time.Time{}
+}
+
+// NextClose returns the end of the period the market is open at t, or of the
+// next one if it is closed. ok is false if the market never closes.
+func (s *TradingSchedule) NextClose(t time.Time) (close time.Time, ok bool) {
+	periods, end := s.periods(t)
+	for _, p := range periods {
+		if t.Before(p.close) {
+			if p.close.Equal(end) {
+				return time.Time{}, false
+			}
+			return p.close, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Closed reports whether the market is closed from from until to, e.g. to
+// explain the gaps of KlineDownloader.MarketClosed
+func (s *TradingSchedule) Closed(from, to time.Time) bool {
+	return !s.NextOpen(from).Before(to)
+}
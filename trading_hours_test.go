@@ -0,0 +1,153 @@
+package go_currencycom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type tradingHoursTestSuite struct {
+	baseTestSuite
+}
+
+func TestTradingHours(t *testing.T) {
+	suite.Run(t, new(tradingHoursTestSuite))
+}
+
+func utcTime(day, hour, minute int) time.Time {
+	// 2024-01-01 is a Monday
+	return time.Date(2024, time.January, day, hour, minute, 0, 0, time.UTC)
+}
+
+func (s *tradingHoursTestSuite) TestWeekdays() {
+	schedule, err := ParseTradingHours("UTC; Mon 07:02 - 15:30; Tue 07:02 - 15:30; Wed 07:02 - 15:30; Thu 07:02 - 15:30; Fri 07:02 - 15:30")
+	r := s.r()
+	r.NoError(err)
+	r.Equal(time.UTC, schedule.Location)
+	r.Len(schedule.Sessions, 5)
+	r.Equal(TradingSession{Day: time.Monday, Open: 7*time.Hour + 2*time.Minute, Close: 15*time.Hour + 30*time.Minute}, schedule.Sessions[0])
+
+	r.True(schedule.IsOpen(utcTime(1, 7, 2)))
+	r.True(schedule.IsOpen(utcTime(1, 15, 29)))
+	r.False(schedule.IsOpen(utcTime(1, 15, 30)))
+	r.False(schedule.IsOpen(utcTime(6, 12, 0)))
+
+	r.Equal(utcTime(1, 8, 0), schedule.NextOpen(utcTime(1, 8, 0)))
+	r.Equal(utcTime(2, 7, 2), schedule.NextOpen(utcTime(1, 16, 0)))
+	r.Equal(utcTime(8, 7, 2), schedule.NextOpen(utcTime(5, 16, 0)))
+
+	close, ok := schedule.NextClose(utcTime(1, 8, 0))
+	r.True(ok)
+	r.Equal(utcTime(1, 15, 30), close)
+	close, ok = schedule.NextClose(utcTime(6, 8, 0))
+	r.True(ok)
+	r.Equal(utcTime(8, 15, 30), close)
+
+	r.True(schedule.Closed(utcTime(5, 15, 30), utcTime(8, 7, 2)))
+	r.False(schedule.Closed(utcTime(5, 15, 30), utcTime(8, 7, 3)))
+	r.False(schedule.Closed(utcTime(1, 8, 0), utcTime(1, 8, 1)))
+}
+
+func (s *tradingHoursTestSuite) TestOvernight() {
+	schedule, err := ParseTradingHours("UTC; Mon - 21:00, 21:05 -; Tue - 21:00, 21:05 -; Wed - 21:00, 21:05 -; Thu - 21:00, 21:05 -; Fri - 21:00; Sun 21:05 -")
+	r := s.r()
+	r.NoError(err)
+	r.Len(schedule.Sessions, 10)
+
+	r.True(schedule.IsOpen(utcTime(1, 0, 0)))
+	r.False(schedule.IsOpen(utcTime(1, 21, 2)))
+	r.True(schedule.IsOpen(utcTime(1, 23, 59)))
+	r.Equal(utcTime(1, 21, 5), schedule.NextOpen(utcTime(1, 21, 0)))
+	r.Equal(utcTime(7, 21, 5), schedule.NextOpen(utcTime(5, 22, 0)))
+
+	// the sessions either side of midnight are a single period
+	close, ok := schedule.NextClose(utcTime(1, 22, 0))
+	r.True(ok)
+	r.Equal(utcTime(2, 21, 0), close)
+	close, ok = schedule.NextClose(utcTime(6, 12, 0))
+	r.True(ok)
+	r.Equal(utcTime(8, 21, 0), close)
+}
+
+func (s *tradingHoursTestSuite) TestAlwaysOpen() {
+	schedule, err := ParseTradingHours("UTC; Mon 00:00 - 24:00; Tue -; Wed -; Thu -; Fri -; Sat -; Sun -")
+	r := s.r()
+	r.NoError(err)
+	r.True(schedule.IsOpen(utcTime(6, 12, 0)))
+	r.Equal(utcTime(6, 12, 0), schedule.NextOpen(utcTime(6, 12, 0)))
+	_, ok := schedule.NextClose(utcTime(6, 12, 0))
+	r.False(ok)
+}
+
+func (s *tradingHoursTestSuite) TestTimezone() {
+	schedule, err := ParseTradingHours("UTC+2; Mon 09:00 - 17:30")
+	r := s.r()
+	r.NoError(err)
+	r.False(schedule.IsOpen(utcTime(1, 6, 59)))
+	r.True(schedule.IsOpen(utcTime(1, 7, 0)))
+	r.Equal(utcTime(8, 7, 0), schedule.NextOpen(utcTime(1, 15, 30)).UTC())
+	close, _ := schedule.NextClose(utcTime(1, 7, 0))
+	r.Equal(utcTime(1, 15, 30), close.UTC())
+
+	// Sunday 23:00 UTC is Monday 01:00 in UTC+2
+	r.Equal(utcTime(8, 7, 0), schedule.NextOpen(utcTime(7, 23, 0)).UTC())
+}
+
+func (s *tradingHoursTestSuite) TestInvalid() {
+	r := s.r()
+	for hours, msg := range map[string]string{
+		"":                        `invalid trading hours "": no session`,
+		"Mars; Mon 07:00 - 08:00": `invalid trading hours "Mars; Mon 07:00 - 08:00": unknown time zone: "Mars"`,
+		"UTC; Foo 07:00 - 08:00":  `invalid trading hours "UTC; Foo 07:00 - 08:00": unknown day "Foo"`,
+		"UTC; Mon 08:00 - 07:00":  `invalid trading hours "UTC; Mon 08:00 - 07:00": invalid session "08:00 - 07:00"`,
+		"UTC; Mon 07:00":          `invalid trading hours "UTC; Mon 07:00": invalid session "07:00"`,
+		"UTC; Mon 07:00 - 24:30":  `invalid trading hours "UTC; Mon 07:00 - 24:30": invalid time "24:30"`,
+		"UTC; Mon 7h - 8h":        `invalid trading hours "UTC; Mon 7h - 8h": invalid time "7h"`,
+	} {
+		_, err := ParseTradingHours(hours)
+		r.EqualError(err, msg, hours)
+	}
+}
+
+func (s *tradingHoursTestSuite) TestCreateOrderRequireMarketOpen() {
+	now := time.Now()
+	closed := &TradingSchedule{Location: time.UTC, Sessions: []TradingSession{
+		{Day: (now.UTC().Weekday() + 3) % 7, Open: 0, Close: time.Hour},
+	}}
+	_, err := s.client.NewCreateOrderService().Symbol("EVK").
+		Side(SideTypeBuy).Type(OrderTypeMarket).Quantity(1).
+		RequireMarketOpen(closed).Do(newContext())
+	r := s.r()
+	r.True(IsMarketClosedError(err))
+	r.True(err.(*MarketClosedError).NextOpen.After(now))
+	s.client.AssertNotCalled(s.T(), "do")
+
+	open, err := ParseTradingHours("UTC; Mon -; Tue -; Wed -; Thu -; Fri -; Sat -; Sun -")
+	r.NoError(err)
+	s.mockDo([]byte(`{"symbol": "EVK", "orderId": "1", "status": "FILLED"}`), nil)
+	defer s.assertDo()
+	res, err := s.client.NewCreateOrderService().Symbol("EVK").
+		Side(SideTypeBuy).Type(OrderTypeMarket).Quantity(1).
+		RequireMarketOpen(open).Do(newContext())
+	r.NoError(err)
+	r.Equal("1", res.OrderID)
+}
+
+func (s *tradingHoursTestSuite) TestSymbolRegistry() {
+	registry, err := NewSymbolRegistry(s.client.Client, time.Hour, nil)
+	s.r().NoError(err)
+	registry.Set(&ExchangeInfo{Symbols: []ExchangeSymbolInfo{
+		{Symbol: "EVK", TradingHours: "UTC; Mon 07:02 - 15:30"},
+		{Symbol: "BTC/USD"},
+	}})
+	r := s.r()
+	_, ok := registry.TradingSchedule("EVK")
+	r.True(ok)
+	_, ok = registry.TradingSchedule("BTC/USD")
+	r.False(ok)
+	r.True(registry.MarketOpen("EVK", utcTime(1, 8, 0)))
+	r.False(registry.MarketOpen("EVK", utcTime(2, 8, 0)))
+	r.True(registry.MarketOpen("BTC/USD", utcTime(2, 8, 0)))
+	r.True(registry.MarketOpen("ETH/USD", utcTime(2, 8, 0)))
+}
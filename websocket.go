@@ -1,9 +1,18 @@
 package go_currencycom
 
 import (
+	"context"
+	"crypto/tls"
 	stdjson "encoding/json"
+	"errors"
+	"fmt"
 	"github.com/gorilla/websocket"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,22 +22,270 @@ type ErrHandler func(err error)
 
 type payload map[string]interface{}
 
+const (
+	defaultWsHandshakeTimeout = 45 * time.Second
+	defaultWsReadLimit        = 655350
+)
+
 type WsConfig struct {
-	Endpoint string
+	Endpoint         string
+	Stream           string
+	Dispatch         *WsDispatchConfig
+	Heartbeat        time.Duration
+	HeartbeatTimeout time.Duration
+	Recorder         *WsRecorder
+
+	// Dialer replaces the dialer built from the transport settings below
+	Dialer *websocket.Dialer
+	// NetDial creates the underlying connection, net.Dialer is used if nil
+	NetDial           func(ctx context.Context, network, addr string) (net.Conn, error)
+	Header            http.Header
+	TLSConfig         *tls.Config
+	Proxy             func(*http.Request) (*url.URL, error)
+	HandshakeTimeout  time.Duration
+	EnableCompression bool
+	ReadLimit         int64
+	// WriteTimeout bounds the write of each request, no deadline is set if zero
+	WriteTimeout time.Duration
+}
+
+func (c *WsConfig) dialer() *websocket.Dialer {
+	if c.Dialer != nil {
+		return c.Dialer
+	}
+	return &websocket.Dialer{
+		NetDialContext:    c.NetDial,
+		Proxy:             c.Proxy,
+		TLSClientConfig:   c.TLSConfig,
+		HandshakeTimeout:  c.HandshakeTimeout,
+		EnableCompression: c.EnableCompression,
+	}
+}
+
+// WsOption define option type for websocket connections
+type WsOption func(*WsConfig)
+
+// WithWsEndpoint connect the stream to endpoint instead of the default one
+func WithWsEndpoint(endpoint string) WsOption {
+	return func(c *WsConfig) {
+		c.Endpoint = endpoint
+	}
+}
+
+// WithWsDialer dial the connection with dialer, ignoring the other transport options
+func WithWsDialer(dialer *websocket.Dialer) WsOption {
+	return func(c *WsConfig) {
+		c.Dialer = dialer
+	}
+}
+
+// WithWsNetDial create the underlying network connection with dial
+func WithWsNetDial(dial func(ctx context.Context, network, addr string) (net.Conn, error)) WsOption {
+	return func(c *WsConfig) {
+		c.NetDial = dial
+	}
+}
+
+// WithWsHeader add header to the handshake request
+func WithWsHeader(header http.Header) WsOption {
+	return func(c *WsConfig) {
+		if c.Header == nil {
+			c.Header = http.Header{}
+		}
+		for k, v := range header {
+			c.Header[k] = append(c.Header[k], v...)
+		}
+	}
 }
 
-func newWsConfig(endpoint string) *WsConfig {
-	return &WsConfig{
-		Endpoint: endpoint,
+// WithWsTLSConfig set the TLS configuration of wss endpoints
+func WithWsTLSConfig(config *tls.Config) WsOption {
+	return func(c *WsConfig) {
+		c.TLSConfig = config
 	}
 }
 
+// WithWsProxy set the proxy of the connection, nil disables the proxy
+// from the environment
+func WithWsProxy(proxy func(*http.Request) (*url.URL, error)) WsOption {
+	return func(c *WsConfig) {
+		c.Proxy = proxy
+	}
+}
+
+// WithWsHandshakeTimeout set the timeout of the opening handshake
+func WithWsHandshakeTimeout(timeout time.Duration) WsOption {
+	return func(c *WsConfig) {
+		c.HandshakeTimeout = timeout
+	}
+}
+
+// WithWsCompression negotiate per message compression with the server
+func WithWsCompression(enable bool) WsOption {
+	return func(c *WsConfig) {
+		c.EnableCompression = enable
+	}
+}
+
+// WithWsReadLimit set the maximum size of a received message
+func WithWsReadLimit(limit int64) WsOption {
+	return func(c *WsConfig) {
+		c.ReadLimit = limit
+	}
+}
+
+// WithWsWriteTimeout set the deadline of each request write
+func WithWsWriteTimeout(timeout time.Duration) WsOption {
+	return func(c *WsConfig) {
+		c.WriteTimeout = timeout
+	}
+}
+
+// WithWsHeartbeat send an application level ping every interval and close
+// the connection if a ping is not answered within timeout of being sent,
+// 2 intervals if timeout is 0. The timeout may be shorter than the interval.
+func WithWsHeartbeat(interval, timeout time.Duration) WsOption {
+	return func(c *WsConfig) {
+		c.Heartbeat = interval
+		c.HeartbeatTimeout = timeout
+	}
+}
+
+func newWsConfig(endpoint string, stream string, opts ...WsOption) *WsConfig {
+	config := &WsConfig{
+		Endpoint:         endpoint,
+		Stream:           stream,
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: defaultWsHandshakeTimeout,
+		ReadLimit:        defaultWsReadLimit,
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+	return config
+}
+
 type WsRequest struct {
 	Destination   string  `json:"destination"`
 	CorrelationID int     `json:"correlationId"`
 	Payload       payload `json:"payload"`
 }
 
+// Destinations of the messages pushed by the websocket api
+const (
+	WsDestinationQuote  = "internal.quote"
+	WsDestinationCandle = "internal.candle"
+	WsDestinationOHLC   = "ohlc.event"
+	WsDestinationTrade  = "internal.trade"
+	WsDestinationDepth  = "marketdepth.event"
+)
+
+const wsStatusOK = "OK"
+
+// WsCorrelationID accepts both numeric and string correlation ids
+type WsCorrelationID string
+
+func (id *WsCorrelationID) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		*id = ""
+		return nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		unquoted, err := strconv.Unquote(s)
+		if err != nil {
+			return err
+		}
+		s = unquoted
+	}
+	*id = WsCorrelationID(s)
+	return nil
+}
+
+// WsResponse define the envelope of a message received from the websocket api
+type WsResponse struct {
+	Status        string             `json:"status"`
+	Destination   string             `json:"destination"`
+	CorrelationID WsCorrelationID    `json:"correlationId"`
+	Payload       stdjson.RawMessage `json:"payload"`
+}
+
+// wsErrorPayload define the payload of a websocket error
+type wsErrorPayload struct {
+	ErrorCode    stdjson.RawMessage `json:"errorCode"`
+	ErrorMessage string             `json:"errorMessage"`
+	Code         stdjson.RawMessage `json:"code"`
+	Msg          string             `json:"msg"`
+}
+
+func newWsError(res *WsResponse) *WsError {
+	e := &WsError{
+		Status:        res.Status,
+		Destination:   res.Destination,
+		CorrelationID: string(res.CorrelationID),
+	}
+	p := new(wsErrorPayload)
+	if err := json.Unmarshal(res.Payload, p); err != nil {
+		e.Message = string(res.Payload)
+		return e
+	}
+	code := p.ErrorCode
+	if len(code) == 0 {
+		code = p.Code
+	}
+	e.Code, _ = strconv.ParseInt(strings.Trim(string(code), `"`), 10, 64)
+	e.Message = p.ErrorMessage
+	if e.Message == "" {
+		e.Message = p.Msg
+	}
+	return e
+}
+
+// wsRouter decode messages and dispatch their payload by destination
+type wsRouter struct {
+	routes     map[string]func(payload stdjson.RawMessage) error
+	errHandler ErrHandler
+	// replies, if set, consumes the replies to pending requests
+	replies func(res *WsResponse) bool
+}
+
+func newWsRouter(errHandler ErrHandler) *wsRouter {
+	return &wsRouter{
+		routes:     make(map[string]func(payload stdjson.RawMessage) error),
+		errHandler: errHandler,
+	}
+}
+
+// on register the payload handler of a destination
+func (r *wsRouter) on(destination string, f func(payload stdjson.RawMessage) error) *wsRouter {
+	r.routes[destination] = f
+	return r
+}
+
+// handle is a WsHandler. Messages with unknown destinations, such as
+// subscription acknowledgements, are ignored.
+func (r *wsRouter) handle(message []byte) {
+	res := new(WsResponse)
+	if err := json.Unmarshal(message, res); err != nil {
+		r.errHandler(fmt.Errorf("invalid websocket message: %w", err))
+		return
+	}
+	if r.replies != nil && r.replies(res) {
+		return
+	}
+	if res.Status != wsStatusOK {
+		r.errHandler(newWsError(res))
+		return
+	}
+	f, ok := r.routes[res.Destination]
+	if !ok {
+		return
+	}
+	if err := f(res.Payload); err != nil {
+		r.errHandler(fmt.Errorf("invalid %s payload: %w", res.Destination, err))
+	}
+}
+
 func newWsRequest(destination string, correlationID int, p payload) *WsRequest {
 	correlationID++
 	return &WsRequest{
@@ -39,18 +296,14 @@ func newWsRequest(destination string, correlationID int, p payload) *WsRequest {
 }
 
 var wsServe = func(config *WsConfig, requests chan WsRequest, handler WsHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
-	Dialer := websocket.Dialer{
-		Proxy:             http.ProxyFromEnvironment,
-		HandshakeTimeout:  45 * time.Second,
-		EnableCompression: false,
-	}
-
-	c, _, err := Dialer.Dial(config.Endpoint, nil)
+	c, _, err := config.dialer().Dial(config.Endpoint, config.Header)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	c.SetReadLimit(655350)
+	if config.ReadLimit > 0 {
+		c.SetReadLimit(config.ReadLimit)
+	}
 	doneC = make(chan struct{})
 	stopC = make(chan struct{})
 	go func() {
@@ -58,17 +311,28 @@ var wsServe = func(config *WsConfig, requests chan WsRequest, handler WsHandler,
 		// websocket.Conn.ReadMessage or when the stopC channel is
 		// closed by the client.
 		defer close(doneC)
+		if config.Dispatch != nil {
+			dispatcher := newWsDispatcher(config.Stream, *config.Dispatch, handler)
+			handler = dispatcher.push
+			defer dispatcher.close()
+		}
 		if WebsocketKeepAlive {
 			keepAlive(c, WebsocketTimeout)
 		}
+		if config.Heartbeat > 0 {
+			handler = heartbeat(c, config, requests, handler, errHandler, doneC)
+		}
+		if config.Recorder != nil {
+			handler = config.Recorder.Wrap(handler, errHandler)
+		}
 		// Wait for the stopC channel to be closed.  We do that in a
 		// separate goroutine because ReadMessage is a blocking
 		// operation.
-		silent := false
+		var silent atomic.Bool
 		go func() {
 			select {
 			case <-stopC:
-				silent = true
+				silent.Store(true)
 			case <-doneC:
 			}
 			err := c.Close()
@@ -87,9 +351,14 @@ var wsServe = func(config *WsConfig, requests chan WsRequest, handler WsHandler,
 						errHandler(err)
 						return
 					}
+					if config.WriteTimeout > 0 {
+						_ = c.SetWriteDeadline(time.Now().Add(config.WriteTimeout))
+					}
 					err = c.WriteMessage(websocket.TextMessage, msg)
 					if err != nil {
-						errHandler(err)
+						if !silent.Load() {
+							errHandler(err)
+						}
 						return
 					}
 				case <-doneC:
@@ -101,7 +370,7 @@ var wsServe = func(config *WsConfig, requests chan WsRequest, handler WsHandler,
 		for {
 			_, message, err := c.ReadMessage()
 			if err != nil {
-				if !silent {
+				if !silent.Load() {
 					errHandler(err)
 				}
 				return
@@ -140,3 +409,68 @@ func keepAlive(c *websocket.Conn, timeout time.Duration) {
 		}
 	}()
 }
+
+const wsPingDestination = "ping"
+
+// ErrWsHeartbeatTimeout is reported when the server stops replying to pings
+var ErrWsHeartbeatTimeout = errors.New("websocket heartbeat timeout")
+
+// heartbeat sends ping requests through the requests channel and filters
+// the replies out of the messages passed to handler. Each ping must be
+// answered within the timeout from the time it was sent; the next ping is
+// sent an interval after the previous one, once it was answered.
+func heartbeat(c *websocket.Conn, config *WsConfig, requests chan WsRequest, handler WsHandler, errHandler ErrHandler, doneC chan struct{}) WsHandler {
+	timeout := config.HeartbeatTimeout
+	if timeout <= 0 {
+		timeout = 2 * config.Heartbeat
+	}
+	replyC := make(chan struct{}, 1)
+
+	go func() {
+		ticker := time.NewTicker(config.Heartbeat)
+		defer ticker.Stop()
+		for {
+			// a late reply to a previous ping does not answer this one
+			select {
+			case <-replyC:
+			default:
+			}
+			select {
+			case requests <- *newWsRequest(wsPingDestination, CorrelationID, payload{}):
+			case <-doneC:
+				return
+			}
+			timer := time.NewTimer(timeout)
+			select {
+			case <-replyC:
+				timer.Stop()
+			case <-timer.C:
+				errHandler(ErrWsHeartbeatTimeout)
+				_ = c.Close()
+				return
+			case <-doneC:
+				timer.Stop()
+				return
+			}
+			select {
+			case <-ticker.C:
+			case <-doneC:
+				return
+			}
+		}
+	}()
+
+	return func(message []byte) {
+		var m struct {
+			Destination string `json:"destination"`
+		}
+		if err := json.Unmarshal(message, &m); err == nil && m.Destination == wsPingDestination {
+			select {
+			case replyC <- struct{}{}:
+			default:
+			}
+			return
+		}
+		handler(message)
+	}
+}
@@ -0,0 +1,109 @@
+package go_currencycom
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// WsConflatedMarketDataEvent is the latest quote of a symbol over a conflation interval
+type WsConflatedMarketDataEvent struct {
+	WsMarketDataEvent
+	// Merged is the number of quotes received for the symbol during the interval
+	Merged int
+}
+
+type WsConflatedMarketDataHandler func(event *WsConflatedMarketDataEvent)
+
+// WsMarketDataConflator merges the quotes of each symbol and emits only the
+// latest one per symbol every interval.
+type WsMarketDataConflator struct {
+	interval time.Duration
+	handler  WsConflatedMarketDataHandler
+
+	mu      sync.Mutex
+	pending map[string]*WsConflatedMarketDataEvent
+	order   []string
+
+	stopC chan struct{}
+	wg    sync.WaitGroup
+}
+
+// NewWsMarketDataConflator init a conflator, call Start to emit events every interval.
+// The interval must be positive.
+func NewWsMarketDataConflator(interval time.Duration, handler WsConflatedMarketDataHandler) (*WsMarketDataConflator, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid conflation interval %s: not positive", interval)
+	}
+	return &WsMarketDataConflator{
+		interval: interval,
+		handler:  handler,
+		pending:  make(map[string]*WsConflatedMarketDataEvent),
+	}, nil
+}
+
+// Handle merge a quote into the pending event of its symbol
+func (c *WsMarketDataConflator) Handle(event *WsMarketDataEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pending, ok := c.pending[event.SymbolName]
+	if !ok {
+		pending = new(WsConflatedMarketDataEvent)
+		c.pending[event.SymbolName] = pending
+		c.order = append(c.order, event.SymbolName)
+	}
+	pending.WsMarketDataEvent = *event
+	pending.Merged++
+}
+
+// Handler returns Handle as a WsMarketDataHandler
+func (c *WsMarketDataConflator) Handler() WsMarketDataHandler {
+	return c.Handle
+}
+
+// Flush emit the pending event of every symbol updated since the last flush,
+// in the order the symbols were first updated.
+func (c *WsMarketDataConflator) Flush() {
+	c.mu.Lock()
+	events := make([]*WsConflatedMarketDataEvent, 0, len(c.order))
+	for _, symbol := range c.order {
+		events = append(events, c.pending[symbol])
+	}
+	c.pending = make(map[string]*WsConflatedMarketDataEvent, len(events))
+	c.order = nil
+	c.mu.Unlock()
+
+	for _, event := range events {
+		c.handler(event)
+	}
+}
+
+// Start flush the pending events every interval until Stop is called
+func (c *WsMarketDataConflator) Start() {
+	c.stopC = make(chan struct{})
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.Flush()
+			case <-c.stopC:
+				return
+			}
+		}
+	}()
+}
+
+// Stop the periodic flush and emit the remaining pending events
+func (c *WsMarketDataConflator) Stop() {
+	if c.stopC == nil {
+		return
+	}
+	close(c.stopC)
+	c.wg.Wait()
+	c.stopC = nil
+	c.Flush()
+}
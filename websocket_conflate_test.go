@@ -0,0 +1,80 @@
+package go_currencycom
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type wsConflatorTestSuite struct {
+	suite.Suite
+	mu     sync.Mutex
+	events []*WsConflatedMarketDataEvent
+}
+
+func TestWsMarketDataConflator(t *testing.T) {
+	suite.Run(t, new(wsConflatorTestSuite))
+}
+
+func (s *wsConflatorTestSuite) SetupTest() {
+	s.events = nil
+}
+
+func (s *wsConflatorTestSuite) handle(event *WsConflatedMarketDataEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+}
+
+func (s *wsConflatorTestSuite) TestFlush() {
+	c, err := NewWsMarketDataConflator(time.Second, s.handle)
+	s.Require().NoError(err)
+	handler := c.Handler()
+	handler(&WsMarketDataEvent{SymbolName: "TXN", Bid: 1, Timestamp: 1})
+	handler(&WsMarketDataEvent{SymbolName: "BTC/USD", Bid: 10, Timestamp: 2})
+	handler(&WsMarketDataEvent{SymbolName: "TXN", Bid: 2, Timestamp: 3})
+	handler(&WsMarketDataEvent{SymbolName: "TXN", Bid: 3, Timestamp: 4})
+	c.Flush()
+
+	r := s.Require()
+	r.Len(s.events, 2)
+	r.Equal("TXN", s.events[0].SymbolName)
+	r.Equal(3.0, s.events[0].Bid)
+	r.Equal(int64(4), s.events[0].Timestamp)
+	r.Equal(3, s.events[0].Merged)
+	r.Equal("BTC/USD", s.events[1].SymbolName)
+	r.Equal(1, s.events[1].Merged)
+
+	// symbols without updates are not emitted again
+	c.Flush()
+	r.Len(s.events, 2)
+}
+
+func (s *wsConflatorTestSuite) TestStartStop() {
+	c, err := NewWsMarketDataConflator(10*time.Millisecond, s.handle)
+	s.Require().NoError(err)
+	c.Start()
+	c.Handle(&WsMarketDataEvent{SymbolName: "TXN", Bid: 1})
+	s.Require().Eventually(func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return len(s.events) == 1
+	}, time.Second, 5*time.Millisecond)
+
+	c.Handle(&WsMarketDataEvent{SymbolName: "TXN", Bid: 2})
+	c.Stop()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Require().Len(s.events, 2)
+	s.Require().Equal(2.0, s.events[1].Bid)
+}
+
+func (s *wsConflatorTestSuite) TestInvalidInterval() {
+	for _, interval := range []time.Duration{0, -time.Second} {
+		c, err := NewWsMarketDataConflator(interval, s.handle)
+		s.Require().Error(err)
+		s.Require().Nil(c)
+	}
+}
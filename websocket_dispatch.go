@@ -0,0 +1,295 @@
+package go_currencycom
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WsOverflowPolicy define what happens to messages when the dispatch queue is full
+type WsOverflowPolicy int
+
+const (
+	// WsOverflowBlock blocks the reader until the handler catches up
+	WsOverflowBlock WsOverflowPolicy = iota
+	// WsOverflowDropOldest discards the oldest queued message
+	WsOverflowDropOldest
+	// WsOverflowDropNewest discards the incoming message
+	WsOverflowDropNewest
+	// WsOverflowConflate keeps only the latest queued message per key.
+	// Messages without key, such as errors and acknowledgements, are always
+	// queued. It coalesces on every message, not only when the queue is
+	// full: any message waiting for the handler is replaced by a newer one
+	// of the same key. Use it for quotes, candles and depth, never for the
+	// trades stream, whose messages are not snapshots.
+	WsOverflowConflate
+)
+
+const defaultWsDispatchQueueSize = 1024
+
+// WsDispatchStats describe the state of a stream's dispatch queue
+type WsDispatchStats struct {
+	Stream   string
+	Depth    int
+	Capacity int
+	Dropped  uint64
+	// Conflated is the number of queued messages replaced by a newer one
+	Conflated uint64
+}
+
+// WsDispatchObserver is called with the queue stats on every drop and,
+// if configured, periodically.
+type WsDispatchObserver func(stats WsDispatchStats)
+
+// WsDispatchConfig define a buffered dispatch layer between the websocket
+// reader and the stream handler.
+type WsDispatchConfig struct {
+	// QueueSize is the maximum number of queued messages, 1024 if not set
+	QueueSize int
+	Policy    WsOverflowPolicy
+	// Key returns the conflation key of a message, messages with an empty key
+	// are never conflated. By default messages are conflated by symbol and
+	// interval of their payload, see wsMessageKey.
+	Key            func(message []byte) string
+	Observer       WsDispatchObserver
+	ReportInterval time.Duration
+}
+
+// WithWsDispatch decouple the websocket reader from the handler with a bounded queue
+func WithWsDispatch(cfg WsDispatchConfig) WsOption {
+	return func(c *WsConfig) {
+		c.Dispatch = &cfg
+	}
+}
+
+type wsDispatcher struct {
+	stream   string
+	cfg      WsDispatchConfig
+	capacity int
+	handler  WsHandler
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	queue     [][]byte
+	keys      []string
+	latest    map[string][]byte
+	dropped   uint64
+	conflated uint64
+	// seq numbers the keys of the messages that are never conflated
+	seq    uint64
+	closed bool
+
+	wg    sync.WaitGroup
+	stopC chan struct{}
+}
+
+func newWsDispatcher(stream string, cfg WsDispatchConfig, handler WsHandler) *wsDispatcher {
+	d := &wsDispatcher{
+		stream:   stream,
+		cfg:      cfg,
+		capacity: cfg.QueueSize,
+		handler:  handler,
+		latest:   make(map[string][]byte),
+		stopC:    make(chan struct{}),
+	}
+	if d.capacity <= 0 {
+		d.capacity = defaultWsDispatchQueueSize
+	}
+	if d.cfg.Key == nil {
+		d.cfg.Key = wsMessageKey
+	}
+	d.cond = sync.NewCond(&d.mu)
+	d.wg.Add(1)
+	go d.run()
+	if cfg.Observer != nil && cfg.ReportInterval > 0 {
+		d.wg.Add(1)
+		go d.report()
+	}
+	return d
+}
+
+// push enqueue a message according to the overflow policy
+func (d *wsDispatcher) push(message []byte) {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return
+	}
+	dropped := false
+	switch d.cfg.Policy {
+	case WsOverflowConflate:
+		key := d.cfg.Key(message)
+		if key == "" {
+			d.seq++
+			key = wsUniqueKeyPrefix + strconv.FormatUint(d.seq, 10)
+		} else if _, ok := d.latest[key]; ok {
+			d.latest[key] = message
+			d.conflated++
+			break
+		}
+		if len(d.keys) >= d.capacity {
+			dropped = d.evictLocked()
+		}
+		d.keys = append(d.keys, key)
+		d.latest[key] = message
+	case WsOverflowDropOldest:
+		if len(d.queue) >= d.capacity {
+			d.queue = d.queue[1:]
+			dropped = true
+		}
+		d.queue = append(d.queue, message)
+	case WsOverflowDropNewest:
+		if len(d.queue) >= d.capacity {
+			dropped = true
+			break
+		}
+		d.queue = append(d.queue, message)
+	default:
+		for len(d.queue) >= d.capacity && !d.closed {
+			d.cond.Wait()
+		}
+		if d.closed {
+			d.mu.Unlock()
+			return
+		}
+		d.queue = append(d.queue, message)
+	}
+	if dropped {
+		d.dropped++
+	}
+	stats := d.statsLocked()
+	d.cond.Broadcast()
+	d.mu.Unlock()
+
+	if dropped && d.cfg.Observer != nil {
+		d.cfg.Observer(stats)
+	}
+}
+
+// wsUniqueKeyPrefix starts the keys of the messages that are never
+// conflated, no symbol starts with a NUL byte
+const wsUniqueKeyPrefix = "\x00"
+
+// evictLocked discard the oldest conflated message. Messages that are never
+// conflated are kept, so the queue may grow past its capacity if it only
+// holds them.
+func (d *wsDispatcher) evictLocked() bool {
+	for i, key := range d.keys {
+		if strings.HasPrefix(key, wsUniqueKeyPrefix) {
+			continue
+		}
+		delete(d.latest, key)
+		d.keys = append(d.keys[:i], d.keys[i+1:]...)
+		return true
+	}
+	return false
+}
+
+func (d *wsDispatcher) pop() (message []byte, ok bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for d.depthLocked() == 0 && !d.closed {
+		d.cond.Wait()
+	}
+	if d.closed {
+		return nil, false
+	}
+	if d.cfg.Policy == WsOverflowConflate {
+		key := d.keys[0]
+		d.keys = d.keys[1:]
+		message = d.latest[key]
+		delete(d.latest, key)
+	} else {
+		message = d.queue[0]
+		d.queue = d.queue[1:]
+	}
+	d.cond.Broadcast()
+	return message, true
+}
+
+func (d *wsDispatcher) run() {
+	defer d.wg.Done()
+	for {
+		message, ok := d.pop()
+		if !ok {
+			return
+		}
+		d.handler(message)
+	}
+}
+
+func (d *wsDispatcher) report() {
+	defer d.wg.Done()
+	ticker := time.NewTicker(d.cfg.ReportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			d.cfg.Observer(d.stats())
+		case <-d.stopC:
+			return
+		}
+	}
+}
+
+func (d *wsDispatcher) depthLocked() int {
+	if d.cfg.Policy == WsOverflowConflate {
+		return len(d.keys)
+	}
+	return len(d.queue)
+}
+
+func (d *wsDispatcher) statsLocked() WsDispatchStats {
+	return WsDispatchStats{
+		Stream:    d.stream,
+		Depth:     d.depthLocked(),
+		Capacity:  d.capacity,
+		Dropped:   d.dropped,
+		Conflated: d.conflated,
+	}
+}
+
+func (d *wsDispatcher) stats() WsDispatchStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.statsLocked()
+}
+
+// close discard the queued messages and wait for the handler to return
+func (d *wsDispatcher) close() {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return
+	}
+	d.closed = true
+	d.cond.Broadcast()
+	d.mu.Unlock()
+	close(d.stopC)
+	d.wg.Wait()
+}
+
+// wsMessageKey returns the symbol and interval of a message payload, or an
+// empty key for errors and messages without symbol
+func wsMessageKey(message []byte) string {
+	var m struct {
+		Status  string `json:"status"`
+		Payload struct {
+			Symbol     string `json:"symbol"`
+			SymbolName string `json:"symbolName"`
+			Interval   string `json:"interval"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(message, &m); err != nil || m.Status != wsStatusOK {
+		return ""
+	}
+	key := m.Payload.Symbol
+	if key == "" {
+		key = m.Payload.SymbolName
+	}
+	if key != "" && m.Payload.Interval != "" {
+		key += "@" + m.Payload.Interval
+	}
+	return key
+}
@@ -0,0 +1,302 @@
+package go_currencycom
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type wsDispatcherTestSuite struct {
+	suite.Suite
+	release  chan struct{}
+	received chan string
+}
+
+func TestWsDispatcher(t *testing.T) {
+	suite.Run(t, new(wsDispatcherTestSuite))
+}
+
+func (s *wsDispatcherTestSuite) SetupTest() {
+	s.release = make(chan struct{})
+	s.received = make(chan string, 16)
+}
+
+// newDispatcher returns a dispatcher whose handler is stuck on the first
+// message until release is closed.
+func (s *wsDispatcherTestSuite) newDispatcher(cfg WsDispatchConfig) *wsDispatcher {
+	d := newWsDispatcher("test", cfg, func(message []byte) {
+		<-s.release
+		s.received <- string(message)
+	})
+	d.push([]byte("first"))
+	s.Require().Eventually(func() bool {
+		return d.stats().Depth == 0
+	}, time.Second, 10*time.Millisecond)
+	return d
+}
+
+func (s *wsDispatcherTestSuite) drain(n int) []string {
+	close(s.release)
+	res := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		res = append(res, <-s.received)
+	}
+	return res
+}
+
+func (s *wsDispatcherTestSuite) TestDropOldest() {
+	var mu sync.Mutex
+	var observed []WsDispatchStats
+	d := s.newDispatcher(WsDispatchConfig{
+		QueueSize: 2,
+		Policy:    WsOverflowDropOldest,
+		Observer: func(stats WsDispatchStats) {
+			mu.Lock()
+			defer mu.Unlock()
+			observed = append(observed, stats)
+		},
+	})
+	defer d.close()
+	for i := 1; i <= 4; i++ {
+		d.push([]byte(fmt.Sprint(i)))
+	}
+	r := s.Require()
+	r.Equal(WsDispatchStats{Stream: "test", Depth: 2, Capacity: 2, Dropped: 2}, d.stats())
+	r.Equal([]string{"first", "3", "4"}, s.drain(3))
+	mu.Lock()
+	defer mu.Unlock()
+	r.Len(observed, 2)
+	r.Equal(uint64(2), observed[1].Dropped)
+}
+
+func (s *wsDispatcherTestSuite) TestDropNewest() {
+	d := s.newDispatcher(WsDispatchConfig{
+		QueueSize: 2,
+		Policy:    WsOverflowDropNewest,
+	})
+	defer d.close()
+	for i := 1; i <= 4; i++ {
+		d.push([]byte(fmt.Sprint(i)))
+	}
+	s.Require().Equal(uint64(2), d.stats().Dropped)
+	s.Require().Equal([]string{"first", "1", "2"}, s.drain(3))
+}
+
+func (s *wsDispatcherTestSuite) TestConflate() {
+	d := s.newDispatcher(WsDispatchConfig{
+		QueueSize: 8,
+		Policy:    WsOverflowConflate,
+	})
+	defer d.close()
+	d.push([]byte(`{"status":"OK","payload":{"symbolName":"A","bid":1}}`))
+	d.push([]byte(`{"status":"OK","payload":{"symbolName":"B","bid":1}}`))
+	d.push([]byte(`{"status":"OK","payload":{"symbolName":"A","bid":2}}`))
+	r := s.Require()
+	r.Equal(WsDispatchStats{Stream: "test", Depth: 2, Capacity: 8, Conflated: 1}, d.stats())
+	r.Equal([]string{
+		"first",
+		`{"status":"OK","payload":{"symbolName":"A","bid":2}}`,
+		`{"status":"OK","payload":{"symbolName":"B","bid":1}}`,
+	}, s.drain(3))
+}
+
+func (s *wsDispatcherTestSuite) TestConflateKeepsMessagesWithoutKey() {
+	d := s.newDispatcher(WsDispatchConfig{
+		QueueSize: 2,
+		Policy:    WsOverflowConflate,
+	})
+	defer d.close()
+	errorMessage := `{"status":"ERROR","payload":{"errorMessage":"invalid symbol"}}`
+	ack := `{"status":"OK","destination":"marketData.subscribe","payload":{"subscriptions":["A"]}}`
+	d.push([]byte(errorMessage))
+	d.push([]byte(ack))
+	d.push([]byte(`{"status":"OK","payload":{"symbolName":"A","bid":1}}`))
+	d.push([]byte(`{"status":"OK","payload":{"symbolName":"B","bid":1}}`))
+	r := s.Require()
+	stats := d.stats()
+	r.Equal(3, stats.Depth)
+	r.Equal(uint64(1), stats.Dropped)
+	r.Equal(uint64(0), stats.Conflated)
+	r.Equal([]string{
+		"first",
+		errorMessage,
+		ack,
+		`{"status":"OK","payload":{"symbolName":"B","bid":1}}`,
+	}, s.drain(4))
+}
+
+func (s *wsDispatcherTestSuite) TestBlock() {
+	d := s.newDispatcher(WsDispatchConfig{
+		QueueSize: 1,
+		Policy:    WsOverflowBlock,
+	})
+	defer d.close()
+	d.push([]byte("1"))
+	pushed := make(chan struct{})
+	go func() {
+		d.push([]byte("2"))
+		close(pushed)
+	}()
+	select {
+	case <-pushed:
+		s.Fail("push should block while the queue is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+	s.Require().Equal([]string{"first", "1", "2"}, s.drain(3))
+	<-pushed
+	s.Require().Equal(uint64(0), d.stats().Dropped)
+}
+
+func (s *wsDispatcherTestSuite) TestWsMessageKey() {
+	r := s.Require()
+	r.Equal("TXN", wsMessageKey([]byte(`{"status":"OK","payload":{"symbolName":"TXN"}}`)))
+	r.Equal("BTC/USD@1m", wsMessageKey([]byte(`{"status":"OK","payload":{"symbol":"BTC/USD","interval":"1m"}}`)))
+	r.Equal("", wsMessageKey([]byte(`{"status":"ERROR","payload":{"symbol":"BTC/USD"}}`)))
+	r.Equal("", wsMessageKey([]byte(`{"status":"OK","payload":{"interval":"1m"}}`)))
+	r.Equal("", wsMessageKey([]byte(`not json`)))
+}
+
+// wsDispatchServeTestSuite runs the dispatch layer behind wsServe, against
+// a server pushing frames once it received the subscription
+type wsDispatchServeTestSuite struct {
+	suite.Suite
+	server *httptest.Server
+	frames []string
+	// resume, if set, holds the frames after the first one until closed
+	resume chan struct{}
+}
+
+func TestWsDispatchServe(t *testing.T) {
+	suite.Run(t, new(wsDispatchServeTestSuite))
+}
+
+func (s *wsDispatchServeTestSuite) SetupTest() {
+	s.frames = nil
+	s.resume = nil
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+		for i, frame := range s.frames {
+			if i == 1 && s.resume != nil {
+				<-s.resume
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(frame)); err != nil {
+				return
+			}
+		}
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+}
+
+func (s *wsDispatchServeTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *wsDispatchServeTestSuite) endpoint() WsOption {
+	return WithWsEndpoint("ws" + strings.TrimPrefix(s.server.URL, "http"))
+}
+
+func (s *wsDispatchServeTestSuite) quote(symbol string, bid float64) string {
+	return fmt.Sprintf(`{"status":"OK","destination":"internal.quote","payload":{"symbolName":%q,"bid":%v}}`, symbol, bid)
+}
+
+func (s *wsDispatchServeTestSuite) TestConflate() {
+	for i := 1; i <= 5; i++ {
+		s.frames = append(s.frames, s.quote("TXN", float64(i)))
+	}
+	s.frames = append(s.frames, s.quote("BTC/USD", 100))
+	s.resume = make(chan struct{})
+	release := make(chan struct{})
+	events := make(chan *WsMarketDataEvent, 10)
+	var conflated atomic.Uint64
+	doneC, stopC, err := WsMarketDataServe([]string{"TXN", "BTC/USD"}, func(event *WsMarketDataEvent) {
+		if event.Bid == 1 {
+			close(s.resume)
+		}
+		<-release
+		events <- event
+	}, func(err error) {}, s.endpoint(), WithWsDispatch(WsDispatchConfig{
+		Policy: WsOverflowConflate,
+		Observer: func(stats WsDispatchStats) {
+			conflated.Store(stats.Conflated)
+		},
+		ReportInterval: time.Millisecond,
+	}))
+	r := s.Require()
+	r.NoError(err)
+	// the handler holds the first quote while the next ones are coalesced
+	r.Eventually(func() bool {
+		return conflated.Load() == 3
+	}, time.Second, time.Millisecond)
+	close(release)
+	var received []string
+	for i := 0; i < 3; i++ {
+		event := <-events
+		received = append(received, fmt.Sprintf("%s %v", event.SymbolName, event.Bid))
+	}
+	r.Equal([]string{"TXN 1", "TXN 5", "BTC/USD 100"}, received)
+	close(stopC)
+	<-doneC
+}
+
+func (s *wsDispatchServeTestSuite) TestDoneAfterHandler() {
+	s.frames = []string{s.quote("TXN", 1), s.quote("TXN", 2)}
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	var returned atomic.Bool
+	doneC, stopC, err := WsMarketDataServe([]string{"TXN"}, func(event *WsMarketDataEvent) {
+		started <- struct{}{}
+		<-release
+		returned.Store(true)
+	}, func(err error) {}, s.endpoint(), WithWsDispatch(WsDispatchConfig{Policy: WsOverflowBlock}))
+	r := s.Require()
+	r.NoError(err)
+	<-started
+	close(stopC)
+	select {
+	case <-doneC:
+		s.Fail("done before the handler returned")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-doneC
+	r.True(returned.Load())
+}
+
+func (s *wsDispatchServeTestSuite) TestStream() {
+	s.frames = []string{s.quote("TXN", 1), s.quote("TXN", 2), s.quote("TXN", 3)}
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := WsMarketDataStream(ctx, []string{"TXN"}, s.endpoint(),
+		WithWsDispatch(WsDispatchConfig{Policy: WsOverflowDropNewest, QueueSize: 10}))
+	r := s.Require()
+	r.NoError(err)
+	for i := 1; i <= 3; i++ {
+		r.Equal(float64(i), (<-stream.C()).Bid)
+	}
+	cancel()
+	<-stream.Done()
+	r.ErrorIs(stream.Err(), context.Canceled)
+	_, ok := <-stream.C()
+	r.False(ok)
+}
@@ -0,0 +1,576 @@
+package go_currencycom
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// WsHubMaxKeys is the maximum number of symbols, or symbol and interval
+	// pairs, subscribed on one upstream connection of a WsHub. Streams with
+	// more are split over several connections.
+	WsHubMaxKeys = 100
+	// WsHubAckTimeout is the time a WsHub waits for the upstream to
+	// acknowledge a subscription
+	WsHubAckTimeout = 10 * time.Second
+)
+
+// ErrWsHubUpstreamClosed is reported when an upstream connection of a WsHub
+// drops. The hub reconnects it and subscribes its symbols again.
+var ErrWsHubUpstreamClosed = errors.New("upstream connection closed")
+
+// Streams of a WsHub
+const (
+	wsHubMarketData     = "marketData"
+	wsHubOHLCMarketData = "OHLCMarketData"
+	wsHubTrades         = "trades"
+	wsHubDepth          = "depthMarketData"
+)
+
+const (
+	defaultWsHubMinBackoff = time.Second
+	defaultWsHubMaxBackoff = time.Minute
+)
+
+type wsHubKey struct {
+	stream   string
+	symbol   string
+	interval string
+}
+
+// wsHubUpstream is a live connection of a wsHubConn
+type wsHubUpstream struct {
+	requests chan WsRequest
+	doneC    chan struct{}
+	stopC    chan struct{}
+
+	mu   sync.Mutex
+	acks map[int]chan *WsResponse
+}
+
+// send a request, false if the connection is closed
+func (u *wsHubUpstream) send(request WsRequest) bool {
+	select {
+	case u.requests <- request:
+		return true
+	case <-u.doneC:
+		return false
+	}
+}
+
+// request send a request and wait for its reply
+func (u *wsHubUpstream) request(request WsRequest) (*WsResponse, error) {
+	ackC := make(chan *WsResponse, 1)
+	u.mu.Lock()
+	u.acks[request.CorrelationID] = ackC
+	u.mu.Unlock()
+	defer func() {
+		u.mu.Lock()
+		delete(u.acks, request.CorrelationID)
+		u.mu.Unlock()
+	}()
+	if !u.send(request) {
+		return nil, ErrWsHubUpstreamClosed
+	}
+	timer := time.NewTimer(WsHubAckTimeout)
+	defer timer.Stop()
+	select {
+	case res := <-ackC:
+		if res.Status != wsStatusOK {
+			return nil, newWsError(res)
+		}
+		return res, nil
+	case <-u.doneC:
+		return nil, ErrWsHubUpstreamClosed
+	case <-timer.C:
+		return nil, fmt.Errorf("%s: no reply within %s", request.Destination, WsHubAckTimeout)
+	}
+}
+
+// reply pass a response to the request waiting for it, false if there is none
+func (u *wsHubUpstream) reply(res *WsResponse) bool {
+	id, err := strconv.Atoi(string(res.CorrelationID))
+	if err != nil {
+		return false
+	}
+	u.mu.Lock()
+	ackC, ok := u.acks[id]
+	u.mu.Unlock()
+	if ok {
+		select {
+		case ackC <- res:
+		default:
+		}
+	}
+	return ok
+}
+
+// wsHubConn is a connection shared by up to WsHubMaxKeys keys of a stream.
+// Its goroutine dials it, and redials it with backoff when it drops, until
+// its last key is removed.
+type wsHubConn struct {
+	stream string
+	stopC  chan struct{}
+	// dialedC is closed after the first dial
+	dialedC chan struct{}
+
+	// guarded by the mutex of the hub
+	keys     map[wsHubKey]bool
+	upstream *wsHubUpstream
+	dialed   bool
+	dialErr  error
+	stopped  bool
+}
+
+// WsHub shares upstream connections between any number of local
+// subscribers. Each stream has its own connections, split in chunks of
+// WsHubMaxKeys, and symbols are added to and removed from them as the
+// first subscriber arrives and the last one leaves. Dropped connections
+// are redialed with backoff and their symbols subscribed again; the drops
+// are reported to the error handler as ErrWsHubUpstreamClosed.
+// Events are shared between subscribers and must not be modified.
+type WsHub struct {
+	errHandler    ErrHandler
+	opts          []WsOption
+	minBackoff    time.Duration
+	maxBackoff    time.Duration
+	correlationID atomic.Int64
+
+	mu          sync.RWMutex
+	subscribers map[wsHubKey]map[int]func(event interface{})
+	conns       map[wsHubKey]*wsHubConn
+	streams     map[string][]*wsHubConn
+	nextID      int
+}
+
+// WsHubSubscription is the handle of a local subscription
+type WsHubSubscription struct {
+	hub  *WsHub
+	id   int
+	keys []wsHubKey
+	once sync.Once
+	// Acks are the upstream replies to the subscription of the symbols
+	// that had no subscriber yet
+	Acks []*WsResponse
+}
+
+// NewWsHub init a hub, errHandler receives the errors of every upstream
+// connection and opts are applied to each of them.
+func NewWsHub(errHandler ErrHandler, opts ...WsOption) *WsHub {
+	return &WsHub{
+		errHandler:  errHandler,
+		opts:        opts,
+		minBackoff:  defaultWsHubMinBackoff,
+		maxBackoff:  defaultWsHubMaxBackoff,
+		subscribers: make(map[wsHubKey]map[int]func(event interface{})),
+		conns:       make(map[wsHubKey]*wsHubConn),
+		streams:     make(map[string][]*wsHubConn),
+	}
+}
+
+func (h *WsHub) handleErr(err error) {
+	if h.errHandler != nil {
+		h.errHandler(err)
+	}
+}
+
+func symbolKeys(stream string, symbols []string) []wsHubKey {
+	keys := make([]wsHubKey, 0, len(symbols))
+	for _, symbol := range symbols {
+		keys = append(keys, wsHubKey{stream: stream, symbol: symbol})
+	}
+	return keys
+}
+
+// SubscribeMarketData deliver the quotes of symbols accepted by filter to handler.
+// A nil filter accepts every event.
+func (h *WsHub) SubscribeMarketData(symbols []string, handler WsMarketDataHandler, filter func(event *WsMarketDataEvent) bool) (*WsHubSubscription, error) {
+	return h.subscribe(symbolKeys(wsHubMarketData, symbols), func(e interface{}) {
+		event := e.(*WsMarketDataEvent)
+		if filter == nil || filter(event) {
+			handler(event)
+		}
+	})
+}
+
+// SubscribeOHLCMarketData deliver the candles of symbols and intervals accepted by filter to handler.
+// A nil filter accepts every event.
+func (h *WsHub) SubscribeOHLCMarketData(symbols []string, intervals []string, handler WsOHLCMarketDataHandler, filter func(event *WsOHLCMarketDataEvent) bool) (*WsHubSubscription, error) {
+	keys := make([]wsHubKey, 0, len(symbols)*len(intervals))
+	for _, symbol := range symbols {
+		for _, interval := range intervals {
+			keys = append(keys, wsHubKey{stream: wsHubOHLCMarketData, symbol: symbol, interval: interval})
+		}
+	}
+	return h.subscribe(keys, func(e interface{}) {
+		event := e.(*WsOHLCMarketDataEvent)
+		if filter == nil || filter(event) {
+			handler(event)
+		}
+	})
+}
+
+// SubscribeTrades deliver the trades of symbols accepted by filter to handler.
+// A nil filter accepts every event.
+func (h *WsHub) SubscribeTrades(symbols []string, handler WsTradesHandler, filter func(event *WsTradesEvent) bool) (*WsHubSubscription, error) {
+	return h.subscribe(symbolKeys(wsHubTrades, symbols), func(e interface{}) {
+		event := e.(*WsTradesEvent)
+		if filter == nil || filter(event) {
+			handler(event)
+		}
+	})
+}
+
+// SubscribeDepth deliver the order book updates of symbols accepted by filter to handler.
+// A nil filter accepts every event.
+func (h *WsHub) SubscribeDepth(symbols []string, handler WsDepthHandler, filter func(event *WsDepthEvent) bool) (*WsHubSubscription, error) {
+	return h.subscribe(symbolKeys(wsHubDepth, symbols), func(e interface{}) {
+		event := e.(*WsDepthEvent)
+		if filter == nil || filter(event) {
+			handler(event)
+		}
+	})
+}
+
+// subscribe register deliver for keys, and subscribe the keys without
+// subscriber on their connection. Connections are dialed and requests sent
+// without holding the mutex of the hub.
+func (h *WsHub) subscribe(keys []wsHubKey, deliver func(event interface{})) (*WsHubSubscription, error) {
+	h.mu.Lock()
+	h.nextID++
+	sub := &WsHubSubscription{hub: h, id: h.nextID, keys: keys, Acks: []*WsResponse{}}
+	conns := make([]*wsHubConn, 0)
+	added := make(map[*wsHubConn][]wsHubKey)
+	for _, key := range keys {
+		subscribers, ok := h.subscribers[key]
+		if !ok {
+			subscribers = make(map[int]func(event interface{}))
+			h.subscribers[key] = subscribers
+			conn := h.connLocked(key.stream)
+			conn.keys[key] = true
+			h.conns[key] = conn
+			if _, ok := added[conn]; !ok {
+				conns = append(conns, conn)
+			}
+			added[conn] = append(added[conn], key)
+		}
+		subscribers[sub.id] = deliver
+	}
+	h.mu.Unlock()
+
+	for _, conn := range conns {
+		<-conn.dialedC
+		h.mu.RLock()
+		upstream, err := conn.upstream, conn.dialErr
+		h.mu.RUnlock()
+		if err != nil {
+			sub.Close()
+			return nil, err
+		}
+		if upstream == nil {
+			// reconnecting, the keys are subscribed with the others once connected
+			continue
+		}
+		for _, request := range h.requests(conn.stream, "subscribe", added[conn]) {
+			res, err := upstream.request(request)
+			if err != nil {
+				sub.Close()
+				return nil, err
+			}
+			sub.Acks = append(sub.Acks, res)
+		}
+	}
+	return sub, nil
+}
+
+// connLocked returns a connection of stream with room for a key, starting
+// a new one if they are all full
+func (h *WsHub) connLocked(stream string) *wsHubConn {
+	for _, conn := range h.streams[stream] {
+		if len(conn.keys) < WsHubMaxKeys {
+			return conn
+		}
+	}
+	conn := &wsHubConn{
+		stream:  stream,
+		stopC:   make(chan struct{}),
+		dialedC: make(chan struct{}),
+		keys:    make(map[wsHubKey]bool),
+	}
+	h.streams[stream] = append(h.streams[stream], conn)
+	go h.run(conn)
+	return conn
+}
+
+// run dial conn until it is stopped, subscribing its keys again after each
+// reconnection
+func (h *WsHub) run(conn *wsHubConn) {
+	backoff := h.minBackoff
+	for {
+		upstream, err := h.dial(conn.stream)
+		h.mu.Lock()
+		first := !conn.dialed
+		if first {
+			conn.dialed = true
+			conn.dialErr = err
+			close(conn.dialedC)
+		}
+		stopped := conn.stopped
+		var keys []wsHubKey
+		if err == nil && !stopped {
+			conn.upstream = upstream
+			conn.dialErr = nil
+			if !first {
+				keys = conn.sortedKeysLocked()
+			}
+		}
+		h.mu.Unlock()
+		if stopped {
+			if err == nil {
+				close(upstream.stopC)
+			}
+			return
+		}
+
+		if err != nil {
+			// the error of the first dial is returned to the subscribers
+			if !first {
+				h.handleErr(fmt.Errorf("%s: reconnect: %w", conn.stream, err))
+			}
+		} else {
+			for _, request := range h.requests(conn.stream, "subscribe", keys) {
+				upstream.send(request)
+			}
+			backoff = h.minBackoff
+			select {
+			case <-upstream.doneC:
+			case <-conn.stopC:
+				close(upstream.stopC)
+				return
+			}
+			h.mu.Lock()
+			conn.upstream = nil
+			stopped = conn.stopped
+			h.mu.Unlock()
+			if stopped {
+				return
+			}
+			h.handleErr(fmt.Errorf("%s: %w, reconnecting", conn.stream, ErrWsHubUpstreamClosed))
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-conn.stopC:
+			return
+		}
+		backoff *= 2
+		if backoff > h.maxBackoff {
+			backoff = h.maxBackoff
+		}
+	}
+}
+
+func (conn *wsHubConn) sortedKeysLocked() []wsHubKey {
+	keys := make([]wsHubKey, 0, len(conn.keys))
+	for key := range conn.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].interval != keys[j].interval {
+			return keys[i].interval < keys[j].interval
+		}
+		return keys[i].symbol < keys[j].symbol
+	})
+	return keys
+}
+
+// dial open a connection routing the events of stream to the subscribers
+func (h *WsHub) dial(stream string) (*wsHubUpstream, error) {
+	u := &wsHubUpstream{
+		requests: make(chan WsRequest),
+		acks:     make(map[int]chan *WsResponse),
+	}
+	router := newWsRouter(h.handleErr)
+	router.replies = u.reply
+	switch stream {
+	case wsHubMarketData:
+		router.onMarketData(func(event *WsMarketDataEvent) {
+			h.deliver(wsHubKey{stream: stream, symbol: event.SymbolName}, event)
+		})
+	case wsHubOHLCMarketData:
+		router.onOHLCMarketData(func(event *WsOHLCMarketDataEvent) {
+			h.deliver(wsHubKey{stream: stream, symbol: event.Symbol, interval: event.Interval}, event)
+		})
+	case wsHubTrades:
+		router.onTrades(func(event *WsTradesEvent) {
+			h.deliver(wsHubKey{stream: stream, symbol: event.Symbol}, event)
+		})
+	case wsHubDepth:
+		router.onDepth(func(event *WsDepthEvent) {
+			h.deliver(wsHubKey{stream: stream, symbol: event.Symbol}, event)
+		})
+	}
+	config := newWsConfig(getWsEndpoint(), stream, h.opts...)
+	doneC, stopC, err := wsServe(config, u.requests, router.handle, h.handleErr)
+	if err != nil {
+		return nil, err
+	}
+	u.doneC, u.stopC = doneC, stopC
+	return u, nil
+}
+
+// requests returns the requests to subscribe or unsubscribe (action) keys,
+// one per interval for candles
+func (h *WsHub) requests(stream string, action string, keys []wsHubKey) []WsRequest {
+	intervals := make([]string, 0)
+	symbols := make(map[string][]string)
+	for _, key := range keys {
+		if _, ok := symbols[key.interval]; !ok {
+			intervals = append(intervals, key.interval)
+		}
+		symbols[key.interval] = append(symbols[key.interval], key.symbol)
+	}
+	res := make([]WsRequest, 0, len(intervals))
+	for _, interval := range intervals {
+		p := payload{"symbols": symbols[interval]}
+		if stream == wsHubOHLCMarketData {
+			p["intervals"] = []string{interval}
+		}
+		res = append(res, WsRequest{
+			Destination:   stream + "." + action,
+			CorrelationID: int(h.correlationID.Add(1)),
+			Payload:       p,
+		})
+	}
+	return res
+}
+
+func (h *WsHub) deliver(key wsHubKey, event interface{}) {
+	h.mu.RLock()
+	subscribers := make([]func(event interface{}), 0, len(h.subscribers[key]))
+	for _, f := range h.subscribers[key] {
+		subscribers = append(subscribers, f)
+	}
+	h.mu.RUnlock()
+	for _, f := range subscribers {
+		f(event)
+	}
+}
+
+// unsubscribe remove the subscriber from the keys accepted by match, or from
+// every key if match is nil, unsubscribe the keys left without subscriber
+// and close the connections left without key
+func (h *WsHub) unsubscribe(sub *WsHubSubscription, match func(key wsHubKey) bool) {
+	h.mu.Lock()
+	conns := make([]*wsHubConn, 0)
+	removed := make(map[*wsHubConn][]wsHubKey)
+	upstreams := make(map[*wsHubConn]*wsHubUpstream)
+	kept := make([]wsHubKey, 0)
+	for _, key := range sub.keys {
+		if match != nil && !match(key) {
+			kept = append(kept, key)
+			continue
+		}
+		subscribers, ok := h.subscribers[key]
+		if !ok {
+			continue
+		}
+		delete(subscribers, sub.id)
+		if len(subscribers) > 0 {
+			continue
+		}
+		delete(h.subscribers, key)
+		conn := h.conns[key]
+		delete(h.conns, key)
+		delete(conn.keys, key)
+		if len(conn.keys) == 0 {
+			h.stopLocked(conn)
+			continue
+		}
+		if conn.upstream == nil {
+			continue
+		}
+		if _, ok := removed[conn]; !ok {
+			conns = append(conns, conn)
+			upstreams[conn] = conn.upstream
+		}
+		removed[conn] = append(removed[conn], key)
+	}
+	sub.keys = kept
+	h.mu.Unlock()
+
+	for _, conn := range conns {
+		for _, request := range h.requests(conn.stream, "unsubscribe", removed[conn]) {
+			upstreams[conn].send(request)
+		}
+	}
+}
+
+func (h *WsHub) stopLocked(conn *wsHubConn) {
+	if conn.stopped {
+		return
+	}
+	conn.stopped = true
+	close(conn.stopC)
+	conns := h.streams[conn.stream]
+	for i, c := range conns {
+		if c == conn {
+			h.streams[conn.stream] = append(conns[:i:i], conns[i+1:]...)
+			break
+		}
+	}
+	if len(h.streams[conn.stream]) == 0 {
+		delete(h.streams, conn.stream)
+	}
+}
+
+// Subscribers returns the number of local subscribers per symbol of a stream
+// ("marketData", "OHLCMarketData", "trades" or "depthMarketData").
+func (h *WsHub) Subscribers(stream string) map[string]int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	res := make(map[string]int)
+	for key, subscribers := range h.subscribers {
+		if key.stream == stream {
+			res[key.symbol] += len(subscribers)
+		}
+	}
+	return res
+}
+
+// Close stop every upstream connection of the hub
+func (h *WsHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, conns := range h.streams {
+		for _, conn := range conns {
+			h.stopLocked(conn)
+		}
+	}
+	h.subscribers = make(map[wsHubKey]map[int]func(event interface{}))
+	h.conns = make(map[wsHubKey]*wsHubConn)
+}
+
+// Close remove the subscriber from the hub
+func (s *WsHubSubscription) Close() {
+	s.once.Do(func() {
+		s.hub.unsubscribe(s, nil)
+	})
+}
+
+// Unsubscribe remove symbols from the subscription, of every interval for
+// candles. The other symbols are still delivered until Close.
+func (s *WsHubSubscription) Unsubscribe(symbols ...string) {
+	set := make(map[string]bool, len(symbols))
+	for _, symbol := range symbols {
+		set[symbol] = true
+	}
+	s.hub.unsubscribe(s, func(key wsHubKey) bool {
+		return set[key.symbol]
+	})
+}
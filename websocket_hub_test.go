@@ -0,0 +1,313 @@
+package go_currencycom
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type wsHubUpstreamMock struct {
+	handler WsHandler
+	doneC   chan struct{}
+	stopC   chan struct{}
+	dropC   chan struct{}
+
+	mu       sync.Mutex
+	requests []WsRequest
+}
+
+func (m *wsHubUpstreamMock) received() []WsRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]WsRequest{}, m.requests...)
+}
+
+type wsHubServeMock struct {
+	mu        sync.Mutex
+	upstreams []*wsHubUpstreamMock
+	// status of the replies to the requests
+	status string
+}
+
+func (m *wsHubServeMock) upstream(i int) *wsHubUpstreamMock {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.upstreams[i]
+}
+
+func (m *wsHubServeMock) count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.upstreams)
+}
+
+// mockWsHubServe records every upstream connection of the hub and replies
+// to its requests
+func (s *websocketServiceTestSuite) mockWsHubServe() *wsHubServeMock {
+	mock := &wsHubServeMock{status: "OK"}
+	wsServe = func(cfg *WsConfig, requests chan WsRequest, handler WsHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
+		mock.mu.Lock()
+		defer mock.mu.Unlock()
+		m := &wsHubUpstreamMock{
+			handler: handler,
+			doneC:   make(chan struct{}),
+			stopC:   make(chan struct{}),
+			dropC:   make(chan struct{}),
+		}
+		status := mock.status
+		go func() {
+			for {
+				select {
+				case request := <-requests:
+					m.mu.Lock()
+					m.requests = append(m.requests, request)
+					m.mu.Unlock()
+					handler([]byte(fmt.Sprintf(`{"status":%q,"destination":%q,"correlationId":%d,"payload":{}}`,
+						status, request.Destination, request.CorrelationID)))
+				case <-m.doneC:
+					return
+				}
+			}
+		}()
+		go func() {
+			select {
+			case <-m.stopC:
+			case <-m.dropC:
+			}
+			close(m.doneC)
+		}()
+		mock.upstreams = append(mock.upstreams, m)
+		return m.doneC, m.stopC, nil
+	}
+	return mock
+}
+
+func (s *websocketServiceTestSuite) TestWsHubSharesUpstream() {
+	mock := s.mockWsHubServe()
+	hub := NewWsHub(func(err error) {
+		s.Fail("unexpected error", err)
+	})
+	defer hub.Close()
+
+	var first, second []*WsMarketDataEvent
+	sub1, err := hub.SubscribeMarketData([]string{"TXN", "BTC/USD"}, func(event *WsMarketDataEvent) {
+		first = append(first, event)
+	}, nil)
+	r := s.r()
+	r.NoError(err)
+	r.Len(sub1.Acks, 1)
+	sub2, err := hub.SubscribeMarketData([]string{"TXN", "ETH/USD"}, func(event *WsMarketDataEvent) {
+		second = append(second, event)
+	}, func(event *WsMarketDataEvent) bool {
+		return event.Bid > 100
+	})
+	r.NoError(err)
+	r.Equal(1, mock.count())
+	r.Equal(map[string]int{"TXN": 2, "BTC/USD": 1, "ETH/USD": 1}, hub.Subscribers("marketData"))
+
+	upstream := mock.upstream(0)
+	requests := upstream.received()
+	r.Len(requests, 2)
+	r.Equal("marketData.subscribe", requests[0].Destination)
+	r.Equal([]string{"TXN", "BTC/USD"}, requests[0].Payload["symbols"])
+	// symbols with a subscriber are not subscribed again
+	r.Equal([]string{"ETH/USD"}, requests[1].Payload["symbols"])
+	r.NotEqual(requests[0].CorrelationID, requests[1].CorrelationID)
+
+	upstream.handler([]byte(`{"status":"OK","destination":"internal.quote","payload":{"symbolName":"TXN","bid":139.85}}`))
+	upstream.handler([]byte(`{"status":"OK","destination":"internal.quote","payload":{"symbolName":"TXN","bid":99.5}}`))
+	r.Len(first, 2)
+	r.Len(second, 1)
+	r.Equal(139.85, second[0].Bid)
+
+	sub1.Close()
+	sub1.Close()
+	r.Equal(map[string]int{"TXN": 1, "ETH/USD": 1}, hub.Subscribers("marketData"))
+	r.Eventually(func() bool {
+		return len(upstream.received()) == 3
+	}, time.Second, time.Millisecond)
+	requests = upstream.received()
+	r.Equal("marketData.unsubscribe", requests[2].Destination)
+	r.Equal([]string{"BTC/USD"}, requests[2].Payload["symbols"])
+	select {
+	case <-upstream.doneC:
+		s.Fail("upstream closed while a subscriber is left")
+	default:
+	}
+
+	sub2.Close()
+	<-upstream.doneC
+	r.Empty(hub.Subscribers("marketData"))
+}
+
+func (s *websocketServiceTestSuite) TestWsHubUnsubscribeSymbols() {
+	mock := s.mockWsHubServe()
+	hub := NewWsHub(func(err error) {
+		s.Fail("unexpected error", err)
+	})
+	defer hub.Close()
+
+	var events []*WsMarketDataEvent
+	sub, err := hub.SubscribeMarketData([]string{"TXN", "BTC/USD"}, func(event *WsMarketDataEvent) {
+		events = append(events, event)
+	}, nil)
+	r := s.r()
+	r.NoError(err)
+	sub.Unsubscribe("BTC/USD")
+	r.Equal(map[string]int{"TXN": 1}, hub.Subscribers("marketData"))
+
+	upstream := mock.upstream(0)
+	r.Eventually(func() bool {
+		return len(upstream.received()) == 2
+	}, time.Second, time.Millisecond)
+	requests := upstream.received()
+	r.Equal("marketData.unsubscribe", requests[1].Destination)
+	r.Equal([]string{"BTC/USD"}, requests[1].Payload["symbols"])
+
+	upstream.handler([]byte(`{"status":"OK","destination":"internal.quote","payload":{"symbolName":"BTC/USD","bid":1}}`))
+	upstream.handler([]byte(`{"status":"OK","destination":"internal.quote","payload":{"symbolName":"TXN","bid":2}}`))
+	r.Len(events, 1)
+	r.Equal("TXN", events[0].SymbolName)
+
+	sub.Close()
+	<-upstream.doneC
+	r.Empty(hub.Subscribers("marketData"))
+}
+
+func (s *websocketServiceTestSuite) TestWsHubSplitsUpstreams() {
+	defer func(n int) {
+		WsHubMaxKeys = n
+	}(WsHubMaxKeys)
+	WsHubMaxKeys = 2
+	mock := s.mockWsHubServe()
+	hub := NewWsHub(nil)
+	defer hub.Close()
+
+	var events []*WsOHLCMarketDataEvent
+	sub, err := hub.SubscribeOHLCMarketData([]string{"BTC/USD", "TXN"}, []string{"1m", "5m"}, func(event *WsOHLCMarketDataEvent) {
+		events = append(events, event)
+	}, nil)
+	r := s.r()
+	r.NoError(err)
+	defer sub.Close()
+	r.Equal(2, mock.count())
+	// each connection gets the intervals of one symbol, one request per interval
+	var txn *wsHubUpstreamMock
+	for i := 0; i < 2; i++ {
+		requests := mock.upstream(i).received()
+		r.Len(requests, 2)
+		r.Equal("OHLCMarketData.subscribe", requests[0].Destination)
+		r.Equal([]string{"1m"}, requests[0].Payload["intervals"])
+		r.Equal([]string{"5m"}, requests[1].Payload["intervals"])
+		r.Equal(requests[0].Payload["symbols"], requests[1].Payload["symbols"])
+		if requests[0].Payload["symbols"].([]string)[0] == "TXN" {
+			txn = mock.upstream(i)
+		}
+	}
+	r.NotNil(txn)
+
+	// candles are routed by symbol and interval
+	txn.handler([]byte(`{"status":"OK","destination":"ohlc.event","payload":{"symbol":"TXN","interval":"5m","c":1}}`))
+	txn.handler([]byte(`{"status":"OK","destination":"ohlc.event","payload":{"symbol":"TXN","interval":"15m","c":1}}`))
+	r.Len(events, 1)
+	r.Equal("5m", events[0].Interval)
+}
+
+func (s *websocketServiceTestSuite) TestWsHubReconnectsClosedUpstream() {
+	mock := s.mockWsHubServe()
+	errC := make(chan error, 10)
+	hub := NewWsHub(func(err error) {
+		errC <- err
+	})
+	hub.minBackoff = time.Millisecond
+	defer hub.Close()
+
+	events := make(chan *WsTradesEvent, 1)
+	sub, err := hub.SubscribeTrades([]string{"BTC/USD", "TXN"}, func(event *WsTradesEvent) {
+		events <- event
+	}, nil)
+	r := s.r()
+	r.NoError(err)
+	defer sub.Close()
+	close(mock.upstream(0).dropC)
+	r.ErrorIs(<-errC, ErrWsHubUpstreamClosed)
+	r.Eventually(func() bool {
+		return mock.count() == 2 && len(mock.upstream(1).received()) == 1
+	}, time.Second, time.Millisecond)
+	requests := mock.upstream(1).received()
+	r.Equal("trades.subscribe", requests[0].Destination)
+	r.Equal([]string{"BTC/USD", "TXN"}, requests[0].Payload["symbols"])
+	r.Equal(map[string]int{"BTC/USD": 1, "TXN": 1}, hub.Subscribers("trades"))
+
+	mock.upstream(1).handler([]byte(`{"status":"OK","destination":"internal.trade","payload":{"symbol":"TXN","price":1}}`))
+	r.Equal("TXN", (<-events).Symbol)
+}
+
+func (s *websocketServiceTestSuite) TestWsHubDialsWithoutLock() {
+	mock := s.mockWsHubServe()
+	mockServe := wsServe
+	dialC := make(chan struct{})
+	wsServe = func(cfg *WsConfig, requests chan WsRequest, handler WsHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
+		<-dialC
+		return mockServe(cfg, requests, handler, errHandler)
+	}
+	hub := NewWsHub(nil)
+	defer hub.Close()
+
+	subC := make(chan *WsHubSubscription)
+	go func() {
+		sub, _ := hub.SubscribeTrades([]string{"BTC/USD"}, func(event *WsTradesEvent) {}, nil)
+		subC <- sub
+	}()
+	r := s.r()
+	r.Eventually(func() bool {
+		return hub.Subscribers("trades")["BTC/USD"] == 1
+	}, time.Second, time.Millisecond)
+	close(dialC)
+	sub := <-subC
+	r.NotNil(sub)
+	sub.Close()
+	r.Equal(1, mock.count())
+}
+
+func (s *websocketServiceTestSuite) TestWsHubServeError() {
+	mock := s.mockWsHubServe()
+	mockServe := wsServe
+	fakeErr := errors.New("dial error")
+	fail := true
+	wsServe = func(cfg *WsConfig, requests chan WsRequest, handler WsHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
+		if fail {
+			fail = false
+			return nil, nil, fakeErr
+		}
+		return mockServe(cfg, requests, handler, errHandler)
+	}
+	hub := NewWsHub(nil)
+	defer hub.Close()
+
+	r := s.r()
+	_, err := hub.SubscribeOHLCMarketData([]string{"BTC/USD", "TXN"}, []string{"1m"}, func(event *WsOHLCMarketDataEvent) {}, nil)
+	r.ErrorIs(err, fakeErr)
+	r.Empty(hub.Subscribers("OHLCMarketData"))
+
+	sub, err := hub.SubscribeOHLCMarketData([]string{"TXN"}, []string{"1m"}, func(event *WsOHLCMarketDataEvent) {}, nil)
+	r.NoError(err)
+	defer sub.Close()
+	r.Equal(1, mock.count())
+}
+
+func (s *websocketServiceTestSuite) TestWsHubSubscribeRejected() {
+	mock := s.mockWsHubServe()
+	mock.status = "BAD_REQUEST"
+	hub := NewWsHub(nil)
+	defer hub.Close()
+
+	_, err := hub.SubscribeDepth([]string{"XXX"}, func(event *WsDepthEvent) {}, nil)
+	r := s.r()
+	wsErr := new(WsError)
+	r.True(errors.As(err, &wsErr))
+	r.Equal("depthMarketData.subscribe", wsErr.Destination)
+	r.Empty(hub.Subscribers("depthMarketData"))
+	<-mock.upstream(0).doneC
+}
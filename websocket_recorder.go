@@ -0,0 +1,245 @@
+package go_currencycom
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	stdjson "encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// maxWsRecordSize is the maximum length of a recorded line
+const maxWsRecordSize = 4 * 1024 * 1024
+
+// WsRecord define a raw websocket message with the time it was received
+type WsRecord struct {
+	Time    time.Time          `json:"time"`
+	Message stdjson.RawMessage `json:"message"`
+}
+
+// WsRecorder writes raw websocket messages to a gzip compressed JSONL stream
+type WsRecorder struct {
+	mu     sync.Mutex
+	gz     *gzip.Writer
+	closer io.Closer
+	err    error
+	now    func() time.Time
+}
+
+// NewWsRecorder init a recorder writing to w
+func NewWsRecorder(w io.Writer) *WsRecorder {
+	return &WsRecorder{
+		gz:  gzip.NewWriter(w),
+		now: time.Now,
+	}
+}
+
+// CreateWsRecorder init a recorder writing to a new file at path
+func CreateWsRecorder(path string) (*WsRecorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	r := NewWsRecorder(f)
+	r.closer = f
+	return r, nil
+}
+
+// WithWsRecorder record every message received by the connection
+func WithWsRecorder(r *WsRecorder) WsOption {
+	return func(c *WsConfig) {
+		c.Recorder = r
+	}
+}
+
+// Record write message with the current time
+func (r *WsRecorder) Record(message []byte) error {
+	// compact the message so that each record stays on a single line
+	compacted := new(bytes.Buffer)
+	if err := stdjson.Compact(compacted, message); err != nil {
+		return err
+	}
+	line, err := json.Marshal(&WsRecord{
+		Time:    r.now(),
+		Message: compacted.Bytes(),
+	})
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	if _, err = r.gz.Write(append(line, '\n')); err != nil {
+		r.err = err
+	}
+	return err
+}
+
+// Wrap returns a handler that records each message before passing it to handler.
+// Messages that cannot be recorded are still passed to handler and the error
+// is reported to errHandler; a write error is reported once since the
+// recorder stops writing after it.
+func (r *WsRecorder) Wrap(handler WsHandler, errHandler ErrHandler) WsHandler {
+	failed := false
+	return func(message []byte) {
+		if err := r.Record(message); err != nil && !failed {
+			failed = r.Err() != nil
+			errHandler(fmt.Errorf("record websocket message: %w", err))
+		}
+		handler(message)
+	}
+}
+
+// Err returns the first write error of the recorder
+func (r *WsRecorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Flush write the buffered records to the underlying writer
+func (r *WsRecorder) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	return r.gz.Flush()
+}
+
+// Close flush the records and close the file opened by CreateWsRecorder
+func (r *WsRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err := r.gz.Close()
+	if r.closer != nil {
+		if cerr := r.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// WsReplayer drives stream handlers with messages written by a WsRecorder
+type WsReplayer struct {
+	gz         *gzip.Reader
+	closer     io.Closer
+	speed      float64
+	router     *wsRouter
+	errHandler ErrHandler
+}
+
+// NewWsReplayer init a replayer reading from r
+func NewWsReplayer(r io.Reader) (*WsReplayer, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	p := &WsReplayer{
+		gz:    gz,
+		speed: 1,
+	}
+	p.router = newWsRouter(func(err error) {
+		if p.errHandler != nil {
+			p.errHandler(err)
+		}
+	})
+	return p, nil
+}
+
+// OpenWsReplayer init a replayer reading the file at path
+func OpenWsReplayer(path string) (*WsReplayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := NewWsReplayer(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	p.closer = f
+	return p, nil
+}
+
+// Speed set the replay speed: 1 replays at the original pace, 10 ten times
+// faster, and 0 as fast as possible.
+func (p *WsReplayer) Speed(speed float64) *WsReplayer {
+	p.speed = speed
+	return p
+}
+
+// MarketDataHandler set the handler of quotes
+func (p *WsReplayer) MarketDataHandler(handler WsMarketDataHandler) *WsReplayer {
+	p.router.onMarketData(handler)
+	return p
+}
+
+// OHLCMarketDataHandler set the handler of candles
+func (p *WsReplayer) OHLCMarketDataHandler(handler WsOHLCMarketDataHandler) *WsReplayer {
+	p.router.onOHLCMarketData(handler)
+	return p
+}
+
+// TradesHandler set the handler of trades
+func (p *WsReplayer) TradesHandler(handler WsTradesHandler) *WsReplayer {
+	p.router.onTrades(handler)
+	return p
+}
+
+// DepthHandler set the handler of order book updates
+func (p *WsReplayer) DepthHandler(handler WsDepthHandler) *WsReplayer {
+	p.router.onDepth(handler)
+	return p
+}
+
+// ErrHandler set the handler of the errors carried by the replayed messages
+func (p *WsReplayer) ErrHandler(errHandler ErrHandler) *WsReplayer {
+	p.errHandler = errHandler
+	return p
+}
+
+// Replay the records until the end of the stream or until ctx is done
+func (p *WsReplayer) Replay(ctx context.Context) error {
+	scanner := bufio.NewScanner(p.gz)
+	scanner.Buffer(make([]byte, 64*1024), maxWsRecordSize)
+	var first time.Time
+	start := time.Now()
+	for scanner.Scan() {
+		record := new(WsRecord)
+		if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+			return err
+		}
+		if first.IsZero() {
+			first = record.Time
+		}
+		if p.speed > 0 {
+			offset := time.Duration(float64(record.Time.Sub(first)) / p.speed)
+			if err := sleepContext(ctx, offset-time.Since(start)); err != nil {
+				return err
+			}
+		} else if err := ctx.Err(); err != nil {
+			return err
+		}
+		p.router.handle(record.Message)
+	}
+	return scanner.Err()
+}
+
+// Close the file opened by OpenWsReplayer
+func (p *WsReplayer) Close() error {
+	err := p.gz.Close()
+	if p.closer != nil {
+		if cerr := p.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,153 @@
+package go_currencycom
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type wsRecorderTestSuite struct {
+	suite.Suite
+}
+
+func TestWsRecorder(t *testing.T) {
+	suite.Run(t, new(wsRecorderTestSuite))
+}
+
+var recordedMessages = [][]byte{
+	[]byte(`{
+		"status":"OK",
+		"destination":"internal.quote",
+		"payload":{"symbolName":"TXN","bid":139.85,"ofr":139.92,"timestamp":1597850971558}
+	}`),
+	[]byte(`{"status":"OK","destination":"marketData.subscribe","payload":{}}`),
+	[]byte(`{"status":"OK","destination":"internal.trade","payload":{"price":11400.95,"size":0.058,"id":1616651347,"symbol":"BTC/USD"}}`),
+	[]byte(`{"status":"OK","destination":"ohlc.event","payload":{"symbol":"BTC/USD","interval":"1m","o":1,"h":2,"l":0.5,"c":1.5,"t":1673619780000}}`),
+	[]byte(`{"status":"ERROR","payload":{"errorCode":1,"errorMessage":"boom"}}`),
+}
+
+func (s *wsRecorderTestSuite) record(recorder *WsRecorder, step time.Duration) {
+	now := time.Date(2023, 1, 13, 12, 0, 0, 0, time.UTC)
+	recorder.now = func() time.Time {
+		now = now.Add(step)
+		return now
+	}
+	var passed int
+	handler := recorder.Wrap(func(message []byte) {
+		passed++
+	}, func(err error) {
+		s.Fail("unexpected error", err)
+	})
+	for _, message := range recordedMessages {
+		handler(message)
+	}
+	s.Require().Equal(len(recordedMessages), passed)
+	s.Require().NoError(recorder.Err())
+}
+
+func (s *wsRecorderTestSuite) TestRecordAndReplay() {
+	buf := new(bytes.Buffer)
+	recorder := NewWsRecorder(buf)
+	s.record(recorder, time.Second)
+	r := s.Require()
+	r.NoError(recorder.Close())
+
+	replayer, err := NewWsReplayer(buf)
+	r.NoError(err)
+	var quotes []*WsMarketDataEvent
+	var trades []*WsTradesEvent
+	var candles []*WsOHLCMarketDataEvent
+	var errs []error
+	err = replayer.Speed(0).
+		MarketDataHandler(func(event *WsMarketDataEvent) {
+			quotes = append(quotes, event)
+		}).
+		TradesHandler(func(event *WsTradesEvent) {
+			trades = append(trades, event)
+		}).
+		OHLCMarketDataHandler(func(event *WsOHLCMarketDataEvent) {
+			candles = append(candles, event)
+		}).
+		ErrHandler(func(err error) {
+			errs = append(errs, err)
+		}).
+		Replay(context.Background())
+	r.NoError(err)
+	r.NoError(replayer.Close())
+
+	r.Len(quotes, 1)
+	r.Equal("TXN", quotes[0].SymbolName)
+	r.Equal(139.85, quotes[0].Bid)
+	r.Len(trades, 1)
+	r.Equal(int64(1616651347), trades[0].ID)
+	r.Len(candles, 1)
+	r.Equal(1.5, candles[0].Close)
+	r.Len(errs, 1)
+	r.True(IsWsError(errs[0]))
+}
+
+func (s *wsRecorderTestSuite) TestReplayAccelerated() {
+	path := filepath.Join(s.T().TempDir(), "feed.jsonl.gz")
+	recorder, err := CreateWsRecorder(path)
+	r := s.Require()
+	r.NoError(err)
+	s.record(recorder, 100*time.Millisecond)
+	r.NoError(recorder.Close())
+
+	replayer, err := OpenWsReplayer(path)
+	r.NoError(err)
+	defer replayer.Close()
+	start := time.Now()
+	r.NoError(replayer.Speed(10).Replay(context.Background()))
+	// four gaps of 100ms replayed ten times faster
+	r.GreaterOrEqual(time.Since(start), 40*time.Millisecond)
+}
+
+func (s *wsRecorderTestSuite) TestReplayCanceled() {
+	buf := new(bytes.Buffer)
+	recorder := NewWsRecorder(buf)
+	s.record(recorder, time.Hour)
+	s.Require().NoError(recorder.Close())
+
+	replayer, err := NewWsReplayer(buf)
+	s.Require().NoError(err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	s.Require().ErrorIs(replayer.Replay(ctx), context.DeadlineExceeded)
+}
+
+type failingWriter struct{}
+
+func (w failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func (s *wsRecorderTestSuite) TestWrapReportsErrors() {
+	recorder := NewWsRecorder(failingWriter{})
+	var errs []error
+	var passed int
+	handler := recorder.Wrap(func(message []byte) {
+		passed++
+	}, func(err error) {
+		errs = append(errs, err)
+	})
+	handler([]byte(`not json`))
+	r := s.Require()
+	r.Equal(1, passed)
+	r.Len(errs, 1)
+	r.NoError(recorder.Err())
+
+	// the write error is reported once, the messages still pass through
+	for _, message := range recordedMessages {
+		handler(message)
+	}
+	r.Error(recorder.Err())
+	r.Equal(len(recordedMessages)+1, passed)
+	r.Len(errs, 2)
+	r.ErrorContains(errs[1], "disk full")
+}
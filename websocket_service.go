@@ -1,7 +1,10 @@
 package go_currencycom
 
 import (
+	stdjson "encoding/json"
 	"errors"
+	"sort"
+	"strconv"
 	"time"
 )
 
@@ -14,9 +17,17 @@ var (
 	WebsocketTimeout   = 30 * time.Second
 	WebsocketKeepAlive = true
 	CorrelationID      = -1
+	// WebsocketEndpoint overrides the endpoint of the streams if set,
+	// e.g. to connect them to a local relay
+	WebsocketEndpoint = ""
 )
 
+var errWsMissingSymbol = errors.New("missing symbol")
+
 func getWsEndpoint() string {
+	if WebsocketEndpoint != "" {
+		return WebsocketEndpoint
+	}
 	if UseDemo {
 		return baseWsDemoURL
 	}
@@ -34,39 +45,41 @@ type WsMarketDataEvent struct {
 
 type WsMarketDataHandler func(event *WsMarketDataEvent)
 
-func WsMarketDataServe(symbols []string, handler WsMarketDataHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
-	return wsMarketDataServe(getWsEndpoint(), symbols, handler, errHandler)
+func decodeWsMarketDataEvent(payload stdjson.RawMessage) (*WsMarketDataEvent, error) {
+	event := new(WsMarketDataEvent)
+	if err := json.Unmarshal(payload, event); err != nil {
+		return nil, err
+	}
+	if event.SymbolName == "" {
+		return nil, errWsMissingSymbol
+	}
+	return event, nil
 }
 
-func wsMarketDataServe(endpoint string, symbols []string, handler WsMarketDataHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
-	config := newWsConfig(endpoint)
-	requests := make(chan WsRequest)
-	wsHandler := func(message []byte) {
-		j, err := newJSON(message)
-		if err != nil {
-			errHandler(err)
-			return
-		}
-		status, err := j.Get("status").String()
+// onMarketData route quotes to handler
+func (r *wsRouter) onMarketData(handler WsMarketDataHandler) *wsRouter {
+	return r.on(WsDestinationQuote, func(payload stdjson.RawMessage) error {
+		event, err := decodeWsMarketDataEvent(payload)
 		if err != nil {
-			errHandler(err)
-			return
-		}
-		if status != "OK" {
-			errHandler(errors.New(status))
-			return
+			return err
 		}
-		j = j.Get("payload")
-		event := new(WsMarketDataEvent)
-		event.SymbolName = j.Get("symbolName").MustString()
-		event.Timestamp = j.Get("timestamp").MustInt64()
-		event.Bid = j.Get("bid").MustFloat64()
-		event.Ofr = j.Get("ofr").MustFloat64()
-		event.BidQty = j.Get("bidQty").MustFloat64()
-		event.OfrQty = j.Get("ofrQty").MustFloat64()
 		handler(event)
+		return nil
+	})
+}
+
+func WsMarketDataServe(symbols []string, handler WsMarketDataHandler, errHandler ErrHandler, opts ...WsOption) (doneC, stopC chan struct{}, err error) {
+	return wsMarketDataServe(getWsEndpoint(), symbols, handler, errHandler, opts...)
+}
+
+func wsMarketDataServe(endpoint string, symbols []string, handler WsMarketDataHandler, errHandler ErrHandler, opts ...WsOption) (doneC, stopC chan struct{}, err error) {
+	config := newWsConfig(endpoint, "marketData", opts...)
+	requests := make(chan WsRequest)
+	router := newWsRouter(errHandler).onMarketData(handler)
+	doneC, stopC, err = wsServe(config, requests, router.handle, errHandler)
+	if err != nil {
+		return nil, nil, err
 	}
-	doneC, stopC, err = wsServe(config, requests, wsHandler, errHandler)
 	requests <- *newWsRequest("marketData.subscribe", CorrelationID, payload{"symbols": symbols})
 	return doneC, stopC, err
 }
@@ -84,41 +97,42 @@ type WsOHLCMarketDataEvent struct {
 
 type WsOHLCMarketDataHandler func(event *WsOHLCMarketDataEvent)
 
-func WsOHLCMarketDataServe(symbols []string, intervals []string, handler WsOHLCMarketDataHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
-	return wsOHLCMarketDataServe(getWsEndpoint(), symbols, intervals, handler, errHandler)
+func decodeWsOHLCMarketDataEvent(payload stdjson.RawMessage) (*WsOHLCMarketDataEvent, error) {
+	event := new(WsOHLCMarketDataEvent)
+	if err := json.Unmarshal(payload, event); err != nil {
+		return nil, err
+	}
+	if event.Symbol == "" {
+		return nil, errWsMissingSymbol
+	}
+	return event, nil
 }
 
-func wsOHLCMarketDataServe(endpoint string, symbols []string, intervals []string, handler WsOHLCMarketDataHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
-	config := newWsConfig(endpoint)
-	requests := make(chan WsRequest)
-	wsHandler := func(message []byte) {
-		j, err := newJSON(message)
+// onOHLCMarketData route candles to handler
+func (r *wsRouter) onOHLCMarketData(handler WsOHLCMarketDataHandler) *wsRouter {
+	f := func(payload stdjson.RawMessage) error {
+		event, err := decodeWsOHLCMarketDataEvent(payload)
 		if err != nil {
-			errHandler(err)
-			return
+			return err
 		}
-		status, err := j.Get("status").String()
-		if err != nil {
-			errHandler(err)
-			return
-		}
-		if status != "OK" {
-			errHandler(errors.New(status))
-			return
-		}
-		j = j.Get("payload")
-		event := new(WsOHLCMarketDataEvent)
-		event.Symbol = j.Get("symbol").MustString()
-		event.Type = j.Get("type").MustString()
-		event.Interval = j.Get("interval").MustString()
-		event.Timestamp = j.Get("t").MustInt64()
-		event.Open = j.Get("o").MustFloat64()
-		event.High = j.Get("h").MustFloat64()
-		event.Low = j.Get("l").MustFloat64()
-		event.Close = j.Get("c").MustFloat64()
 		handler(event)
+		return nil
+	}
+	return r.on(WsDestinationCandle, f).on(WsDestinationOHLC, f)
+}
+
+func WsOHLCMarketDataServe(symbols []string, intervals []string, handler WsOHLCMarketDataHandler, errHandler ErrHandler, opts ...WsOption) (doneC, stopC chan struct{}, err error) {
+	return wsOHLCMarketDataServe(getWsEndpoint(), symbols, intervals, handler, errHandler, opts...)
+}
+
+func wsOHLCMarketDataServe(endpoint string, symbols []string, intervals []string, handler WsOHLCMarketDataHandler, errHandler ErrHandler, opts ...WsOption) (doneC, stopC chan struct{}, err error) {
+	config := newWsConfig(endpoint, "OHLCMarketData", opts...)
+	requests := make(chan WsRequest)
+	router := newWsRouter(errHandler).onOHLCMarketData(handler)
+	doneC, stopC, err = wsServe(config, requests, router.handle, errHandler)
+	if err != nil {
+		return nil, nil, err
 	}
-	doneC, stopC, err = wsServe(config, requests, wsHandler, errHandler)
 	requests <- *newWsRequest("OHLCMarketData.subscribe", CorrelationID, payload{"symbols": symbols, "intervals": intervals})
 	return doneC, stopC, err
 }
@@ -136,41 +150,139 @@ type WsTradesEvent struct {
 
 type WsTradesHandler func(event *WsTradesEvent)
 
-func WsTradesServe(symbols []string, handler WsTradesHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
-	return wsTradesServe(getWsEndpoint(), symbols, handler, errHandler)
+func decodeWsTradesEvent(payload stdjson.RawMessage) (*WsTradesEvent, error) {
+	event := new(WsTradesEvent)
+	if err := json.Unmarshal(payload, event); err != nil {
+		return nil, err
+	}
+	if event.Symbol == "" {
+		return nil, errWsMissingSymbol
+	}
+	return event, nil
 }
 
-func wsTradesServe(endpoint string, symbols []string, handler WsTradesHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
-	config := newWsConfig(endpoint)
-	requests := make(chan WsRequest)
-	wsHandler := func(message []byte) {
-		j, err := newJSON(message)
+// onTrades route trades to handler
+func (r *wsRouter) onTrades(handler WsTradesHandler) *wsRouter {
+	return r.on(WsDestinationTrade, func(payload stdjson.RawMessage) error {
+		event, err := decodeWsTradesEvent(payload)
 		if err != nil {
-			errHandler(err)
-			return
+			return err
 		}
-		status, err := j.Get("status").String()
+		handler(event)
+		return nil
+	})
+}
+
+func WsTradesServe(symbols []string, handler WsTradesHandler, errHandler ErrHandler, opts ...WsOption) (doneC, stopC chan struct{}, err error) {
+	return wsTradesServe(getWsEndpoint(), symbols, handler, errHandler, opts...)
+}
+
+func wsTradesServe(endpoint string, symbols []string, handler WsTradesHandler, errHandler ErrHandler, opts ...WsOption) (doneC, stopC chan struct{}, err error) {
+	config := newWsConfig(endpoint, "trades", opts...)
+	requests := make(chan WsRequest)
+	router := newWsRouter(errHandler).onTrades(handler)
+	doneC, stopC, err = wsServe(config, requests, router.handle, errHandler)
+	if err != nil {
+		return nil, nil, err
+	}
+	requests <- *newWsRequest("trades.subscribe", CorrelationID, payload{"symbols": symbols})
+	return doneC, stopC, err
+}
+
+type WsDepthEvent struct {
+	Symbol    string
+	Timestamp int64
+	// Bids are sorted by descending price, Asks by ascending price
+	Bids PriceLevelList
+	Asks PriceLevelList
+}
+
+type WsDepthHandler func(event *WsDepthEvent)
+
+type wsDepthData struct {
+	Timestamp int64              `json:"ts"`
+	Bid       map[string]float64 `json:"bid"`
+	Ofr       map[string]float64 `json:"ofr"`
+}
+
+func newPriceLevelList(levels map[string]float64) (PriceLevelList, error) {
+	res := make(PriceLevelList, 0, len(levels))
+	for price, quantity := range levels {
+		p, err := strconv.ParseFloat(price, 64)
 		if err != nil {
-			errHandler(err)
-			return
+			return nil, err
 		}
-		if status != "OK" {
-			errHandler(errors.New(status))
-			return
+		res = append(res, PriceLevel{Price: p, Quantity: quantity})
+	}
+	sort.Sort(res)
+	return res, nil
+}
+
+func decodeWsDepthEvent(payload stdjson.RawMessage) (*WsDepthEvent, error) {
+	p := new(struct {
+		Symbol string             `json:"symbol"`
+		Data   stdjson.RawMessage `json:"data"`
+	})
+	if err := json.Unmarshal(payload, p); err != nil {
+		return nil, err
+	}
+	if p.Symbol == "" {
+		return nil, errWsMissingSymbol
+	}
+	// the depth data may be sent as an encoded JSON string
+	data := []byte(p.Data)
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, err
+		}
+		data = []byte(s)
+	}
+	d := new(wsDepthData)
+	if err := json.Unmarshal(data, d); err != nil {
+		return nil, err
+	}
+	bids, err := newPriceLevelList(d.Bid)
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(sort.Reverse(bids))
+	asks, err := newPriceLevelList(d.Ofr)
+	if err != nil {
+		return nil, err
+	}
+	return &WsDepthEvent{
+		Symbol:    p.Symbol,
+		Timestamp: d.Timestamp,
+		Bids:      bids,
+		Asks:      asks,
+	}, nil
+}
+
+// onDepth route order book updates to handler
+func (r *wsRouter) onDepth(handler WsDepthHandler) *wsRouter {
+	return r.on(WsDestinationDepth, func(payload stdjson.RawMessage) error {
+		event, err := decodeWsDepthEvent(payload)
+		if err != nil {
+			return err
 		}
-		j = j.Get("payload")
-		event := new(WsTradesEvent)
-		event.Symbol = j.Get("symbol").MustString()
-		event.ID = j.Get("id").MustInt64()
-		event.Timestamp = j.Get("ts").MustInt64()
-		event.Price = j.Get("price").MustFloat64()
-		event.Size = j.Get("size").MustFloat64()
-		event.OrderID = j.Get("orderId").MustString()
-		event.ClientOrderID = j.Get("clientOrderId").MustString()
-		event.Buyer = j.Get("buyer").MustBool()
 		handler(event)
+		return nil
+	})
+}
+
+func WsDepthServe(symbols []string, handler WsDepthHandler, errHandler ErrHandler, opts ...WsOption) (doneC, stopC chan struct{}, err error) {
+	return wsDepthServe(getWsEndpoint(), symbols, handler, errHandler, opts...)
+}
+
+func wsDepthServe(endpoint string, symbols []string, handler WsDepthHandler, errHandler ErrHandler, opts ...WsOption) (doneC, stopC chan struct{}, err error) {
+	config := newWsConfig(endpoint, "depthMarketData", opts...)
+	requests := make(chan WsRequest)
+	router := newWsRouter(errHandler).onDepth(handler)
+	doneC, stopC, err = wsServe(config, requests, router.handle, errHandler)
+	if err != nil {
+		return nil, nil, err
 	}
-	doneC, stopC, err = wsServe(config, requests, wsHandler, errHandler)
-	requests <- *newWsRequest("trades.subscribe", CorrelationID, payload{"symbols": symbols})
+	requests <- *newWsRequest("depthMarketData.subscribe", CorrelationID, payload{"symbols": symbols})
 	return doneC, stopC, err
 }
@@ -197,3 +197,110 @@ func (s *websocketServiceTestSuite) assertWsTradeEventEqual(e, a *WsTradesEvent)
 	r.Equal(e.ClientOrderID, a.ClientOrderID, "ClientOrderID")
 	r.Equal(e.Buyer, a.Buyer, "Buyer")
 }
+
+func (s *websocketServiceTestSuite) TestWsMarketDataServeError() {
+	data := []byte(`{
+		"status":"BAD_REQUEST",
+		"destination":"marketData.subscribe",
+		"correlationId":"1",
+		"payload":{
+			"errorCode":-1128,
+			"errorMessage":"Invalid symbols"
+		}}`)
+	s.mockWsServe(data, nil)
+	defer s.assertWsServe()
+
+	var wsErr error
+	doneC, stopC, err := WsMarketDataServe([]string{"UNKNOWN"}, func(event *WsMarketDataEvent) {
+		s.Fail("unexpected event")
+	}, func(err error) {
+		wsErr = err
+	})
+	r := s.r()
+	r.NoError(err)
+	close(stopC)
+	<-doneC
+	r.True(IsWsError(wsErr))
+	r.Equal(&WsError{
+		Status:        "BAD_REQUEST",
+		Destination:   "marketData.subscribe",
+		CorrelationID: "1",
+		Code:          -1128,
+		Message:       "Invalid symbols",
+	}, wsErr)
+}
+
+func (s *websocketServiceTestSuite) TestWsTradesServeInvalidPayload() {
+	data := []byte(`{
+		"status":"OK",
+		"destination":"internal.trade",
+		"payload":{
+			"price":"11400.95",
+			"symbol":"BTC/USD"
+		}}`)
+	s.mockWsServe(data, nil)
+	defer s.assertWsServe()
+
+	var wsErr error
+	doneC, stopC, err := WsTradesServe([]string{"BTC/USD"}, func(event *WsTradesEvent) {
+		s.Fail("unexpected event")
+	}, func(err error) {
+		wsErr = err
+	})
+	r := s.r()
+	r.NoError(err)
+	close(stopC)
+	<-doneC
+	r.Error(wsErr)
+	r.Contains(wsErr.Error(), "invalid internal.trade payload")
+}
+
+func (s *websocketServiceTestSuite) TestWsMarketDataServeIgnoresOtherDestinations() {
+	data := []byte(`{
+		"status":"OK",
+		"destination":"marketData.subscribe",
+		"correlationId":1,
+		"payload":{
+			"subscriptions":{"TXN":"OK"}
+		}}`)
+	s.mockWsServe(data, nil)
+	defer s.assertWsServe()
+
+	doneC, stopC, err := WsMarketDataServe([]string{"TXN"}, func(event *WsMarketDataEvent) {
+		s.Fail("unexpected event")
+	}, func(err error) {
+		s.Fail("unexpected error", err)
+	})
+	s.r().NoError(err)
+	close(stopC)
+	<-doneC
+}
+
+func (s *websocketServiceTestSuite) TestWsDepthServe() {
+	data := []byte(`{
+		"status":"OK",
+		"destination":"marketdepth.event",
+		"payload":{
+			"data":"{\"ts\":1597850971558,\"bid\":{\"139.85\":2500,\"139.8\":1000},\"ofr\":{\"139.95\":500,\"139.92\":2500}}",
+			"symbol":"TXN"
+		}}`)
+	s.mockWsServe(data, nil)
+	defer s.assertWsServe()
+
+	var event *WsDepthEvent
+	doneC, stopC, err := WsDepthServe([]string{"TXN"}, func(e *WsDepthEvent) {
+		event = e
+	}, func(err error) {
+		s.Fail("unexpected error", err)
+	})
+	r := s.r()
+	r.NoError(err)
+	close(stopC)
+	<-doneC
+	r.Equal(&WsDepthEvent{
+		Symbol:    "TXN",
+		Timestamp: 1597850971558,
+		Bids:      PriceLevelList{{139.85, 2500}, {139.8, 1000}},
+		Asks:      PriceLevelList{{139.92, 2500}, {139.95, 500}},
+	}, event)
+}
@@ -0,0 +1,247 @@
+package go_currencycom
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// WsStaleEvent is emitted when a symbol stops updating or recovers
+type WsStaleEvent struct {
+	Symbol     string
+	Stale      bool
+	LastUpdate time.Time
+	// Silence is the time elapsed since LastUpdate when the symbol went
+	// stale, or the length of the gap when it recovered.
+	Silence time.Duration
+}
+
+type WsStaleHandler func(event *WsStaleEvent)
+
+// WsStaleMonitorConfig define the staleness thresholds of a WsStaleMonitor
+type WsStaleMonitorConfig struct {
+	// Threshold is the default time without updates after which a symbol is stale
+	Threshold time.Duration
+	// Thresholds overrides Threshold per symbol
+	Thresholds map[string]time.Duration
+	// CheckInterval is the period of the staleness check, Threshold/4 if not
+	// set, and at least a millisecond
+	CheckInterval time.Duration
+	// MarketOpen reports whether the market of a symbol is open at t.
+	// Symbols are never stale while their market is closed.
+	MarketOpen func(symbol string, t time.Time) bool
+}
+
+const minWsStaleCheckInterval = time.Millisecond
+
+type wsStaleState struct {
+	last  time.Time
+	stale bool
+}
+
+// WsStaleMonitor tracks the time of the last update of each symbol and
+// reports symbols that go silent while the connection itself stays alive.
+type WsStaleMonitor struct {
+	cfg     WsStaleMonitorConfig
+	handler WsStaleHandler
+	now     func() time.Time
+
+	mu      sync.Mutex
+	symbols map[string]*wsStaleState
+
+	stopC chan struct{}
+	wg    sync.WaitGroup
+}
+
+// NewWsStaleMonitor init a stale monitor, call Start to run the periodic check
+func NewWsStaleMonitor(cfg WsStaleMonitorConfig, handler WsStaleHandler) *WsStaleMonitor {
+	return &WsStaleMonitor{
+		cfg:     cfg,
+		handler: handler,
+		now:     time.Now,
+		symbols: make(map[string]*wsStaleState),
+	}
+}
+
+// Watch start tracking symbols, even if they never receive an update
+func (m *WsStaleMonitor) Watch(symbols ...string) {
+	now := m.now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, symbol := range symbols {
+		if _, ok := m.symbols[symbol]; !ok {
+			m.symbols[symbol] = &wsStaleState{last: now}
+		}
+	}
+}
+
+// Touch record an update of symbol
+func (m *WsStaleMonitor) Touch(symbol string) {
+	now := m.now()
+	m.mu.Lock()
+	state, ok := m.symbols[symbol]
+	if !ok {
+		state = &wsStaleState{}
+		m.symbols[symbol] = state
+	}
+	var event *WsStaleEvent
+	if state.stale {
+		event = &WsStaleEvent{
+			Symbol:     symbol,
+			LastUpdate: state.last,
+			Silence:    now.Sub(state.last),
+		}
+	}
+	state.last = now
+	state.stale = false
+	m.mu.Unlock()
+
+	if event != nil && m.handler != nil {
+		m.handler(event)
+	}
+}
+
+// MarketDataHandler wrap handler to record the updates of each symbol
+func (m *WsStaleMonitor) MarketDataHandler(handler WsMarketDataHandler) WsMarketDataHandler {
+	return func(event *WsMarketDataEvent) {
+		m.Touch(event.SymbolName)
+		handler(event)
+	}
+}
+
+// OHLCMarketDataHandler wrap handler to record the updates of each symbol
+func (m *WsStaleMonitor) OHLCMarketDataHandler(handler WsOHLCMarketDataHandler) WsOHLCMarketDataHandler {
+	return func(event *WsOHLCMarketDataEvent) {
+		m.Touch(event.Symbol)
+		handler(event)
+	}
+}
+
+// TradesHandler wrap handler to record the updates of each symbol
+func (m *WsStaleMonitor) TradesHandler(handler WsTradesHandler) WsTradesHandler {
+	return func(event *WsTradesEvent) {
+		m.Touch(event.Symbol)
+		handler(event)
+	}
+}
+
+// Stale returns the symbols that are currently stale
+func (m *WsStaleMonitor) Stale() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	res := make([]string, 0)
+	for symbol, state := range m.symbols {
+		if state.stale {
+			res = append(res, symbol)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+func (m *WsStaleMonitor) threshold(symbol string) time.Duration {
+	if d, ok := m.cfg.Thresholds[symbol]; ok {
+		return d
+	}
+	return m.cfg.Threshold
+}
+
+// Check compare the last update of every symbol with its threshold
+func (m *WsStaleMonitor) Check() {
+	now := m.now()
+	m.mu.Lock()
+	events := make([]*WsStaleEvent, 0)
+	for symbol, state := range m.symbols {
+		if m.cfg.MarketOpen != nil && !m.cfg.MarketOpen(symbol, now) {
+			// a closed market is not stale, and the silence is counted
+			// from the moment it opens again
+			if state.stale {
+				state.stale = false
+				events = append(events, &WsStaleEvent{
+					Symbol:     symbol,
+					LastUpdate: state.last,
+					Silence:    now.Sub(state.last),
+				})
+			}
+			state.last = now
+			continue
+		}
+		if state.stale {
+			continue
+		}
+		threshold := m.threshold(symbol)
+		if threshold <= 0 {
+			continue
+		}
+		if silence := now.Sub(state.last); silence > threshold {
+			state.stale = true
+			events = append(events, &WsStaleEvent{
+				Symbol:     symbol,
+				Stale:      true,
+				LastUpdate: state.last,
+				Silence:    silence,
+			})
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Symbol < events[j].Symbol
+	})
+	if m.handler != nil {
+		for _, event := range events {
+			m.handler(event)
+		}
+	}
+}
+
+func (m *WsStaleMonitor) checkInterval() time.Duration {
+	if m.cfg.CheckInterval >= minWsStaleCheckInterval {
+		return m.cfg.CheckInterval
+	}
+	if m.cfg.CheckInterval > 0 {
+		return minWsStaleCheckInterval
+	}
+	interval := m.cfg.Threshold
+	for _, d := range m.cfg.Thresholds {
+		if interval <= 0 || (d > 0 && d < interval) {
+			interval = d
+		}
+	}
+	if interval <= 0 {
+		return time.Second
+	}
+	if interval/4 < minWsStaleCheckInterval {
+		return minWsStaleCheckInterval
+	}
+	return interval / 4
+}
+
+// Start run Check periodically until Stop is called
+func (m *WsStaleMonitor) Start() {
+	m.stopC = make(chan struct{})
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(m.checkInterval())
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.Check()
+			case <-m.stopC:
+				return
+			}
+		}
+	}()
+}
+
+// Stop the periodic check
+func (m *WsStaleMonitor) Stop() {
+	if m.stopC == nil {
+		return
+	}
+	close(m.stopC)
+	m.wg.Wait()
+	m.stopC = nil
+}
@@ -0,0 +1,136 @@
+package go_currencycom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type wsStaleMonitorTestSuite struct {
+	suite.Suite
+	now    time.Time
+	events []*WsStaleEvent
+	open   bool
+}
+
+func TestWsStaleMonitor(t *testing.T) {
+	suite.Run(t, new(wsStaleMonitorTestSuite))
+}
+
+func (s *wsStaleMonitorTestSuite) SetupTest() {
+	s.now = time.Date(2023, 1, 13, 12, 0, 0, 0, time.UTC)
+	s.events = nil
+	s.open = true
+}
+
+func (s *wsStaleMonitorTestSuite) newMonitor(cfg WsStaleMonitorConfig) *WsStaleMonitor {
+	m := NewWsStaleMonitor(cfg, func(event *WsStaleEvent) {
+		s.events = append(s.events, event)
+	})
+	m.now = func() time.Time {
+		return s.now
+	}
+	return m
+}
+
+func (s *wsStaleMonitorTestSuite) TestStaleAndRecover() {
+	m := s.newMonitor(WsStaleMonitorConfig{
+		Threshold:  10 * time.Second,
+		Thresholds: map[string]time.Duration{"TXN": time.Minute},
+	})
+	m.Watch("BTC/USD", "TXN")
+	handler := m.MarketDataHandler(func(event *WsMarketDataEvent) {})
+
+	s.now = s.now.Add(5 * time.Second)
+	handler(&WsMarketDataEvent{SymbolName: "BTC/USD"})
+	s.now = s.now.Add(5 * time.Second)
+	m.Check()
+	s.Require().Empty(s.events)
+
+	s.now = s.now.Add(6 * time.Second)
+	m.Check()
+	r := s.Require()
+	r.Len(s.events, 1)
+	r.Equal("BTC/USD", s.events[0].Symbol)
+	r.True(s.events[0].Stale)
+	r.Equal(11*time.Second, s.events[0].Silence)
+	r.Equal([]string{"BTC/USD"}, m.Stale())
+
+	// already stale symbols are reported once
+	m.Check()
+	r.Len(s.events, 1)
+
+	s.now = s.now.Add(4 * time.Second)
+	handler(&WsMarketDataEvent{SymbolName: "BTC/USD"})
+	r.Len(s.events, 2)
+	r.False(s.events[1].Stale)
+	r.Equal(15*time.Second, s.events[1].Silence)
+	r.Empty(m.Stale())
+}
+
+func (s *wsStaleMonitorTestSuite) TestMarketClosed() {
+	m := s.newMonitor(WsStaleMonitorConfig{
+		Threshold: 10 * time.Second,
+		MarketOpen: func(symbol string, t time.Time) bool {
+			return s.open
+		},
+	})
+	m.Watch("TXN")
+	s.open = false
+	s.now = s.now.Add(time.Hour)
+	m.Check()
+	s.Require().Empty(s.events)
+
+	s.open = true
+	s.now = s.now.Add(5 * time.Second)
+	m.Check()
+	s.Require().Empty(s.events)
+	s.now = s.now.Add(6 * time.Second)
+	m.Check()
+	s.Require().Len(s.events, 1)
+	s.Require().Equal(11*time.Second, s.events[0].Silence)
+}
+
+func (s *wsStaleMonitorTestSuite) TestStaleUntilMarketCloses() {
+	m := s.newMonitor(WsStaleMonitorConfig{
+		Threshold: 10 * time.Second,
+		MarketOpen: func(symbol string, t time.Time) bool {
+			return s.open
+		},
+	})
+	m.Watch("TXN")
+	s.now = s.now.Add(11 * time.Second)
+	m.Check()
+	r := s.Require()
+	r.Equal([]string{"TXN"}, m.Stale())
+
+	s.open = false
+	s.now = s.now.Add(time.Hour)
+	m.Check()
+	r.Empty(m.Stale())
+	r.Len(s.events, 2)
+	r.False(s.events[1].Stale)
+
+	// the market reopens without tick, the silence is counted from then
+	s.open = true
+	s.now = s.now.Add(5 * time.Second)
+	m.Check()
+	r.Empty(m.Stale())
+	s.now = s.now.Add(6 * time.Second)
+	m.Check()
+	r.Equal([]string{"TXN"}, m.Stale())
+	r.Equal(11*time.Second, s.events[2].Silence)
+}
+
+func (s *wsStaleMonitorTestSuite) TestCheckInterval() {
+	r := s.Require()
+	r.Equal(time.Millisecond, s.newMonitor(WsStaleMonitorConfig{Threshold: 3}).checkInterval())
+	r.Equal(time.Millisecond, s.newMonitor(WsStaleMonitorConfig{CheckInterval: 1}).checkInterval())
+	r.Equal(time.Second, s.newMonitor(WsStaleMonitorConfig{}).checkInterval())
+	r.Equal(5*time.Second, s.newMonitor(WsStaleMonitorConfig{Threshold: 20 * time.Second}).checkInterval())
+
+	m := s.newMonitor(WsStaleMonitorConfig{Threshold: 3})
+	m.Start()
+	m.Stop()
+}
@@ -0,0 +1,154 @@
+package go_currencycom
+
+import (
+	"context"
+	"sync"
+)
+
+// WebsocketStreamBufferSize is the capacity of the event channel returned by
+// the context based streams.
+var WebsocketStreamBufferSize = 128
+
+// WsStream is a websocket subscription bound to a context.
+// Events are delivered on C, non-fatal errors on Errors. Both channels are
+// closed once the stream terminates, after which Err reports the reason.
+type WsStream[T any] struct {
+	events chan T
+	errC   chan error
+	done   chan struct{}
+
+	mu     sync.Mutex
+	closed bool
+	last   error
+	err    error
+}
+
+func newWsStream[T any]() *WsStream[T] {
+	return &WsStream[T]{
+		events: make(chan T, WebsocketStreamBufferSize),
+		errC:   make(chan error, WebsocketStreamBufferSize),
+		done:   make(chan struct{}),
+	}
+}
+
+// C returns the channel of events
+func (s *WsStream[T]) C() <-chan T {
+	return s.events
+}
+
+// Errors returns the channel of errors reported while the stream is running.
+// Errors are dropped if the channel is not drained.
+func (s *WsStream[T]) Errors() <-chan error {
+	return s.errC
+}
+
+// Done returns a channel that is closed when the stream terminates
+func (s *WsStream[T]) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns the error that terminated the stream.
+// It returns nil while the stream is running, the context error if the
+// context was canceled, or the last error reported by the connection.
+func (s *WsStream[T]) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *WsStream[T]) send(ctx context.Context, event T) {
+	select {
+	case s.events <- event:
+	case <-ctx.Done():
+	}
+}
+
+func (s *WsStream[T]) handleErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.last = err
+	select {
+	case s.errC <- err:
+	default:
+	}
+}
+
+func (s *WsStream[T]) run(ctx context.Context, doneC, stopC chan struct{}) {
+	go func() {
+		select {
+		case <-ctx.Done():
+			close(stopC)
+			<-doneC
+		case <-doneC:
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.closed = true
+		s.err = ctx.Err()
+		if s.err == nil {
+			s.err = s.last
+		}
+		close(s.events)
+		close(s.errC)
+		close(s.done)
+	}()
+}
+
+// WsMarketDataStream subscribes to market data of the symbols until ctx is done
+func WsMarketDataStream(ctx context.Context, symbols []string, opts ...WsOption) (*WsStream[*WsMarketDataEvent], error) {
+	s := newWsStream[*WsMarketDataEvent]()
+	handler := func(event *WsMarketDataEvent) {
+		s.send(ctx, event)
+	}
+	doneC, stopC, err := WsMarketDataServe(symbols, handler, s.handleErr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	s.run(ctx, doneC, stopC)
+	return s, nil
+}
+
+// WsOHLCMarketDataStream subscribes to candles of the symbols until ctx is done
+func WsOHLCMarketDataStream(ctx context.Context, symbols []string, intervals []string, opts ...WsOption) (*WsStream[*WsOHLCMarketDataEvent], error) {
+	s := newWsStream[*WsOHLCMarketDataEvent]()
+	handler := func(event *WsOHLCMarketDataEvent) {
+		s.send(ctx, event)
+	}
+	doneC, stopC, err := WsOHLCMarketDataServe(symbols, intervals, handler, s.handleErr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	s.run(ctx, doneC, stopC)
+	return s, nil
+}
+
+// WsTradesStream subscribes to trades of the symbols until ctx is done
+func WsTradesStream(ctx context.Context, symbols []string, opts ...WsOption) (*WsStream[*WsTradesEvent], error) {
+	s := newWsStream[*WsTradesEvent]()
+	handler := func(event *WsTradesEvent) {
+		s.send(ctx, event)
+	}
+	doneC, stopC, err := WsTradesServe(symbols, handler, s.handleErr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	s.run(ctx, doneC, stopC)
+	return s, nil
+}
+
+// WsDepthStream subscribes to order book updates of the symbols until ctx is done
+func WsDepthStream(ctx context.Context, symbols []string, opts ...WsOption) (*WsStream[*WsDepthEvent], error) {
+	s := newWsStream[*WsDepthEvent]()
+	handler := func(event *WsDepthEvent) {
+		s.send(ctx, event)
+	}
+	doneC, stopC, err := WsDepthServe(symbols, handler, s.handleErr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	s.run(ctx, doneC, stopC)
+	return s, nil
+}
@@ -0,0 +1,61 @@
+package go_currencycom
+
+import (
+	"context"
+	"errors"
+)
+
+func (s *websocketServiceTestSuite) TestWsMarketDataStream() {
+	data := []byte(`{
+		"status":"OK",
+		"destination":"internal.quote",
+		"payload":{
+			"symbolName":"TXN",
+			"bid":139.85,
+			"bidQty":2500,
+			"ofr":139.92000000000002,
+			"ofrQty":2500,
+			"timestamp":1597850971558
+		}}`)
+	fakeErrMsg := "fake error"
+	s.mockWsServe(data, errors.New(fakeErrMsg))
+	defer s.assertWsServe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := WsMarketDataStream(ctx, []string{"TXN"})
+	r := s.r()
+	r.NoError(err)
+
+	event := <-stream.C()
+	s.assertWsMarketDataEventEqual(&WsMarketDataEvent{
+		SymbolName: "TXN",
+		Bid:        139.85,
+		Ofr:        139.92000000000002,
+		BidQty:     2500,
+		OfrQty:     2500,
+		Timestamp:  1597850971558,
+	}, event)
+	r.EqualError(<-stream.Errors(), fakeErrMsg)
+	r.NoError(stream.Err())
+
+	cancel()
+	<-stream.Done()
+	r.ErrorIs(stream.Err(), context.Canceled)
+	_, ok := <-stream.C()
+	r.False(ok)
+	_, ok = <-stream.Errors()
+	r.False(ok)
+}
+
+func (s *websocketServiceTestSuite) TestWsTradesStreamServeError() {
+	fakeErr := errors.New("dial error")
+	wsServe = func(cfg *WsConfig, requests chan WsRequest, handler WsHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
+		s.serveCount++
+		return nil, nil, fakeErr
+	}
+	defer s.assertWsServe()
+
+	stream, err := WsTradesStream(context.Background(), []string{"BTC/USD"})
+	s.r().ErrorIs(err, fakeErr)
+	s.r().Nil(stream)
+}
@@ -0,0 +1,219 @@
+package go_currencycom
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/suite"
+)
+
+type wsTransportTestSuite struct {
+	suite.Suite
+	server  *httptest.Server
+	headers chan http.Header
+	reply   []byte
+}
+
+func TestWsTransport(t *testing.T) {
+	suite.Run(t, new(wsTransportTestSuite))
+}
+
+func (s *wsTransportTestSuite) SetupTest() {
+	s.headers = make(chan http.Header, 1)
+	s.reply = []byte(`{"status":"OK","destination":"internal.quote","payload":{"symbolName":"TXN","bid":139.85}}`)
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.headers <- r.Header
+		upgrader := websocket.Upgrader{}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+		if err := conn.WriteMessage(websocket.TextMessage, s.reply); err != nil {
+			return
+		}
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+}
+
+func (s *wsTransportTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *wsTransportTestSuite) endpoint() string {
+	return "ws" + strings.TrimPrefix(s.server.URL, "http")
+}
+
+func (s *wsTransportTestSuite) TestHeaderAndNetDial() {
+	var dials int32
+	netDial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		atomic.AddInt32(&dials, 1)
+		return (&net.Dialer{}).DialContext(ctx, network, addr)
+	}
+	events := make(chan *WsMarketDataEvent, 1)
+	doneC, stopC, err := WsMarketDataServe([]string{"TXN"}, func(event *WsMarketDataEvent) {
+		events <- event
+	}, func(err error) {},
+		WithWsEndpoint(s.endpoint()),
+		WithWsNetDial(netDial),
+		WithWsHeader(http.Header{"X-Client": []string{"test"}}),
+		WithWsProxy(nil),
+		WithWsWriteTimeout(time.Second),
+	)
+	r := s.Require()
+	r.NoError(err)
+	defer func() {
+		close(stopC)
+		<-doneC
+	}()
+	r.Equal("test", (<-s.headers).Get("X-Client"))
+	r.Equal(int32(1), atomic.LoadInt32(&dials))
+	select {
+	case event := <-events:
+		r.Equal("TXN", event.SymbolName)
+	case <-time.After(time.Second):
+		s.Fail("no event received")
+	}
+}
+
+func (s *wsTransportTestSuite) TestReadLimit() {
+	errs := make(chan error, 1)
+	doneC, _, err := WsMarketDataServe([]string{"TXN"}, func(event *WsMarketDataEvent) {
+		s.Fail("message above the read limit was handled")
+	}, func(err error) {
+		errs <- err
+	},
+		WithWsEndpoint(s.endpoint()),
+		WithWsReadLimit(16),
+	)
+	s.Require().NoError(err)
+	<-doneC
+	s.Require().ErrorIs(<-errs, websocket.ErrReadLimit)
+}
+
+func (s *wsTransportTestSuite) TestDialer() {
+	// the server accepts the connection but never completes the handshake
+	releaseC := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-releaseC
+	}))
+	defer server.Close()
+	defer close(releaseC)
+
+	dialer := &websocket.Dialer{HandshakeTimeout: 10 * time.Millisecond}
+	_, _, err := WsMarketDataServe([]string{"TXN"}, func(event *WsMarketDataEvent) {}, func(err error) {},
+		WithWsEndpoint("ws"+strings.TrimPrefix(server.URL, "http")+"/connect"),
+		WithWsDialer(dialer),
+	)
+	r := s.Require()
+	r.Error(err)
+	netErr, ok := err.(net.Error)
+	r.True(ok)
+	r.True(netErr.Timeout())
+}
+
+type wsHeartbeatTestSuite struct {
+	suite.Suite
+	server *httptest.Server
+	// replies is set when the server answers pings
+	replies atomic.Bool
+}
+
+func TestWsHeartbeat(t *testing.T) {
+	suite.Run(t, new(wsHeartbeatTestSuite))
+}
+
+func (s *wsHeartbeatTestSuite) SetupTest() {
+	s.replies.Store(true)
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var request WsRequest
+			if err := json.Unmarshal(message, &request); err != nil || request.Destination != wsPingDestination || !s.replies.Load() {
+				continue
+			}
+			reply := fmt.Sprintf(`{"status":"OK","destination":"ping","correlationId":%d,"payload":{}}`, request.CorrelationID)
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(reply)); err != nil {
+				return
+			}
+		}
+	}))
+}
+
+func (s *wsHeartbeatTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+// serve open a market data stream with a heartbeat and returns the errors
+// reported while it runs for d
+func (s *wsHeartbeatTestSuite) serve(interval, timeout, d time.Duration) (errs []error, closed bool) {
+	var mu sync.Mutex
+	doneC, stopC, err := WsMarketDataServe([]string{"TXN"}, func(event *WsMarketDataEvent) {}, func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	},
+		WithWsEndpoint("ws"+strings.TrimPrefix(s.server.URL, "http")),
+		WithWsHeartbeat(interval, timeout),
+	)
+	s.Require().NoError(err)
+	select {
+	case <-doneC:
+		closed = true
+	case <-time.After(d):
+		close(stopC)
+		<-doneC
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]error{}, errs...), closed
+}
+
+func (s *wsHeartbeatTestSuite) TestReplies() {
+	errs, closed := s.serve(20*time.Millisecond, 100*time.Millisecond, 200*time.Millisecond)
+	s.Require().Empty(errs)
+	s.Require().False(closed)
+}
+
+func (s *wsHeartbeatTestSuite) TestTimeoutShorterThanInterval() {
+	errs, closed := s.serve(100*time.Millisecond, 50*time.Millisecond, 500*time.Millisecond)
+	s.Require().Empty(errs)
+	s.Require().False(closed)
+}
+
+func (s *wsHeartbeatTestSuite) TestNoReply() {
+	s.replies.Store(false)
+	start := time.Now()
+	errs, closed := s.serve(200*time.Millisecond, 50*time.Millisecond, time.Second)
+	r := s.Require()
+	r.True(closed)
+	r.NotEmpty(errs)
+	r.ErrorIs(errs[0], ErrWsHeartbeatTimeout)
+	// the first ping times out without waiting for the interval
+	r.Less(time.Since(start), 200*time.Millisecond)
+}